@@ -1,113 +1,250 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"path/filepath"
+	"os"
 
-	"github.com/MayR-Labs/secureflow-go/internal/config"
 	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
 	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
 	"github.com/spf13/cobra"
 )
 
 var decryptCmd = &cobra.Command{
-	Use:   "decrypt",
+	Use:   "decrypt [file...]",
 	Short: "Decrypt files specified in the configuration",
 	Long: `Decrypts all encrypted files listed in secureflow.yaml back to their 
-original locations. Useful for local development and CI/CD pipelines.`,
+original locations. Useful for local development and CI/CD pipelines.
+
+File arguments and --only limit decryption to the named files, and --except
+skips files; files can be named by input path, output name, glob or tag.
+
+A missing encrypted file fails the run unless its entry is marked
+"optional: true" or --allow-missing is given, as does a file that fails to
+decrypt or to be copied to copy_to. Files that fail do not stop the others
+unless --fail-fast is given. Any failure ends the run with a summary of the
+failed files and the exit status of the most serious one (see secureflow
+--help); --continue-on-error exits successfully as long as one file was
+decrypted. The k8s-secret output always stops at the first failure.
+
+With --output-format k8s-secret, nothing is written to disk. The files are
+decrypted in memory and printed to stdout as Kubernetes Secret manifests,
+ready for kubectl apply -f -. A .env file becomes one data key per variable,
+any other file one key named after the file; a file's k8s block in the config
+can choose the Secret, its type and the key. Messages go to stderr.
+
+Example:
+  secureflow decrypt --output-format k8s-secret --namespace prod --name app-secrets | kubectl apply -f -`,
 	RunE: runDecrypt,
 }
 
+// Output formats accepted by decrypt --output-format
+const (
+	outputFiles     = "files"
+	outputK8sSecret = "k8s-secret"
+)
+
+// Flags for decrypt
+var (
+	decryptOutputFormat string
+	secretName          string
+	secretNamespace     string
+)
+
 func init() {
 	rootCmd.AddCommand(decryptCmd)
+	addSelectionFlags(decryptCmd)
+	addJobsFlag(decryptCmd)
+	addFailureFlags(decryptCmd)
+	decryptCmd.Flags().StringVar(&decryptOutputFormat, "output-format", outputFiles, "files (decrypt to the input paths) or k8s-secret (print Kubernetes Secrets to stdout)")
+	decryptCmd.Flags().StringVar(&secretName, "name", "", "Secret name for files without k8s.name, with --output-format k8s-secret")
+	decryptCmd.Flags().StringVar(&secretNamespace, "namespace", "", "Secret namespace for files without k8s.namespace, with --output-format k8s-secret")
 }
 
 func runDecrypt(cmd *cobra.Command, args []string) error {
+	switch decryptOutputFormat {
+	case outputFiles:
+		if secretName != "" || secretNamespace != "" {
+			return fmt.Errorf("--name and --namespace require --output-format %s", outputK8sSecret)
+		}
+	case outputK8sSecret:
+		// Stdout carries the manifests, so messages and prompts go to stderr
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
+		return runDecryptSecrets(cmd, args, stdout)
+	default:
+		return fmt.Errorf("invalid output format %q (expected %s or %s)", decryptOutputFormat, outputFiles, outputK8sSecret)
+	}
+
+	if err := checkJobs(); err != nil {
+		return err
+	}
+
 	// Load config
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
+	}
+	if _, err := selectFiles(cfg, args); err != nil {
+		return err
 	}
 
-	// Get password
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Get password (not needed when decrypting with identities)
 	var pwd string
-	if password != "" {
-		pwd = password
-	} else if nonInteractive {
-		return fmt.Errorf("password required in non-interactive mode (use --password flag)")
-	} else {
-		pwd, err = utils.ReadPassword(utils.Colorize(utils.ColorBlue, "🔐 Enter password to decrypt your secrets: "))
-		if err != nil {
-			return err
-		}
-		if pwd == "" {
-			return fmt.Errorf("password cannot be empty")
-		}
+	if len(opts.Identities) > 0 {
+		fmt.Printf("%s 🔑 Using %d identity key(s)\n", utils.ColorBlue, len(opts.Identities))
+	} else if pwd, err = resolvePassword(cfg, "🔐 Enter password to decrypt your secrets: "); err != nil {
+		return err
 	}
 
 	fmt.Println()
 	fmt.Printf("%s 🔐 Starting decryption process...\n\n", utils.ColorYellow)
 
-	// Decrypt each file
-	successCount := 0
-	for _, fileMapping := range cfg.Files {
-		encryptedPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
+	project := &secureflow.Project{
+		Config:       cfg,
+		Options:      opts,
+		Password:     pwd,
+		Progress:     printDecryptProgress(true),
+		Jobs:         jobs,
+		AllowMissing: allowMissing,
+		FailFast:     failFast,
+	}
+	res, err := project.Decrypt()
+	if err := checkResult(cmd, "decryption failed", res, err); err != nil {
+		return err
+	}
 
-		fmt.Printf("%s 📄 Decrypting %s...\n", utils.ColorYellow, encryptedPath)
+	fmt.Println()
+	if len(res.Failed) > 0 {
+		fmt.Printf("%s ✅ %d file(s) decrypted\n", utils.ColorGreen, res.Done)
+	} else {
+		fmt.Printf("%s 🎉 All secrets decrypted successfully! (%d file(s))\n", utils.ColorGreen, res.Done)
+	}
 
-		// Check if encrypted file exists
-		if !utils.FileExists(encryptedPath) {
-			fmt.Printf("%s ⚠️  Warning: %s not found, skipping\n\n", utils.ColorYellow, encryptedPath)
-			continue
-		}
+	return nil
+}
 
-		// Ensure output directory exists
-		outputDir := filepath.Dir(fileMapping.Input)
-		if outputDir != "." && outputDir != "" {
-			if err := utils.EnsureDir(outputDir); err != nil {
-				fmt.Printf("%s ❌ Failed to create directory %s: %v\n\n", utils.ColorRed, outputDir, err)
-				continue
-			}
-		}
+// runDecryptSecrets decrypts the selected files in memory and writes them to
+// out as Kubernetes Secrets. It stops at the first file that fails.
+func runDecryptSecrets(cmd *cobra.Command, args []string, out *os.File) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := selectFiles(cfg, args); err != nil {
+		return err
+	}
 
-		// Decrypt file
-		if err := crypto.DecryptFile(encryptedPath, fileMapping.Input, pwd); err != nil {
-			fmt.Printf("%s ❌ Failed to decrypt %s: %v\n\n", utils.ColorRed, encryptedPath, err)
-			return fmt.Errorf("decryption failed (wrong password?)")
-		}
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
 
-		fmt.Printf("%s ✅ %s decrypted successfully -> %s\n", utils.ColorGreen, encryptedPath, fileMapping.Input)
-
-		// Handle copy_to if specified
-		if fileMapping.CopyTo != "" {
-			// Ensure copy_to directory exists
-			copyToDir := filepath.Dir(fileMapping.CopyTo)
-			if copyToDir != "." && copyToDir != "" {
-				if err := utils.EnsureDir(copyToDir); err != nil {
-					fmt.Printf("%s ⚠️  Warning: Failed to create directory %s: %v\n", utils.ColorYellow, copyToDir, err)
-					fmt.Println()
-					continue
-				}
-			}
+	// Get password (not needed when decrypting with identities)
+	var pwd string
+	if len(opts.Identities) > 0 {
+		fmt.Printf("%s 🔑 Using %d identity key(s)\n", utils.ColorBlue, len(opts.Identities))
+	} else if pwd, err = resolvePassword(cfg, "🔐 Enter password to decrypt your secrets: "); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("%s 🔐 Starting decryption process...\n\n", utils.ColorYellow)
+
+	project := &secureflow.Project{
+		Config:       cfg,
+		Options:      opts,
+		Password:     pwd,
+		Progress:     printDecryptProgress(false),
+		AllowMissing: allowMissing,
+	}
+	secrets, err := project.Secrets(secureflow.SecretOptions{Name: secretName, Namespace: secretNamespace})
+	if err := checkResult(cmd, "decryption failed", nil, err); err != nil {
+		return err
+	}
+	if err := secureflow.WriteSecrets(out, secrets); err != nil {
+		return err
+	}
 
-			// Copy the decrypted file to the copy_to location
-			if err := utils.CopyFile(fileMapping.Input, fileMapping.CopyTo); err != nil {
-				fmt.Printf("%s ⚠️  Warning: Failed to copy %s to %s: %v\n", utils.ColorYellow, fileMapping.Input, fileMapping.CopyTo, err)
+	fmt.Printf("%s 🎉 %d Secret(s) written to stdout\n", utils.ColorGreen, len(secrets))
+	return nil
+}
+
+// printDecryptProgress reports each file as decrypt, test or the k8s-secret
+// output processes it; copies is set when files may be copied to copy_to,
+// which then ends the file's messages
+func printDecryptProgress(copies bool) func(secureflow.Event) {
+	return func(e secureflow.Event) {
+		switch e.Kind {
+		case secureflow.ManifestLoaded:
+			fmt.Printf("%s 🧾 Validating against %s\n\n", utils.ColorBlue, e.Path)
+		case secureflow.FileStarted:
+			fmt.Printf("%s 📄 Decrypting %s...\n", utils.ColorYellow, e.Path)
+		case secureflow.FileSkipped:
+			fmt.Printf("%s ⚠️  Warning: %s not found, skipping\n\n", utils.ColorYellow, e.Path)
+		case secureflow.FileFailed:
+			if errors.Is(e.Err, secureflow.ErrMissing) {
+				fmt.Printf("%s ❌ %s not found\n\n", utils.ColorRed, e.Path)
 			} else {
-				fmt.Printf("%s 📋 Copied to %s\n", utils.ColorGreen, fileMapping.CopyTo)
+				fmt.Printf("%s ❌ Failed to decrypt %s: %v\n\n", utils.ColorRed, e.Path, e.Err)
+			}
+		case secureflow.FileDone:
+			fmt.Printf("%s ✅ %s decrypted successfully -> %s\n", utils.ColorGreen, e.Path, e.Dest)
+			if e.File.CopyTo == "" || !copies {
+				fmt.Println()
 			}
+		case secureflow.FileCopied:
+			if e.Err != nil {
+				fmt.Printf("%s ❌ Failed to copy %s to %s: %v\n", utils.ColorRed, e.Path, e.Dest, e.Err)
+			} else {
+				fmt.Printf("%s 📋 Copied to %s\n", utils.ColorGreen, e.Dest)
+			}
+			fmt.Println()
 		}
-
-		fmt.Println()
-		successCount++
 	}
+}
 
-	if successCount == 0 {
-		return fmt.Errorf("no files were decrypted")
+// decryptionError reports why decryption failed as precisely as the file
+// format allows. Authenticated files distinguish a wrong password from
+// corruption; OpenSSL files can only hint at the likely cause. err stays
+// the cause, which picks the exit status.
+func decryptionError(prefix string, err error) error {
+	var msg string
+	switch {
+	case errors.Is(err, crypto.ErrWrongPassword):
+		msg = fmt.Sprintf("%s: wrong password", prefix)
+	case errors.Is(err, crypto.ErrCorrupted):
+		msg = fmt.Sprintf("%s: file is corrupted or has been tampered with", prefix)
+	case errors.Is(err, crypto.ErrNoIdentity):
+		msg = fmt.Sprintf("%s: %v (use --identity with a matching key file)", prefix, err)
+	case errors.Is(err, manifest.ErrMismatch):
+		msg = fmt.Sprintf("%s: %v", prefix, err)
+	case errors.Is(err, crypto.ErrInvalidFormat):
+		msg = fmt.Sprintf("%s: not a SecureFlow encrypted file", prefix)
+	default:
+		msg = fmt.Sprintf("%s (wrong password?)", prefix)
 	}
+	return &causeError{msg: msg, cause: err}
+}
 
-	fmt.Println()
-	fmt.Printf("%s 🎉 All secrets decrypted successfully! (%d file(s))\n", utils.ColorGreen, successCount)
+// causeError replaces the message of an error with a clearer one
+type causeError struct {
+	msg   string
+	cause error
+}
 
-	return nil
+func (e *causeError) Error() string {
+	return e.msg
+}
+
+func (e *causeError) Unwrap() error {
+	return e.cause
 }
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/diff"
+	"github.com/MayR-Labs/secureflow-go/internal/dotenv"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [file...]",
+	Short: "Show changes inside encrypted files since a git revision",
+	Long: `Decrypts the working-tree version of each encrypted file and the version
+at a git revision (HEAD by default), in memory, and prints a unified diff of
+the plaintext. Nothing is written to disk. Files with more than 2000 added
+and removed lines are only reported as changed.
+
+File arguments, --only and --except limit the comparison to some files,
+named by input path, output name, glob or tag; without them every
+configured file is compared.
+
+With --mask, .env-style files are shown as KEY=******** lines tagged with a
+short per-run fingerprint of each value, so reviewers can see which keys
+were added, removed or changed without seeing the secrets. Other files are
+then only reported as changed.`,
+	RunE: runDiff,
+}
+
+var (
+	diffRev  string
+	diffMask bool
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVar(&diffRev, "rev", "HEAD", "git revision to compare the working tree against")
+	diffCmd.Flags().BoolVar(&diffMask, "mask", false, "hide values: show only which keys changed in .env files")
+	addSelectionFlags(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	// Load config
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, err := selectFiles(cfg, args); err != nil {
+		return err
+	}
+
+	if err := exec.Command("git", "rev-parse", "--verify", "--quiet", diffRev+"^{commit}").Run(); err != nil {
+		return fmt.Errorf("unknown git revision %q", diffRev)
+	}
+
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Get password (not needed when decrypting with identities)
+	var pwd string
+	if len(opts.Identities) == 0 {
+		if pwd, err = resolvePassword(cfg, "🔐 Enter password to decrypt your secrets: "); err != nil {
+			return err
+		}
+	}
+
+	// Values are tagged with a key that only lives for this run, so tags
+	// cannot be compared across runs to guess values
+	maskKey := make([]byte, 32)
+	if _, err := rand.Read(maskKey); err != nil {
+		return fmt.Errorf("failed to generate mask key: %w", err)
+	}
+
+	color := term.IsTerminal(int(os.Stdout.Fd()))
+	changed := 0
+	for _, fileMapping := range cfg.Files {
+		encryptedPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
+
+		old, err := gitShow(diffRev, encryptedPath)
+		if err != nil {
+			return err
+		}
+		current, err := readIfExists(encryptedPath)
+		if err != nil {
+			return err
+		}
+
+		oldPlain, err := decryptBytes(old, pwd, opts)
+		if err != nil {
+			return decryptionError(fmt.Sprintf("failed to decrypt %s at %s", encryptedPath, diffRev), err)
+		}
+		newPlain, err := decryptBytes(current, pwd, opts)
+		if err != nil {
+			return decryptionError(fmt.Sprintf("failed to decrypt %s", encryptedPath), err)
+		}
+
+		if bytes.Equal(oldPlain, newPlain) {
+			continue
+		}
+		changed++
+
+		oldName := fmt.Sprintf("a/%s (%s)", fileMapping.Input, diffRev)
+		newName := fmt.Sprintf("b/%s (working tree)", fileMapping.Input)
+		switch {
+		case old == nil:
+			oldName = "/dev/null"
+		case current == nil:
+			newName = "/dev/null"
+		}
+
+		if diffMask {
+			if !dotenv.IsDotenv(fileMapping.Input) {
+				fmt.Printf("Contents of %s changed (hidden by --mask)\n", fileMapping.Input)
+				continue
+			}
+			if oldPlain, err = maskDotenv(oldPlain, maskKey); err != nil {
+				return fmt.Errorf("cannot mask %s at %s: %w", fileMapping.Input, diffRev, err)
+			}
+			if newPlain, err = maskDotenv(newPlain, maskKey); err != nil {
+				return fmt.Errorf("cannot mask %s: %w", fileMapping.Input, err)
+			}
+		}
+
+		if diff.IsBinary(oldPlain) || diff.IsBinary(newPlain) {
+			fmt.Printf("Binary files %s and %s differ\n", oldName, newName)
+			continue
+		}
+		printDiff(oldName, newName, oldPlain, newPlain, color)
+	}
+
+	if changed == 0 {
+		fmt.Printf("%s ✅ No changes since %s\n", utils.ColorGreen, diffRev)
+	}
+	return nil
+}
+
+// findFileMapping finds the configured file whose input path or output name
+// is name
+func findFileMapping(cfg *config.Config, name string) (config.FileMapping, bool) {
+	clean := filepath.Clean(name)
+	for _, fileMapping := range cfg.Files {
+		if filepath.Clean(fileMapping.Input) == clean || fileMapping.Output == name ||
+			filepath.Join(cfg.OutputDir, fileMapping.Output) == clean {
+			return fileMapping, true
+		}
+	}
+	return config.FileMapping{}, false
+}
+
+// gitShow returns the contents of path at rev, or nil if it did not exist
+func gitShow(rev, path string) ([]byte, error) {
+	if filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if path, err = filepath.Rel(wd, path); err != nil {
+			return nil, err
+		}
+	}
+	object := rev + ":./" + filepath.ToSlash(path)
+
+	if err := exec.Command("git", "cat-file", "-e", object).Run(); err != nil {
+		return nil, nil
+	}
+
+	var stderr bytes.Buffer
+	show := exec.Command("git", "show", object)
+	show.Stderr = &stderr
+	data, err := show.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show %s failed: %s", object, strings.TrimSpace(stderr.String()))
+	}
+	return data, nil
+}
+
+// readIfExists reads path, returning nil if it does not exist
+func readIfExists(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// decryptBytes decrypts an encrypted file held in memory; nil stays nil
+func decryptBytes(data []byte, pwd string, opts crypto.Options) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var plaintext bytes.Buffer
+	if err := crypto.Decrypt(bytes.NewReader(data), &plaintext, pwd, opts); err != nil {
+		return nil, err
+	}
+	return plaintext.Bytes(), nil
+}
+
+// maskDotenv replaces every value in a .env file with a short keyed tag
+func maskDotenv(data, key []byte) ([]byte, error) {
+	entries, err := dotenv.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return dotenv.Mask(entries, func(value string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))[:8]
+	}), nil
+}
+
+// printDiff prints a unified diff, colored when writing to a terminal
+func printDiff(oldName, newName string, old, new []byte, color bool) {
+	hunks, err := diff.Hunks(old, new, diff.DefaultContext)
+	if err != nil {
+		fmt.Printf("Files %s and %s differ (%v)\n", oldName, newName, err)
+		return
+	}
+	if hunks == nil {
+		return
+	}
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return utils.Colorize(c, s)
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		fmt.Println(paint(utils.ColorBlue, h.Header()))
+		for _, line := range h.Lines {
+			switch line.Kind {
+			case '-':
+				fmt.Print(paint(utils.ColorRed, line.String()))
+			case '+':
+				fmt.Print(paint(utils.ColorGreen, line.String()))
+			default:
+				fmt.Print(line.String())
+			}
+		}
+	}
+}
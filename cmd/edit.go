@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
+	"github.com/spf13/cobra"
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <file>",
+	Short: "Edit an encrypted file in $EDITOR and re-encrypt it on save",
+	Long: `Decrypts one configured file into a private temporary file, opens it in
+$VISUAL or $EDITOR, and re-encrypts it if the content changed.
+
+The temporary file is created with mode 0600 in a private directory, on
+/dev/shm when available so the plaintext stays in memory. It is overwritten
+and removed when the editor exits, including after Ctrl-C or a crash. Ctrl-C
+or SIGTERM after the editor exits cancels the re-encryption.
+
+The file argument may be a configured input path or encrypted output name.
+If the encrypted file does not exist yet, the editor starts empty.
+
+After a git merge of a file encrypted with encryption: values, take either
+side of the conflicting secureflow-values line and manifest.json entry and
+run edit --merged. It accepts the merged document as long as every value
+decrypts, lets you review it, and seals it again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEdit,
+}
+
+var editMerged bool
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+	editCmd.Flags().BoolVar(&editMerged, "merged", false, "accept a values file merged by git, whose document MAC no longer matches, and re-encrypt it")
+}
+
+func runEdit(cmd *cobra.Command, args []string) error {
+	// Load config
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fileMapping, ok := findFileMapping(cfg, args[0])
+	if !ok {
+		return fmt.Errorf("%s is not listed in %s", args[0], cfgFile)
+	}
+	encryptedPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
+
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	// A password is needed unless decrypting with identities and
+	// re-encrypting to recipients
+	var pwd string
+	if len(opts.Identities) == 0 || len(opts.Recipients) == 0 {
+		if pwd, err = resolvePassword(cfg, "🔐 Enter password: "); err != nil {
+			return err
+		}
+	}
+
+	m, key, err := loadManifest(cfg, pwd)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := readIfExists(encryptedPath)
+	if err != nil {
+		return err
+	}
+	if entry := m.Entry(fileMapping.Output); ciphertext != nil && entry != nil && !editMerged {
+		if err := entry.VerifyCiphertext(encryptedPath); err != nil {
+			return decryptionError("edit aborted", err)
+		}
+	}
+	readOpts := opts
+	readOpts.IgnoreDocumentMAC = editMerged
+	original, err := decryptBytes(ciphertext, pwd, readOpts)
+	if err != nil {
+		return decryptionError(fmt.Sprintf("failed to decrypt %s", encryptedPath), err)
+	}
+
+	dir, err := os.MkdirTemp(privateTempDir(), "secureflow-edit-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	// A signal would kill the process before the deferred scrub, so they are
+	// caught until it has run; one that arrives after the editor exits
+	// cancels the re-encryption
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	// Editors leave swap and backup files next to the file, so the whole
+	// directory is scrubbed
+	defer scrubDir(dir)
+
+	tmpPath := filepath.Join(dir, filepath.Base(fileMapping.Input))
+	if err := writePrivateFile(tmpPath, original); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s 📝 Editing %s (plaintext in %s)\n", utils.ColorBlue, fileMapping.Input, dir)
+	if err := runEditor(tmpPath, signals); err != nil {
+		return err
+	}
+
+	edited, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to read edited file: %w", err)
+	}
+	if bytes.Equal(edited, original) && !editMerged {
+		fmt.Printf("%s ✅ No changes to %s\n", utils.ColorGreen, fileMapping.Input)
+		return nil
+	}
+
+	fileOpts, err := secureflow.FileOptions(opts, fileMapping, ciphertext)
+	if err != nil {
+		return err
+	}
+	var encrypted bytes.Buffer
+	if err := crypto.Encrypt(bytes.NewReader(edited), &encrypted, pwd, fileOpts); err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", fileMapping.Input, err)
+	}
+	select {
+	case sig := <-signals:
+		return fmt.Errorf("received %v, nothing was re-encrypted", sig)
+	default:
+	}
+	if err := utils.EnsureDir(cfg.OutputDir); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(encryptedPath, encrypted.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if err := updateManifestEntry(cfg, m, key, fileMapping, edited, encrypted.Bytes()); err != nil {
+		return fmt.Errorf("%s was re-encrypted but the manifest was not updated: %w", encryptedPath, err)
+	}
+
+	fmt.Printf("%s ✅ %s re-encrypted -> %s\n", utils.ColorGreen, fileMapping.Input, encryptedPath)
+	if utils.FileExists(fileMapping.Input) {
+		fmt.Printf("%s ⚠️  Your local %s is now out of date; run secureflow decrypt to refresh it\n", utils.ColorYellow, fileMapping.Input)
+	}
+	return nil
+}
+
+// privateTempDir prefers a memory-backed directory for plaintext
+func privateTempDir() string {
+	if runtime.GOOS == "linux" {
+		if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+			return "/dev/shm"
+		}
+	}
+	return os.TempDir()
+}
+
+// writePrivateFile creates path readable only by the current user
+func writePrivateFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return nil
+}
+
+// runEditor opens path in the user's editor and waits for it to exit. The
+// caller catches signals on signals; Ctrl-C is left to the editor, which
+// gets it from the terminal, and SIGTERM and SIGHUP are passed on to it.
+func runEditor(path string, signals <-chan os.Signal) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case runtime.GOOS == "windows" && editor == "":
+		cmd = exec.Command("notepad", path)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("cmd", "/C", editor+` "`+path+`"`)
+	default:
+		if editor == "" {
+			editor = "vi"
+		}
+		// Let the shell split editors like "code --wait"
+		cmd = exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start editor %q: %w", editor, err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == syscall.SIGTERM || sig == syscall.SIGHUP {
+					cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("editor exited with an error, nothing was re-encrypted: %w", err)
+	}
+	return nil
+}
+
+// scrubDir overwrites every regular file under dir with zeros and removes
+// the directory. On copy-on-write or journaling filesystems the overwrite is
+// best effort, which is why a memory-backed directory is preferred.
+func scrubDir(dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			overwriteFile(path, info.Size())
+		}
+		return nil
+	})
+	os.RemoveAll(dir)
+}
+
+// overwriteFile replaces the first size bytes of path with zeros
+func overwriteFile(path string, size int64) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	zeros := make([]byte, 32*1024)
+	for size > 0 {
+		n := int64(len(zeros))
+		if size < n {
+			n = size
+		}
+		if _, err := file.Write(zeros[:n]); err != nil {
+			return
+		}
+		size -= n
+	}
+	file.Sync()
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+)
+
+func TestEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell script as the editor")
+	}
+
+	tests := []struct {
+		name      string
+		script    string
+		wantErr   bool
+		rewritten bool
+		expected  string
+	}{
+		{name: "NoChange", script: ""},
+		{name: "Changed", script: `printf 'DEBUG=1\n' >> "$1"`, rewritten: true, expected: "API_KEY=secret\nDEBUG=1\n"},
+		{name: "EditorFails", script: `printf 'DEBUG=1\n' >> "$1"; exit 1`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			writeFiles(t, map[string]string{
+				"secureflow.yaml": "output_dir: enc\nfiles:\n  - input: .env\n    output: env.encrypted\n",
+				".env":            "API_KEY=secret\n",
+			})
+			setFlag(t, &cfgFile, "secureflow.yaml")
+			setFlag(t, &nonInteractive, true)
+			setFlag(t, &passwordFlag, "password")
+			setFlag(t, &pbkdf2Iter, 1000)
+			setFlag(t, &editMerged, false)
+			p := encryptProject(t, "password")
+
+			// The editor records the file it was given, to check it is gone
+			pathFile := filepath.Join(dir, "edited-path")
+			editor := filepath.Join(dir, "editor.sh")
+			script := "#!/bin/sh\necho \"$1\" > '" + pathFile + "'\n" + tt.script + "\n"
+			if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+				t.Fatalf("Failed to write editor script: %v", err)
+			}
+			t.Setenv("VISUAL", "")
+			t.Setenv("EDITOR", editor)
+
+			encryptedPath := filepath.Join("enc", "env.encrypted")
+			ciphertext, err := os.ReadFile(encryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", encryptedPath, err)
+			}
+			manifestData, err := os.ReadFile(manifest.Path("enc"))
+			if err != nil {
+				t.Fatalf("Failed to read manifest: %v", err)
+			}
+
+			err = runEdit(editCmd, []string{".env"})
+			if tt.wantErr && err == nil {
+				t.Error("Expected error when the editor fails")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Edit failed: %v", err)
+			}
+
+			edited, err := os.ReadFile(pathFile)
+			if err != nil {
+				t.Fatalf("Expected the editor to run: %v", err)
+			}
+			tmpDir := filepath.Dir(string(bytes.TrimSpace(edited)))
+			if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
+				t.Errorf("Expected temporary directory %s to be removed, got %v", tmpDir, err)
+			}
+
+			got, err := os.ReadFile(encryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", encryptedPath, err)
+			}
+			gotManifest, err := os.ReadFile(manifest.Path("enc"))
+			if err != nil {
+				t.Fatalf("Failed to read manifest: %v", err)
+			}
+			if !tt.rewritten {
+				if !bytes.Equal(got, ciphertext) {
+					t.Error("Expected the encrypted file to be unchanged")
+				}
+				if !bytes.Equal(gotManifest, manifestData) {
+					t.Error("Expected the manifest to be unchanged")
+				}
+				return
+			}
+
+			plaintext, err := decryptBytes(got, "password", p.Options)
+			if err != nil {
+				t.Fatalf("Failed to decrypt the edited file: %v", err)
+			}
+			if string(plaintext) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, plaintext)
+			}
+			m, err := manifest.Load("enc")
+			if err != nil {
+				t.Fatalf("Failed to load manifest: %v", err)
+			}
+			if err := m.Entry("env.encrypted").VerifyCiphertext(encryptedPath); err != nil {
+				t.Errorf("Expected the manifest to describe the new file: %v", err)
+			}
+		})
+	}
+}
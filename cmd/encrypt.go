@@ -1,55 +1,86 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/MayR-Labs/secureflow-go/internal/config"
-	"github.com/MayR-Labs/secureflow-go/internal/crypto"
 	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
 	"github.com/spf13/cobra"
 )
 
 var encryptCmd = &cobra.Command{
-	Use:   "encrypt",
+	Use:   "encrypt [file...]",
 	Short: "Encrypt files specified in the configuration",
-	Long: `Encrypts all files listed in secureflow.yaml using AES-256-CBC encryption.
-Generates a report file with metadata about encrypted files.`,
+	Long: `Encrypts all files listed in secureflow.yaml, or only those named by
+file arguments and --only, minus any matching --except. Files can be named by
+input path, output name, glob or tag. The container format is
+chosen by the "format" setting: "openssl" (default) writes OpenSSL-compatible
+AES-256-CBC files, "aead" writes authenticated AES-256-GCM files.
+If "recipients" are configured, files are encrypted to those public keys
+instead of a password.
+
+Writes a manifest.json to the output directory recording each file's size,
+ciphertext hash and a password-keyed plaintext fingerprint, which decrypt and
+test validate against. Use --report-format text for the older report.txt.
+When only some files are encrypted, their entries in an existing manifest
+are updated and the others are kept.
+
+A missing input fails the run unless its entry is marked "optional: true"
+or --allow-missing is given. Files that fail do not stop the others unless
+--fail-fast is given. Any failure ends the run with a summary of the failed
+files and the exit status of the most serious one (see secureflow --help);
+--continue-on-error exits successfully as long as one file was encrypted.`,
 	RunE: runEncrypt,
 }
 
+var reportFormat string
+
 func init() {
 	rootCmd.AddCommand(encryptCmd)
+	encryptCmd.Flags().StringVar(&reportFormat, "report-format", string(secureflow.ReportJSON), "report to write to the output directory: json (manifest.json), text (report.txt) or none")
+	addSelectionFlags(encryptCmd)
+	addJobsFlag(encryptCmd)
+	addFailureFlags(encryptCmd)
 }
 
 func runEncrypt(cmd *cobra.Command, args []string) error {
+	if err := checkJobs(); err != nil {
+		return err
+	}
+
 	// Load config
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	partial, err := selectFiles(cfg, args)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
-	// Get password
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	switch secureflow.ReportFormat(reportFormat) {
+	case secureflow.ReportJSON, secureflow.ReportText, secureflow.ReportNone:
+	default:
+		return fmt.Errorf("invalid --report-format %q (expected json, text or none)", reportFormat)
+	}
+
+	// Get password (not needed when encrypting to recipients)
 	var pwd string
-	if password != "" {
-		pwd = password
-	} else if nonInteractive {
-		return fmt.Errorf("password required in non-interactive mode (use --password flag)")
-	} else {
-		pwd, err = utils.ReadPassword(utils.Colorize(utils.ColorBlue, "🔐 Enter password to encrypt your secrets: "))
-		if err != nil {
-			return err
-		}
-		if pwd == "" {
-			return fmt.Errorf("password cannot be empty")
-		}
+	if len(opts.Recipients) > 0 {
+		fmt.Printf("%s 🔑 Encrypting to %d recipient(s)\n", utils.ColorBlue, len(opts.Recipients))
+	} else if pwd, err = resolvePassword(cfg, "🔐 Enter password to encrypt your secrets: "); err != nil {
+		return err
 	}
 
 	// Get optional password hint
 	var passwordHint string
-	if !nonInteractive {
+	if !nonInteractive && len(opts.Recipients) == 0 {
 		passwordHint, err = utils.ReadLine(utils.Colorize(utils.ColorBlue, "🔑 (Optional) Enter a password hint (leave blank to skip): "))
 		if err != nil {
 			return err
@@ -64,84 +95,57 @@ func runEncrypt(cmd *cobra.Command, args []string) error {
 			return err
 		}
 	}
-	if note == "" {
-		note = "Encrypted secrets for CI/CD"
-	}
 
 	fmt.Println()
 
-	// Ensure output directory exists
-	if err := utils.EnsureDir(cfg.OutputDir); err != nil {
+	project := &secureflow.Project{
+		Config:       cfg,
+		Options:      opts,
+		Password:     pwd,
+		ToolVersion:  Version,
+		Progress:     printEncryptProgress,
+		Jobs:         jobs,
+		AllowMissing: allowMissing,
+		FailFast:     failFast,
+	}
+	res, err := project.Encrypt(secureflow.EncryptOptions{
+		Report:       secureflow.ReportFormat(reportFormat),
+		Note:         note,
+		PasswordHint: passwordHint,
+		Merge:        partial,
+	})
+	if err := checkResult(cmd, "encryption failed", res, err); err != nil {
 		return err
 	}
 
-	// Create report file
-	reportPath := filepath.Join(cfg.OutputDir, "report.txt")
-	reportFile, err := os.Create(reportPath)
-	if err != nil {
-		return fmt.Errorf("failed to create report file: %w", err)
+	fmt.Printf("%s ✅ Encryption complete. %d file(s) saved to %s\n", utils.ColorGreen, res.Done, cfg.OutputDir)
+	if res.Report != "" {
+		fmt.Printf("📄 Report saved to %s\n", res.Report)
 	}
-	defer reportFile.Close()
-
-	// Write report header
-	fmt.Fprintf(reportFile, "Encryption Report\n")
-	fmt.Fprintf(reportFile, "=================\n")
-	fmt.Fprintf(reportFile, "\n")
-	fmt.Fprintf(reportFile, "Note: %s\n", note)
-	if passwordHint != "" {
-		fmt.Fprintf(reportFile, "Password Hint: %s\n", passwordHint)
-	} else {
-		fmt.Fprintf(reportFile, "Password Hint: N/A\n")
+
+	if changed, err := syncGitignore(); err != nil {
+		fmt.Printf("%s ⚠️  Warning: Could not update .gitignore: %v\n", utils.ColorYellow, err)
+	} else if changed {
+		fmt.Printf("%s 📝 Updated the plaintext paths in .gitignore\n", utils.ColorBlue)
 	}
-	fmt.Fprintf(reportFile, "Created at: %s\n", time.Now().Format("2006-01-02"))
-	fmt.Fprintf(reportFile, "=================\n")
-	fmt.Fprintf(reportFile, "\n")
-
-	// Encrypt each file
-	successCount := 0
-	for _, fileMapping := range cfg.Files {
-		fmt.Printf("%s 📦 Encrypting %s...\n", utils.ColorYellow, fileMapping.Input)
-
-		// Check if input file exists
-		if !utils.FileExists(fileMapping.Input) {
-			fmt.Printf("%s ⚠️  Warning: %s not found, skipping\n\n", utils.ColorYellow, fileMapping.Input)
-			continue
-		}
 
-		// Get file info before encryption
-		fileInfo, err := utils.GetFileInfo(fileMapping.Input)
-		if err != nil {
-			fmt.Printf("%s ⚠️  Warning: Could not get file info for %s: %v\n\n", utils.ColorYellow, fileMapping.Input, err)
-			continue
-		}
+	return nil
+}
 
-		// Encrypt file
-		outputPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
-		if err := crypto.EncryptFile(fileMapping.Input, outputPath, pwd); err != nil {
-			fmt.Printf("%s ❌ Failed to encrypt %s: %v\n\n", utils.ColorRed, fileMapping.Input, err)
-			continue
+// printEncryptProgress reports each file as encrypt processes it
+func printEncryptProgress(e secureflow.Event) {
+	switch e.Kind {
+	case secureflow.FileStarted:
+		fmt.Printf("%s 📦 Encrypting %s...\n", utils.ColorYellow, e.Path)
+	case secureflow.FileSkipped:
+		fmt.Printf("%s ⚠️  Warning: %s not found, skipping\n\n", utils.ColorYellow, e.Path)
+	case secureflow.FileFailed:
+		if errors.Is(e.Err, secureflow.ErrMissing) {
+			fmt.Printf("%s ❌ %s not found\n\n", utils.ColorRed, e.Path)
+		} else {
+			fmt.Printf("%s ❌ Failed to encrypt %s: %v\n\n", utils.ColorRed, e.Path, e.Err)
 		}
-
-		fmt.Printf("%s ✅ %s encrypted successfully -> %s\n\n", utils.ColorGreen, fileMapping.Input, outputPath)
-
-		// Write to report
-		fmt.Fprintf(reportFile, "File:           %s\n", fileMapping.Input)
-		fmt.Fprintf(reportFile, "Encrypted As:   %s\n", fileMapping.Output)
-		fmt.Fprintf(reportFile, "Size (bytes):   %d\n", fileInfo.Size)
-		fmt.Fprintf(reportFile, "Lines:          %d\n", fileInfo.Lines)
-		fmt.Fprintf(reportFile, "Last Modified:  %s\n", fileInfo.LastModified.Format("2006-01-02 15:04:05"))
-		fmt.Fprintf(reportFile, "----------------------------------------\n")
-		fmt.Fprintf(reportFile, "\n")
-
-		successCount++
+	case secureflow.FileDone:
+		fmt.Printf("%s ✅ %s encrypted successfully -> %s\n\n", utils.ColorGreen, e.Path, e.Dest)
 	}
-
-	if successCount == 0 {
-		return fmt.Errorf("no files were encrypted")
-	}
-
-	fmt.Printf("%s ✅ Encryption complete. %d file(s) saved to %s\n", utils.ColorGreen, successCount, cfg.OutputDir)
-	fmt.Printf("📄 Report saved to %s\n", reportPath)
-
-	return nil
 }
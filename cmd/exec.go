@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/dotenv"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/password"
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [--file FILE]... -- command [args...]",
+	Short: "Run a command with secrets from encrypted .env files in its environment",
+	Long: `Decrypts .env-style files in memory, adds their variables to the
+environment of a child process and runs it. The plaintext is never written
+to disk.
+
+Variables from the files override the inherited environment, and later
+files override earlier ones. Without --file, every configured .env-style
+file is used. SECUREFLOW_PASSWORD and the --password-env variable are
+removed from the child's environment.
+
+SIGTERM, SIGHUP and SIGQUIT are forwarded to the child; Ctrl-C reaches it
+from the terminal. secureflow exits with the child's exit code.
+
+Example:
+  secureflow exec --file .env.prod -- npm start`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runExec,
+}
+
+var execFiles []string
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().StringArrayVar(&execFiles, "file", nil, "configured .env file (input path or output name) to load; repeatable")
+	// Everything after the command name belongs to the command
+	execCmd.Flags().SetInterspersed(false)
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	// Load config
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var files []config.FileMapping
+	for _, name := range execFiles {
+		fileMapping, ok := findFileMapping(cfg, name)
+		if !ok {
+			return fmt.Errorf("%s is not listed in %s", name, cfgFile)
+		}
+		files = append(files, fileMapping)
+	}
+	if len(execFiles) == 0 {
+		for _, fileMapping := range cfg.Files {
+			if dotenv.IsDotenv(fileMapping.Input) {
+				files = append(files, fileMapping)
+			}
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no .env files in %s; name one with --file", cfgFile)
+		}
+	}
+
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Get password (not needed when decrypting with identities)
+	var pwd string
+	if len(opts.Identities) == 0 {
+		if pwd, err = resolvePassword(cfg, "🔐 Enter password to decrypt your secrets: "); err != nil {
+			return err
+		}
+	}
+
+	// Stdout belongs to the child, so the manifest is checked quietly
+	m, err := manifest.Load(cfg.OutputDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var entries []dotenv.Entry
+	for _, fileMapping := range files {
+		encryptedPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
+
+		ciphertext, err := os.ReadFile(encryptedPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", encryptedPath, err)
+		}
+		if entry := m.Entry(fileMapping.Output); entry != nil {
+			if err := entry.VerifyCiphertext(encryptedPath); err != nil {
+				return decryptionError("exec aborted", err)
+			}
+		}
+
+		plaintext, err := decryptBytes(ciphertext, pwd, opts)
+		if err != nil {
+			return decryptionError(fmt.Sprintf("failed to decrypt %s", encryptedPath), err)
+		}
+		parsed, err := dotenv.Parse(plaintext)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", fileMapping.Input, err)
+		}
+		entries = append(entries, parsed...)
+	}
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = dotenv.Merge(childEnviron(cfg), entries)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	// From here on the child reports its own failures; Execute still
+	// prints errors that are not an exit code
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return runChild(child)
+}
+
+// childEnviron returns this process's environment without the variables
+// that carried the SecureFlow password
+func childEnviron(cfg *config.Config) []string {
+	src := passwordSource(cfg)
+	hidden := map[string]bool{password.DefaultEnv: true, src.DefaultEnv: true}
+	if src.Env != "" {
+		hidden[src.Env] = true
+	}
+
+	var environ []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if !hidden[key] {
+			environ = append(environ, kv)
+		}
+	}
+	return environ
+}
+
+// runChild starts child, forwards signals to it until it exits and returns
+// its exit status as an exitCode. Ctrl-C is only caught: the terminal sends
+// it to the child too, and a second SIGINT makes some tools skip their
+// graceful shutdown.
+func runChild(child *exec.Cmd) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", child.Path, err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig != os.Interrupt {
+					child.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := child.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok && status.Signaled() {
+			// Report death by signal the way shells do
+			return exitCode(128 + int(status.Signal()))
+		}
+		return exitCode(exitErr.ExitCode())
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+)
+
+func TestChildEnviron(t *testing.T) {
+	t.Setenv("SECUREFLOW_PASSWORD", "default")
+	t.Setenv("APP_PASSWORD", "configured")
+	t.Setenv("FLAG_PASSWORD", "flagged")
+	t.Setenv("KEEP_ME", "kept")
+
+	tests := []struct {
+		name     string
+		flag     string
+		hidden   []string
+		expected []string
+	}{
+		{name: "ConfigEnv", hidden: []string{"SECUREFLOW_PASSWORD", "APP_PASSWORD"}, expected: []string{"FLAG_PASSWORD", "KEEP_ME"}},
+		// The config's variable is not consulted when a flag is given
+		{name: "PasswordEnvFlag", flag: "FLAG_PASSWORD", hidden: []string{"SECUREFLOW_PASSWORD", "FLAG_PASSWORD"}, expected: []string{"APP_PASSWORD", "KEEP_ME"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &passwordEnv, tt.flag)
+			cfg := &config.Config{Password: &config.PasswordConfig{Env: "APP_PASSWORD"}}
+
+			keys := make(map[string]bool)
+			for _, kv := range childEnviron(cfg) {
+				key, _, _ := strings.Cut(kv, "=")
+				keys[key] = true
+			}
+			for _, key := range tt.hidden {
+				if keys[key] {
+					t.Errorf("Expected %s to be removed from the child's environment", key)
+				}
+			}
+			for _, key := range tt.expected {
+				if !keys[key] {
+					t.Errorf("Expected %s to be passed to the child", key)
+				}
+			}
+		})
+	}
+}
+
+func TestRunChildExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		expected error
+	}{
+		{name: "Success", script: "exit 0", expected: nil},
+		{name: "ExitCode", script: "exit 3", expected: exitCode(3)},
+		// Death by signal is reported as 128 + the signal number
+		{name: "Killed", script: "kill -TERM $$", expected: exitCode(128 + int(syscall.SIGTERM))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := runChild(exec.Command("sh", "-c", tt.script)); err != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	err := runChild(exec.Command("secureflow-test-no-such-command"))
+	var code exitCode
+	if err == nil || errors.As(err, &code) {
+		t.Errorf("Expected a start error for a missing command, got %v", err)
+	}
+}
+
+func TestRunChildSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX signals")
+	}
+	t.Chdir(t.TempDir())
+
+	// The child records SIGINT and exits 7 on SIGTERM
+	child := exec.Command("sh", "-c", `trap 'echo > got-int' INT; trap 'exit 7' TERM; echo > ready; while :; do sleep 0.05; done`)
+	result := make(chan error, 1)
+	go func() { result <- runChild(child) }()
+
+	waitFor := func(path string) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			if _, err := os.Stat(path); err == nil {
+				return
+			}
+		}
+		t.Fatalf("Timed out waiting for %s", path)
+	}
+	waitFor("ready")
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("Failed to find own process: %v", err)
+	}
+	if err := self.Signal(syscall.SIGINT); err != nil {
+		t.Fatalf("Failed to send SIGINT: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := self.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("Failed to send SIGTERM: %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if err != exitCode(7) {
+			t.Errorf("Expected the child to exit 7 on the forwarded SIGTERM, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		child.Process.Kill()
+		t.Fatal("Timed out waiting for the child")
+	}
+	if _, err := os.Stat("got-int"); err == nil {
+		t.Error("Expected SIGINT not to be forwarded to the child")
+	}
+}
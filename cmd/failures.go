@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the commands that encrypt or decrypt many files
+var (
+	allowMissing    bool
+	failFast        bool
+	continueOnError bool
+)
+
+// allowMissingUsage is the help of --allow-missing, which verify has too
+const allowMissingUsage = "skip missing files as if every file were marked optional"
+
+// addFailureFlags registers --allow-missing, --fail-fast and
+// --continue-on-error on cmd
+func addFailureFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&allowMissing, "allow-missing", false, allowMissingUsage)
+	cmd.Flags().BoolVar(&failFast, "fail-fast", false, "stop at the first file that fails instead of processing the rest")
+	cmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "exit successfully when some files fail, as long as one file succeeds")
+	cmd.MarkFlagsMutuallyExclusive("fail-fast", "continue-on-error")
+}
+
+// checkResult lists the files that failed in an encrypt, decrypt or test run
+// in a summary table and returns the exit status for the most serious
+// failure; each file was reported as it failed. res may be nil when only
+// the error is known. Errors that are not about files pass through.
+func checkResult(cmd *cobra.Command, prefix string, res *secureflow.Result, err error) error {
+	failed := fileErrors(err)
+	if len(failed) == 0 {
+		return err
+	}
+
+	fmt.Println()
+	if res != nil {
+		summary := fmt.Sprintf("%d done, %d skipped, %d failed", res.Done, res.Skipped, len(failed))
+		if res.Stopped > 0 {
+			summary += fmt.Sprintf(", %d not processed (--fail-fast)", res.Stopped)
+		}
+		fmt.Printf("%s 📋 Summary: %s\n", utils.ColorBlue, summary)
+	}
+	for _, fileErr := range failed {
+		fmt.Printf("%s ❌ %-40s %v\n", utils.ColorRed, fileErr.Path, fileErr.Err)
+	}
+	fmt.Println()
+
+	if continueOnError && res != nil && res.Done > 0 {
+		fmt.Printf("%s ⚠️  Warning: %s for %d file(s), continuing (--continue-on-error)\n", utils.ColorYellow, prefix, len(failed))
+		return nil
+	}
+
+	fmt.Printf("%s ❌ %s for %d file(s)\n", utils.ColorRed, prefix, len(failed))
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return errorStatus(err)
+}
+
+// fileErrors returns the *secureflow.FileError of each file in err
+func fileErrors(err error) []*secureflow.FileError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var failed []*secureflow.FileError
+		for _, e := range joined.Unwrap() {
+			failed = append(failed, fileErrors(e)...)
+		}
+		return failed
+	}
+	var fileErr *secureflow.FileError
+	if errors.As(err, &fileErr) {
+		return []*secureflow.FileError{fileErr}
+	}
+	return nil
+}
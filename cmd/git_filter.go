@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/password"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
+	"github.com/spf13/cobra"
+)
+
+// gitFilterName names the filter and diff drivers in .git/config and the
+// managed block in .gitattributes
+const gitFilterName = "secureflow"
+
+var gitFilterCmd = &cobra.Command{
+	Use:   "git-filter",
+	Short: "Keep plaintext in the working tree while git stores only ciphertext",
+	Long: `Registers SecureFlow as a git clean/smudge filter for every configured
+input file, like git-crypt. Files are encrypted when staged and decrypted
+when checked out, so the working tree holds plaintext and commits hold
+ciphertext. git diff and git log -p show the decrypted text.
+
+Encryption in the filter is deterministic: the salt and nonce are derived
+from the password and the content, so an unchanged file encrypts to the
+same bytes and does not show as modified. This reveals when two versions
+of a file are identical, but nothing about their content.
+
+The filter never prompts: set SECUREFLOW_PASSWORD, or a password source in
+secureflow.yaml. Without a password, checkouts leave files encrypted and
+commits of changed files fail.`,
+}
+
+var gitFilterInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Register the filter in .git/config and .gitattributes",
+	Long: `Registers the clean, smudge and textconv drivers in .git/config and lists
+every configured input in a managed block of .gitattributes. Run it from
+the top of the repository, where secureflow.yaml is, and again after
+changing the files list. Glob and directory entries are listed as patterns,
+so new files under them are encrypted too.
+
+Files committed before the filter was installed stay in plaintext in git
+until they are staged again with git add --renormalize.`,
+	Args: cobra.NoArgs,
+	RunE: runGitFilterInstall,
+}
+
+var gitFilterUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the filter from .git/config and .gitattributes",
+	Args:  cobra.NoArgs,
+	RunE:  runGitFilterUninstall,
+}
+
+// The filter commands are run by git, which shows their errors; usage would
+// only add noise
+
+var gitFilterCleanCmd = &cobra.Command{
+	Use:          "clean <path>",
+	Short:        "Encrypt stdin to stdout (run by git when staging)",
+	Args:         cobra.ExactArgs(1),
+	Hidden:       true,
+	SilenceUsage: true,
+	RunE:         runGitFilterClean,
+}
+
+var gitFilterSmudgeCmd = &cobra.Command{
+	Use:          "smudge <path>",
+	Short:        "Decrypt stdin to stdout (run by git when checking out)",
+	Args:         cobra.ExactArgs(1),
+	Hidden:       true,
+	SilenceUsage: true,
+	RunE:         runGitFilterSmudge,
+}
+
+var gitFilterTextconvCmd = &cobra.Command{
+	Use:          "textconv <file>",
+	Short:        "Print a file decrypted (run by git diff)",
+	Args:         cobra.ExactArgs(1),
+	Hidden:       true,
+	SilenceUsage: true,
+	RunE:         runGitFilterTextconv,
+}
+
+func init() {
+	rootCmd.AddCommand(gitFilterCmd)
+	gitFilterCmd.AddCommand(gitFilterInstallCmd, gitFilterUninstallCmd,
+		gitFilterCleanCmd, gitFilterSmudgeCmd, gitFilterTextconvCmd)
+}
+
+func runGitFilterInstall(cmd *cobra.Command, args []string) error {
+	if err := checkRepoRoot(); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+	if len(opts.Recipients) > 0 {
+		return fmt.Errorf("the git filter needs password-based encryption; recipients cannot be used with it")
+	}
+
+	command, err := selfCommand()
+	if err != nil {
+		return err
+	}
+	if envName != "" {
+		command += " --env " + shellQuote(envName)
+	}
+
+	settings := [][2]string{
+		{"filter." + gitFilterName + ".clean", command + " git-filter clean %f"},
+		{"filter." + gitFilterName + ".smudge", command + " git-filter smudge %f"},
+		{"filter." + gitFilterName + ".required", "true"},
+		{"diff." + gitFilterName + ".textconv", command + " git-filter textconv"},
+	}
+	for _, s := range settings {
+		if out, err := exec.Command("git", "config", "--local", s[0], s[1]).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set %s: %s", s[0], strings.TrimSpace(string(out)))
+		}
+	}
+
+	patterns := gitFilterPatterns(cfg)
+	lines := make([]string, len(patterns))
+	for i, p := range patterns {
+		lines[i] = fmt.Sprintf("%s filter=%s diff=%s", p, gitFilterName, gitFilterName)
+	}
+	if err := setGitAttributes(lines); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s ✅ Registered the %s filter in .git/config\n", utils.ColorGreen, gitFilterName)
+	fmt.Printf("%s ✅ Listed %d path(s) in .gitattributes\n", utils.ColorGreen, len(patterns))
+	for _, f := range cfg.Files {
+		if exec.Command("git", "check-ignore", "-q", f.Input).Run() == nil {
+			fmt.Printf("%s ⚠️  %s is ignored by git; remove it from .gitignore to commit it encrypted\n", utils.ColorYellow, f.Input)
+		}
+	}
+	fmt.Printf("%s 💡 Commit .gitattributes, and run git add --renormalize . to encrypt files committed before\n", utils.ColorBlue)
+	return nil
+}
+
+func runGitFilterUninstall(cmd *cobra.Command, args []string) error {
+	if err := checkRepoRoot(); err != nil {
+		return err
+	}
+
+	for _, section := range []string{"filter." + gitFilterName, "diff." + gitFilterName} {
+		// Fails when the section is missing, which is fine
+		_ = exec.Command("git", "config", "--local", "--remove-section", section).Run()
+	}
+	if err := setGitAttributes(nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s ✅ Removed the %s filter from .git/config and .gitattributes\n", utils.ColorGreen, gitFilterName)
+	fmt.Printf("%s 💡 Files already committed stay encrypted in git until they are committed again\n", utils.ColorBlue)
+	return nil
+}
+
+func runGitFilterClean(cmd *cobra.Command, args []string) error {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if isEncrypted(input) {
+		// A file checked out without a password is still encrypted
+		_, err := os.Stdout.Write(input)
+		return err
+	}
+
+	cfg, opts, err := gitFilterOptions(args[0])
+	if err != nil {
+		return err
+	}
+	pwd, found, err := filterPassword(cfg)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("secureflow: cannot encrypt %s without a password; set %s", args[0], passwordSource(cfg).DefaultEnv)
+	}
+
+	if opts.SIVKey, err = crypto.DeriveSIVKey(pwd, opts); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := crypto.Encrypt(bytes.NewReader(input), &out, pwd, opts); err != nil {
+		return fmt.Errorf("secureflow: failed to encrypt %s: %w", args[0], err)
+	}
+	_, err = os.Stdout.Write(out.Bytes())
+	return err
+}
+
+func runGitFilterSmudge(cmd *cobra.Command, args []string) error {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if !isEncrypted(input) {
+		// Committed before the filter was installed
+		_, err := os.Stdout.Write(input)
+		return err
+	}
+
+	cfg, opts, err := gitFilterOptions(args[0])
+	if err != nil {
+		return err
+	}
+	pwd, found, err := filterPassword(cfg)
+	if err != nil {
+		return err
+	}
+	if !found && len(opts.Identities) == 0 {
+		fmt.Fprintf(os.Stderr, "secureflow: no password, leaving %s encrypted\n", args[0])
+		_, err := os.Stdout.Write(input)
+		return err
+	}
+
+	plaintext, err := decryptBytes(input, pwd, opts)
+	if err != nil {
+		return decryptionError(fmt.Sprintf("secureflow: failed to decrypt %s", args[0]), err)
+	}
+	_, err = os.Stdout.Write(plaintext)
+	return err
+}
+
+func runGitFilterTextconv(cmd *cobra.Command, args []string) error {
+	input, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+	if !isEncrypted(input) {
+		_, err := os.Stdout.Write(input)
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+	pwd, found, err := filterPassword(cfg)
+	if err != nil {
+		return err
+	}
+	if !found && len(opts.Identities) == 0 {
+		return fmt.Errorf("secureflow: cannot show the diff of an encrypted file without a password; set %s", passwordSource(cfg).DefaultEnv)
+	}
+
+	plaintext, err := decryptBytes(input, pwd, opts)
+	if err != nil {
+		return decryptionError("secureflow: failed to decrypt", err)
+	}
+	_, err = os.Stdout.Write(plaintext)
+	return err
+}
+
+// filterPassword resolves the password without prompting: git gives the
+// filter the file, not a terminal, on stdin
+func filterPassword(cfg *config.Config) (string, bool, error) {
+	if passwordStdin {
+		return "", false, fmt.Errorf("--password-stdin cannot be used by the git filter, which reads the file from stdin")
+	}
+	return password.Resolve(passwordSource(cfg), nil)
+}
+
+// gitFilterOptions loads the config and the encryption options for the file
+// at path, relative to the top of the repository
+func gitFilterOptions(path string) (*config.Config, crypto.Options, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, crypto.Options{}, err
+	}
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return nil, crypto.Options{}, err
+	}
+	if fileMapping, ok := findFileMapping(cfg, path); ok {
+		if opts, err = secureflow.FileOptions(opts, fileMapping, nil); err != nil {
+			return nil, crypto.Options{}, err
+		}
+	}
+	return cfg, opts, nil
+}
+
+// gitFilterPatterns returns the .gitattributes patterns for the configured
+// inputs: each file, and each glob or directory entry as a pattern
+func gitFilterPatterns(cfg *config.Config) []string {
+	var patterns []string
+	seen := make(map[string]bool)
+	for _, f := range cfg.Files {
+		p := filepath.ToSlash(filepath.Clean(f.Input))
+		if f.Pattern != "" {
+			p = filepath.ToSlash(filepath.Clean(f.Pattern))
+			if !strings.ContainsAny(f.Pattern, "*?[") {
+				p += "/**"
+			}
+		}
+		p = gitAttributesPath("/" + p)
+		if !seen[p] {
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// gitAttributesUnsafe matches the characters that must be escaped in a
+// .gitattributes pattern
+var gitAttributesUnsafe = regexp.MustCompile(`[\s"\\]`)
+
+// gitAttributesPath quotes a pattern that contains spaces, as git expects
+func gitAttributesPath(p string) string {
+	if !gitAttributesUnsafe.MatchString(p) {
+		return p
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\t", `\t`, "\n", `\n`)
+	return `"` + r.Replace(p) + `"`
+}
+
+// setGitAttributes replaces the managed block of .gitattributes with lines,
+// creating the file if needed and removing it again if it ends up empty
+func setGitAttributes(lines []string) error {
+	const path = ".gitattributes"
+	data, err := readIfExists(path)
+	if err != nil {
+		return err
+	}
+	updated := utils.SetBlock(data, gitFilterName+" git-filter", lines)
+	if bytes.Equal(updated, data) {
+		return nil
+	}
+	if len(updated) == 0 && data != nil {
+		return os.Remove(path)
+	}
+	return utils.WriteFileAtomic(path, updated, 0644)
+}
+
+// checkRepoRoot fails unless the working directory is the top of a git
+// working tree, where git runs filters and where paths in .gitattributes
+// are relative to
+func checkRepoRoot() error {
+	out, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+	if prefix := strings.TrimSpace(string(out)); prefix != "" {
+		return fmt.Errorf("run this from the top of the repository, not %s", prefix)
+	}
+	return nil
+}
+
+// gitFilterInstalled reports whether git-filter install has registered the
+// filter in this repository
+func gitFilterInstalled() bool {
+	return exec.Command("git", "config", "--local", "--get", "filter."+gitFilterName+".clean").Run() == nil
+}
+
+// isEncrypted reports whether data is in one of SecureFlow's formats
+func isEncrypted(data []byte) bool {
+	_, err := crypto.DetectFormat(data)
+	return err == nil || crypto.IsValuesDocument(data)
+}
+
+// selfCommand returns the shell command that runs this binary with the
+// current --config, for git to run later
+func selfCommand() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the secureflow binary: %w", err)
+	}
+	return shellQuote(exe) + " --config " + shellQuote(cfgFile), nil
+}
+
+// shellQuote quotes s for the shell git runs filter commands with
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`!*?[]{}()<>|&;#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
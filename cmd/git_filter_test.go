@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// runFilter runs a git-filter command on input the way git does, through
+// stdin and stdout, and returns its output
+func runFilter(t *testing.T, run func(*cobra.Command, []string) error, path string, input []byte) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	inPath, outPath := filepath.Join(dir, "stdin"), filepath.Join(dir, "stdout")
+	if err := os.WriteFile(inPath, input, 0600); err != nil {
+		t.Fatalf("Failed to write filter input: %v", err)
+	}
+	stdin, err := os.Open(inPath)
+	if err != nil {
+		t.Fatalf("Failed to open filter input: %v", err)
+	}
+	defer stdin.Close()
+	stdout, err := os.Create(outPath)
+	if err != nil {
+		t.Fatalf("Failed to create filter output: %v", err)
+	}
+	defer stdout.Close()
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, stdout
+	err = run(nil, []string{path})
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+	if err != nil {
+		t.Fatalf("Filter failed on %s: %v", path, err)
+	}
+
+	output, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("Failed to read filter output: %v", err)
+	}
+	return output
+}
+
+func TestGitFilterCleanSmudge(t *testing.T) {
+	plaintext := []byte("API_KEY=secret\n")
+
+	for _, format := range []string{"openssl", "aead"} {
+		t.Run(format, func(t *testing.T) {
+			gitRepo(t, map[string]string{
+				"secureflow.yaml": "format: " + format + "\noutput_dir: enc\nfiles:\n  - input: .env\n    output: env.encrypted\n",
+			})
+			setFlag(t, &cfgFile, "secureflow.yaml")
+			setFlag(t, &pbkdf2Iter, 1000)
+			t.Setenv("SECUREFLOW_PASSWORD", "password")
+
+			cleaned := runFilter(t, runGitFilterClean, ".env", plaintext)
+			if bytes.Contains(cleaned, []byte("secret")) || !isEncrypted(cleaned) {
+				t.Fatalf("Expected clean to encrypt, got %q", cleaned)
+			}
+			// Unchanged files must clean to the same bytes, or git status
+			// shows them as modified
+			if again := runFilter(t, runGitFilterClean, ".env", plaintext); !bytes.Equal(again, cleaned) {
+				t.Error("Expected clean to give the same bytes on every run")
+			}
+			if other := runFilter(t, runGitFilterClean, ".env", []byte("API_KEY=other\n")); bytes.Equal(other, cleaned) {
+				t.Error("Expected different content to clean to different bytes")
+			}
+			if twice := runFilter(t, runGitFilterClean, ".env", cleaned); !bytes.Equal(twice, cleaned) {
+				t.Error("Expected clean to leave encrypted input as it is")
+			}
+
+			if smudged := runFilter(t, runGitFilterSmudge, ".env", cleaned); !bytes.Equal(smudged, plaintext) {
+				t.Errorf("Expected smudge to decrypt to %q, got %q", plaintext, smudged)
+			}
+			// Files committed before the filter was installed
+			if smudged := runFilter(t, runGitFilterSmudge, ".env", plaintext); !bytes.Equal(smudged, plaintext) {
+				t.Errorf("Expected smudge to pass plaintext through, got %q", smudged)
+			}
+		})
+	}
+}
+
+func TestGitFilterPatterns(t *testing.T) {
+	cfg := &config.Config{Files: []config.FileMapping{
+		{Input: ".env"},
+		{Input: "./my secrets/.env prod"},
+		{Input: `odd"name\.env`},
+		{Input: "config/a.json", Pattern: "config/*.json"},
+		{Input: "config/b.json", Pattern: "config/*.json"},
+		{Input: "certs/a.pem", Pattern: "certs"},
+	}}
+
+	expected := []string{
+		"/.env",
+		`"/my secrets/.env prod"`,
+		`"/odd\"name\\.env"`,
+		"/config/*.json",
+		"/certs/**",
+	}
+	if got := gitFilterPatterns(cfg); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected patterns %q, got %q", expected, got)
+	}
+}
+
+func TestGitAttributesPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		expected string
+	}{
+		{"Plain", "/.env", "/.env"},
+		{"Space", "/my secrets/.env", `"/my secrets/.env"`},
+		{"Tab", "/a\tb", `"/a\tb"`},
+		{"Newline", "/a\nb", `"/a\nb"`},
+		{"Quote", `/a"b`, `"/a\"b"`},
+		{"Backslash", `/a\b`, `"/a\\b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gitAttributesPath(tt.pattern); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGitFilterAttributesMatch(t *testing.T) {
+	// git itself must read the quoted patterns back as the original paths
+	inputs := []string{"my secrets/.env prod", `odd"name.env`, "tab\there.env"}
+	gitRepo(t, nil)
+
+	cfg := &config.Config{}
+	for _, input := range inputs {
+		cfg.Files = append(cfg.Files, config.FileMapping{Input: input})
+	}
+	var lines []string
+	for _, p := range gitFilterPatterns(cfg) {
+		lines = append(lines, p+" filter="+gitFilterName)
+	}
+	if err := setGitAttributes(lines); err != nil {
+		t.Fatalf("Failed to write .gitattributes: %v", err)
+	}
+
+	for _, input := range inputs {
+		out, err := exec.Command("git", "check-attr", "-z", "filter", "--", input).Output()
+		if err != nil {
+			t.Fatalf("git check-attr failed: %v", err)
+		}
+		fields := strings.Split(string(out), "\x00")
+		if len(fields) < 3 || fields[2] != gitFilterName {
+			t.Errorf("Expected git to apply the filter to %q, got %q", input, out)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// gitignoreBlock names the managed block in .gitignore
+const gitignoreBlock = "secureflow"
+
+var gitignoreCmd = &cobra.Command{
+	Use:   "gitignore",
+	Short: "Manage the block of plaintext paths in .gitignore",
+}
+
+var gitignoreSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "List every plaintext path from secureflow.yaml in .gitignore",
+	Long: `Writes every input, copy_to destination and test_output_dir of every
+environment to a managed block of .gitignore in the current directory,
+between "# BEGIN secureflow" and "# END secureflow". The rest of the file
+is left alone, and the block is rewritten from the config each time, so
+entries removed from the config are removed from .gitignore too.
+
+init and encrypt update the block as well. Inputs are left out while
+secureflow git-filter is installed, since git then commits them encrypted.`,
+	Args: cobra.NoArgs,
+	RunE: runGitignoreSync,
+}
+
+func init() {
+	rootCmd.AddCommand(gitignoreCmd)
+	gitignoreCmd.AddCommand(gitignoreSyncCmd)
+}
+
+func runGitignoreSync(cmd *cobra.Command, args []string) error {
+	changed, err := syncGitignore()
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Printf("%s ✅ Updated the plaintext paths in .gitignore\n", utils.ColorGreen)
+	} else {
+		fmt.Printf("%s ✅ .gitignore is up to date\n", utils.ColorGreen)
+	}
+	return nil
+}
+
+// syncGitignore rewrites the managed block of .gitignore from the config
+// file, reporting whether anything changed
+func syncGitignore() (bool, error) {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return false, &configError{fmt.Errorf("failed to load config: %w", err)}
+	}
+	if err := cfg.Validate(); err != nil {
+		return false, &configError{fmt.Errorf("invalid config: %w", err)}
+	}
+	patterns, err := cfg.IgnorePatterns(!gitFilterInstalled())
+	if err != nil {
+		return false, &configError{fmt.Errorf("invalid config: %w", err)}
+	}
+
+	var lines []string
+	if len(patterns) > 0 {
+		lines = append([]string{"# Plaintext secrets from " + cfgFile + "; updated by secureflow gitignore sync"}, patterns...)
+	}
+
+	const path = ".gitignore"
+	data, err := readIfExists(path)
+	if err != nil {
+		return false, err
+	}
+	updated := utils.SetBlock(data, gitignoreBlock, lines)
+	if bytes.Equal(updated, data) {
+		return false, nil
+	}
+	if len(updated) == 0 && data != nil {
+		return true, os.Remove(path)
+	}
+	if err := utils.WriteFileAtomic(path, updated, 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// unignoredPaths returns the plaintext paths of cfg, an expanded
+// environment, that git's ignore rules do not cover. Outside a git
+// repository there is nothing to check.
+func unignoredPaths(cfg *config.Config) ([]string, error) {
+	if exec.Command("git", "rev-parse", "--is-inside-work-tree").Run() != nil {
+		return nil, nil
+	}
+
+	inputs := !gitFilterInstalled()
+	var paths []string
+	for _, f := range cfg.Files {
+		if inputs {
+			paths = append(paths, f.Input)
+		}
+		if f.CopyTo != "" {
+			paths = append(paths, f.CopyTo)
+		}
+	}
+	if cfg.TestOutputDir != "" {
+		paths = append(paths, strings.TrimSuffix(cfg.TestOutputDir, "/")+"/")
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	// Only the rules count, not whether a file happens to be tracked
+	check := exec.Command("git", "check-ignore", "--no-index", "-z", "--stdin")
+	check.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	out, err := check.Output()
+	// Exit status 1 means that none of the paths is ignored
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil, fmt.Errorf("failed to check .gitignore: %w", err)
+	}
+
+	ignored := make(map[string]bool)
+	for _, p := range strings.Split(string(out), "\x00") {
+		ignored[p] = true
+	}
+	var unignored []string
+	for _, p := range paths {
+		if !ignored[p] {
+			unignored = append(unignored, p)
+		}
+	}
+	return unignored, nil
+}
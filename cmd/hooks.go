@@ -0,0 +1,388 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
+	"github.com/spf13/cobra"
+)
+
+// hookBlock names the managed block in .git/hooks/pre-commit
+const hookBlock = "secureflow pre-commit"
+
+// reportNames are the files encrypt writes to output_dir besides the
+// encrypted files; they hold no secrets and are committed as they are
+var reportNames = map[string]bool{
+	manifest.FileName:         true,
+	secureflow.TextReportName: true,
+}
+
+// skipHookEnv, when set to 1, makes the pre-commit check pass without
+// checking anything
+const skipHookEnv = "SECUREFLOW_SKIP_HOOK"
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage the git pre-commit hook that blocks committing plaintext secrets",
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Add the SecureFlow check to .git/hooks/pre-commit",
+	Long: `Adds a pre-commit hook that fails the commit when:
+
+  - a staged file is a configured input, a copy_to destination, or inside
+    test_output_dir, so a plaintext secret would be committed
+  - a staged file in output_dir, other than manifest.json and report.txt,
+    or ending in .encrypted, is not in one of SecureFlow's encrypted formats
+  - an input has changed since it was last encrypted, as secureflow status
+    reports
+
+The files of the top level and of every environment are checked, whatever
+--env is. Inputs handled by secureflow git-filter are encrypted by git
+itself and are not checked. The check is added to an existing pre-commit
+hook as a managed block, and core.hooksPath is respected. Run it from the
+top of the repository, where secureflow.yaml is.
+
+To commit anyway, use git commit --no-verify, or set SECUREFLOW_SKIP_HOOK=1
+to skip only this check.`,
+	Args: cobra.NoArgs,
+	RunE: runHooksInstall,
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the SecureFlow check from .git/hooks/pre-commit",
+	Args:  cobra.NoArgs,
+	RunE:  runHooksUninstall,
+}
+
+var hooksPreCommitCmd = &cobra.Command{
+	Use:    "pre-commit",
+	Short:  "Check the staged changes (run by the pre-commit hook)",
+	Args:   cobra.NoArgs,
+	Hidden: true,
+	RunE:   runHooksPreCommit,
+	// The problems are listed above the error; usage would only bury them
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(hooksCmd)
+	hooksCmd.AddCommand(hooksInstallCmd, hooksUninstallCmd, hooksPreCommitCmd)
+}
+
+func runHooksInstall(cmd *cobra.Command, args []string) error {
+	if err := checkRepoRoot(); err != nil {
+		return err
+	}
+	if _, err := loadEnvironments(); err != nil {
+		return err
+	}
+
+	command, err := selfCommand()
+	if err != nil {
+		return err
+	}
+	hookPath, err := preCommitHookPath()
+	if err != nil {
+		return err
+	}
+
+	lines := []string{
+		"# Blocks commits of plaintext secrets; git commit --no-verify skips it",
+		command + " hooks pre-commit || exit 1",
+	}
+	if err := setPreCommitHook(hookPath, lines); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s ✅ Added the SecureFlow check to %s\n", utils.ColorGreen, hookPath)
+	return nil
+}
+
+func runHooksUninstall(cmd *cobra.Command, args []string) error {
+	if err := checkRepoRoot(); err != nil {
+		return err
+	}
+	hookPath, err := preCommitHookPath()
+	if err != nil {
+		return err
+	}
+	if err := setPreCommitHook(hookPath, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s ✅ Removed the SecureFlow check from %s\n", utils.ColorGreen, hookPath)
+	return nil
+}
+
+func runHooksPreCommit(cmd *cobra.Command, args []string) error {
+	if os.Getenv(skipHookEnv) == "1" {
+		fmt.Printf("%s ⚠️  %s=1, skipping the SecureFlow pre-commit check\n", utils.ColorYellow, skipHookEnv)
+		return nil
+	}
+
+	envs, err := loadEnvironments()
+	if err != nil {
+		return err
+	}
+	staged, err := stagedFiles()
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	report := func(format string, a ...interface{}) {
+		fmt.Printf("%s ❌ "+format+"\n", append([]interface{}{utils.ColorRed}, a...)...)
+		problems++
+	}
+
+	// Plaintext secrets and encrypted files that are not encrypted, in any
+	// environment
+	plaintext := make(map[string]string)
+	var outputDirs, testOutputDirs []string
+	for _, env := range envs {
+		for _, f := range env.cfg.Files {
+			if !env.filtered[slashPath(f.Input)] {
+				plaintext[slashPath(f.Input)] = "an input in " + cfgFile
+			}
+			if f.CopyTo != "" {
+				plaintext[slashPath(f.CopyTo)] = "a copy_to destination in " + cfgFile
+			}
+		}
+		outputDirs = append(outputDirs, slashPath(env.cfg.OutputDir))
+		if env.cfg.TestOutputDir != "" {
+			testOutputDirs = append(testOutputDirs, slashPath(env.cfg.TestOutputDir))
+		}
+	}
+	for _, p := range staged {
+		switch {
+		case plaintext[p] != "":
+			report("%s is %s; unstage it with git restore --staged %s", p, plaintext[p], shellQuote(p))
+		case isUnderAny(p, testOutputDirs):
+			report("%s is a decrypted test file; unstage it with git restore --staged %s", p, shellQuote(p))
+		case (isUnderAny(p, outputDirs) && !isReport(p, outputDirs)) || strings.HasSuffix(p, ".encrypted"):
+			data, err := exec.Command("git", "cat-file", "blob", ":"+p).Output()
+			if err != nil {
+				return fmt.Errorf("failed to read staged %s: %w", p, err)
+			}
+			if !isEncrypted(data) {
+				report("%s is staged but not encrypted; run secureflow encrypt and stage it again", p)
+			}
+		}
+	}
+
+	// Inputs changed since they were last encrypted
+	for _, env := range envs {
+		encryptCommand := "secureflow encrypt"
+		if env.name != "" {
+			encryptCommand += " --env " + shellQuote(env.name)
+		}
+
+		c, err := newStatusChecker(env.cfg, env.opts, false)
+		if errors.Is(err, crypto.ErrWrongPassword) {
+			// The password found belongs to another environment
+			fmt.Printf("%s ⚠️  %s: %v; not checking whether its inputs changed\n", utils.ColorYellow, env.label(), err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, f := range env.cfg.Files {
+			if env.filtered[slashPath(f.Input)] {
+				continue
+			}
+			switch state, err := c.check(f); state {
+			case stateUpToDate, statePlaintextMissing, stateUnknown:
+			case stateError:
+				report("%s: %v", f.Input, err)
+			default:
+				report("%s is %s; run %s", f.Input, state, encryptCommand)
+			}
+		}
+	}
+
+	if problems > 0 {
+		fmt.Println()
+		return fmt.Errorf("commit blocked by %d problem(s); to commit anyway, use git commit --no-verify or set %s=1", problems, skipHookEnv)
+	}
+	return nil
+}
+
+// hookEnvironment is one environment the pre-commit check covers
+type hookEnvironment struct {
+	name     string // "" for the top level
+	cfg      *config.Config
+	opts     crypto.Options
+	filtered map[string]bool
+}
+
+// label names the environment in messages
+func (e hookEnvironment) label() string {
+	if e.name == "" {
+		return "default"
+	}
+	return "environment " + e.name
+}
+
+// loadEnvironments loads the config once for the top level and once for
+// each environment, whatever --env is, so the hook protects the files of
+// all of them
+func loadEnvironments() ([]hookEnvironment, error) {
+	cfg, err := config.Load(cfgFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return nil, &configError{fmt.Errorf("failed to load config: %w", err)}
+	}
+
+	var envs []hookEnvironment
+	for _, name := range cfg.UsedEnvironments() {
+		env := hookEnvironment{name: name}
+		env.cfg, err = cfg.ForEnvironment(name)
+		if err == nil {
+			err = env.cfg.ExpandFiles()
+		}
+		if err != nil {
+			return nil, &configError{fmt.Errorf("%s: %w", env.label(), err)}
+		}
+		if env.opts, err = cryptoOptions(env.cfg); err != nil {
+			return nil, err
+		}
+		if env.filtered, err = gitFiltered(env.cfg); err != nil {
+			return nil, err
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+// stagedFiles lists the paths added or changed in the index, relative to the
+// top of the repository
+func stagedFiles() ([]string, error) {
+	out, err := exec.Command("git", "diff", "--cached", "--name-only", "-z", "--diff-filter=d").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged files: %w", err)
+	}
+	var files []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			files = append(files, p)
+		}
+	}
+	return files, nil
+}
+
+// gitFiltered returns the inputs that secureflow git-filter encrypts when
+// they are staged, which may be committed as they are
+func gitFiltered(cfg *config.Config) (map[string]bool, error) {
+	filtered := make(map[string]bool)
+	if len(cfg.Files) == 0 {
+		return filtered, nil
+	}
+
+	args := []string{"check-attr", "-z", "filter", "--"}
+	for _, f := range cfg.Files {
+		args = append(args, slashPath(f.Input))
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitattributes: %w", err)
+	}
+
+	// Each path is reported as path NUL attribute NUL value NUL
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if fields[i+2] == gitFilterName {
+			filtered[fields[i]] = true
+		}
+	}
+	return filtered, nil
+}
+
+// preCommitHookPath returns where git looks for the pre-commit hook
+func preCommitHookPath() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks/pre-commit").Output()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// setPreCommitHook replaces the managed block of the hook at hookPath with
+// lines, creating the hook if needed and removing it again if nothing else
+// is left in it
+func setPreCommitHook(hookPath string, lines []string) error {
+	const shebang = "#!/bin/sh\n"
+	data, err := readIfExists(hookPath)
+	if err != nil {
+		return err
+	}
+	if data == nil && len(lines) == 0 {
+		return nil
+	}
+
+	current := data
+	if current == nil {
+		current = []byte(shebang)
+	}
+	updated := utils.SetBlock(current, hookBlock, lines)
+	if bytes.Equal(updated, data) {
+		return nil
+	}
+	if len(lines) == 0 && strings.TrimSpace(string(updated)) == strings.TrimSpace(shebang) {
+		return os.Remove(hookPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	return utils.WriteFileAtomic(hookPath, updated, 0755)
+}
+
+// slashPath cleans a path from the config into the form git prints
+func slashPath(p string) string {
+	return filepath.ToSlash(filepath.Clean(p))
+}
+
+// isUnder reports whether the slash-separated path p is inside dir
+func isUnder(p, dir string) bool {
+	return dir != "." && strings.HasPrefix(p, dir+"/")
+}
+
+// isReport reports whether the slash-separated path p is a report encrypt
+// wrote directly inside one of the output dirs
+func isReport(p string, outputDirs []string) bool {
+	if !reportNames[path.Base(p)] {
+		return false
+	}
+	for _, dir := range outputDirs {
+		if path.Dir(p) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnderAny reports whether the slash-separated path p is inside one of dirs
+func isUnderAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isUnder(p, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
+)
+
+// gitRepo makes the working directory a new git repository holding files
+func gitRepo(t *testing.T, files map[string]string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to init git repository: %v: %s", err, out)
+	}
+	writeFiles(t, files)
+}
+
+// gitAdd stages paths
+func gitAdd(t *testing.T, paths ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", append([]string{"add", "--"}, paths...)...).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to stage %v: %v: %s", paths, err, out)
+	}
+}
+
+func TestHooksCheckEveryEnvironment(t *testing.T) {
+	gitRepo(t, map[string]string{
+		"secureflow.yaml": `environments:
+  prod:
+    output_dir: secrets/prod
+    files:
+      - input: .env.prod
+        output: .env.prod.encrypted
+  staging:
+    output_dir: secrets/staging
+    test_output_dir: test_dec
+    files:
+      - input: .env.staging
+        output: .env.staging.encrypted
+`,
+		".env.staging":                    "API_KEY=staging\n",
+		"secrets/staging/notes.txt":       "not encrypted\n",
+		"test_dec/.env.staging.encrypted": "API_KEY=staging\n",
+	})
+	setFlag(t, &cfgFile, "secureflow.yaml")
+	setFlag(t, &envName, "prod")
+
+	t.Run("PreCommit", func(t *testing.T) {
+		for _, p := range []string{".env.staging", "secrets/staging/notes.txt", "test_dec/.env.staging.encrypted"} {
+			gitAdd(t, p)
+			// Only the staged copy is left, so nothing looks out of date
+			if err := os.Remove(p); err != nil {
+				t.Fatalf("Failed to remove %s: %v", p, err)
+			}
+			err := runHooksPreCommit(hooksPreCommitCmd, nil)
+			if err == nil || !strings.Contains(err.Error(), "commit blocked") {
+				t.Errorf("Expected staging %s to block the commit with --env prod, got %v", p, err)
+			}
+			if out, err := exec.Command("git", "rm", "-q", "--cached", "--", p).CombinedOutput(); err != nil {
+				t.Fatalf("Failed to unstage %s: %v: %s", p, err, out)
+			}
+		}
+	})
+
+	t.Run("Install", func(t *testing.T) {
+		if err := runHooksInstall(hooksInstallCmd, nil); err != nil {
+			t.Fatalf("hooks install failed: %v", err)
+		}
+		hookPath, err := preCommitHookPath()
+		if err != nil {
+			t.Fatalf("Failed to find the hook: %v", err)
+		}
+		hook, err := os.ReadFile(hookPath)
+		if err != nil {
+			t.Fatalf("Failed to read the hook: %v", err)
+		}
+		if !strings.Contains(string(hook), " hooks pre-commit") {
+			t.Errorf("Expected the hook to run the check, got:\n%s", hook)
+		}
+		if strings.Contains(string(hook), "--env") {
+			t.Errorf("Expected the hook not to select an environment, got:\n%s", hook)
+		}
+	})
+}
+
+func TestHooksAllowReports(t *testing.T) {
+	for _, report := range []secureflow.ReportFormat{secureflow.ReportJSON, secureflow.ReportText} {
+		t.Run(string(report), func(t *testing.T) {
+			gitRepo(t, map[string]string{
+				"secureflow.yaml": "output_dir: enc\nfiles:\n  - input: .env\n    output: env.encrypted\n",
+				".env":            "API_KEY=secret\n",
+			})
+			setFlag(t, &cfgFile, "secureflow.yaml")
+
+			p, err := secureflow.NewProject("secureflow.yaml", "")
+			if err != nil {
+				t.Fatalf("NewProject failed: %v", err)
+			}
+			p.Password = "password"
+			p.Options.PBKDF2Iter = 1000
+			if _, err := p.Encrypt(secureflow.EncryptOptions{Report: report}); err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			gitAdd(t, "enc")
+
+			if err := runHooksPreCommit(hooksPreCommitCmd, nil); err != nil {
+				t.Errorf("Expected the encrypted files and %s report to be committable, got %v", report, err)
+			}
+		})
+	}
+}
@@ -121,6 +121,12 @@ func runInit(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("%s ✅ Created %s\n", utils.ColorGreen, cfgFile)
+
+	if changed, err := syncGitignore(); err != nil {
+		fmt.Printf("%s ⚠️  Warning: Could not update .gitignore: %v\n", utils.ColorYellow, err)
+	} else if changed {
+		fmt.Printf("%s ✅ Added the plaintext paths to .gitignore\n", utils.ColorGreen)
+	}
 	fmt.Println("\nYou can now edit this file to match your project structure.")
 	fmt.Println("Then run: secureflow encrypt")
 
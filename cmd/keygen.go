@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a keypair for recipient-based encryption",
+	Long: `Generates an X25519 keypair. The private key (identity) is written to a
+file readable only by you; the public key (recipient) is printed so it can
+be added to the "recipients" list in secureflow.yaml.
+
+Files encrypted to recipients are decrypted with:
+  secureflow decrypt --identity key.txt
+
+Removing someone's public key from "recipients" and re-running encrypt
+revokes their access without changing anyone else's key.`,
+	RunE: runKeygen,
+}
+
+var keygenOutput string
+
+func init() {
+	rootCmd.AddCommand(keygenCmd)
+	keygenCmd.Flags().StringVarP(&keygenOutput, "output", "o", "secureflow-key.txt", "file to write the private key to")
+}
+
+func runKeygen(cmd *cobra.Command, args []string) error {
+	if utils.FileExists(keygenOutput) {
+		return fmt.Errorf("key file already exists: %s", keygenOutput)
+	}
+
+	identity, err := crypto.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+	recipient := identity.Recipient()
+
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), recipient, identity)
+
+	// O_EXCL guards against racing with another process creating the file
+	file, err := os.OpenFile(keygenOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	fmt.Printf("%s ✅ Private key saved to %s\n", utils.ColorGreen, keygenOutput)
+	fmt.Printf("%s ⚠️  Keep it secret and never commit it to git\n\n", utils.ColorYellow)
+	fmt.Printf("%s🔑 Public key:%s %s\n", utils.ColorBlue, utils.ColorReset, recipient)
+	fmt.Println("\nAdd the public key to secureflow.yaml:")
+	fmt.Println("  recipients:")
+	fmt.Printf("    - %s\n", recipient)
+
+	return nil
+}
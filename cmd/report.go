@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+)
+
+// loadManifest reads the manifest from the output directory and derives its
+// plaintext HMAC key. Both are nil when there is no manifest.
+func loadManifest(cfg *config.Config, pwd string) (*manifest.Manifest, []byte, error) {
+	m, err := manifest.Load(cfg.OutputDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := m.Key(pwd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Printf("%s 🧾 Validating against %s\n", utils.ColorBlue, manifest.Path(cfg.OutputDir))
+	return m, key, nil
+}
+
+// updateManifestEntry records a file re-encrypted from plaintext held in
+// memory. It does nothing when there is no manifest.
+func updateManifestEntry(cfg *config.Config, m *manifest.Manifest, key []byte, fileMapping config.FileMapping, plaintext, ciphertext []byte) error {
+	if m == nil {
+		return nil
+	}
+
+	entry := manifest.Entry{
+		Input:            fileMapping.Input,
+		Output:           fileMapping.Output,
+		Size:             int64(len(plaintext)),
+		Lines:            countLines(plaintext),
+		ModifiedAt:       time.Now().UTC(),
+		CiphertextSHA256: manifest.SHA256(ciphertext),
+	}
+	if key != nil {
+		entry.PlaintextHMAC = manifest.HMAC(key, plaintext)
+		entry.CiphertextHMAC = manifest.HMAC(key, ciphertext)
+	}
+	if old := m.Entry(fileMapping.Output); old != nil {
+		// Editing changes the content, not who may read the file
+		entry.Mode = old.Mode
+	}
+	m.Set(entry)
+
+	return m.Save(cfg.OutputDir)
+}
+
+// countLines counts lines the way utils.GetFileInfo does
+func countLines(data []byte) int {
+	lines := bytes.Count(data, []byte{'\n'})
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/password"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
 	"github.com/spf13/cobra"
 )
 
@@ -12,9 +20,16 @@ var (
 	Version = "1.2.0"
 
 	// Global flags
-	cfgFile        string
-	nonInteractive bool
-	password       string
+	cfgFile         string
+	envName         string
+	nonInteractive  bool
+	passwordFlag    string
+	passwordEnv     string
+	passwordFile    string
+	passwordStdin   bool
+	passwordCommand string
+	pbkdf2Iter      int
+	identityFiles   []string
 )
 
 // rootCmd represents the base command
@@ -23,21 +38,219 @@ var rootCmd = &cobra.Command{
 	Short: "SecureFlow - Secure file encryption/decryption CLI",
 	Long: `SecureFlow is a lightweight, Go-based CLI for securely encrypting 
 and decrypting sensitive files like environment variables, keystores, 
-and service credentials for local and CI/CD use.`,
+and service credentials for local and CI/CD use.
+
+Exit status:
+  0  success
+  1  any other error
+  2  a file is missing
+  3  wrong password or identity
+  4  a file is corrupted or has been tampered with
+  5  a file could not be read or written
+  6  the config file is missing or invalid
+
+When several files fail, the highest status is returned.`,
 	Version: Version,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(int(errorStatus(err)))
+	}
+}
+
+// exitCode is returned by commands that exit with a specific status and have
+// already reported why
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// Exit statuses that tell failures apart; a higher status is a more serious
+// failure
+const (
+	exitFailure       exitCode = 1 // any other error
+	exitMissing       exitCode = 2 // a file is missing
+	exitWrongPassword exitCode = 3 // wrong password or identity
+	exitCorrupted     exitCode = 4 // a file is corrupted or has been tampered with
+	exitIO            exitCode = 5 // a file could not be read or written
+	exitConfig        exitCode = 6 // the config file is missing or invalid
+)
+
+// errorStatus picks the exit status for err. Errors joined together, such as
+// one per failed file, exit with the highest status among them.
+func errorStatus(err error) exitCode {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		status := exitFailure
+		for _, e := range joined.Unwrap() {
+			status = max(status, errorStatus(e))
+		}
+		return status
 	}
+
+	var cfgErr *configError
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &cfgErr):
+		return exitConfig
+	case errors.Is(err, secureflow.ErrMissing), errors.Is(err, fs.ErrNotExist):
+		return exitMissing
+	case errors.Is(err, crypto.ErrWrongPassword), errors.Is(err, crypto.ErrNoIdentity), errors.Is(err, crypto.ErrDecryptionFailed):
+		return exitWrongPassword
+	case errors.Is(err, crypto.ErrCorrupted), errors.Is(err, crypto.ErrInvalidFormat), errors.Is(err, manifest.ErrMismatch):
+		return exitCorrupted
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		return exitIO
+	default:
+		return exitFailure
+	}
+}
+
+// configError marks an error in the config file
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
 }
 
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "secureflow.yaml", "config file path")
+	rootCmd.PersistentFlags().StringVar(&envName, "env", "", "environment from the config's environments section to use")
 	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "run in non-interactive mode")
-	rootCmd.PersistentFlags().StringVar(&password, "password", "", "encryption/decryption password (for non-interactive mode)")
+	rootCmd.PersistentFlags().StringVar(&passwordFlag, "password", "", "encryption/decryption password (visible in process listings; prefer the options below)")
+	rootCmd.PersistentFlags().StringVar(&passwordEnv, "password-env", "", "read the password from this environment variable")
+	rootCmd.PersistentFlags().StringVar(&passwordFile, "password-file", "", "read the password from the first line of this file")
+	rootCmd.PersistentFlags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from the first line of stdin")
+	rootCmd.PersistentFlags().StringVar(&passwordCommand, "password-command", "", "run this shell command and use the first line of its output as the password")
+	rootCmd.PersistentFlags().StringArrayVar(&identityFiles, "identity", nil, "identity (private key) file for files encrypted to recipients; repeatable")
+	rootCmd.PersistentFlags().IntVar(&pbkdf2Iter, "pbkdf2-iter", 0, "PBKDF2 iterations for the openssl format, like openssl enc -iter N (default 10000)")
+}
+
+// jobs is the --jobs flag of the commands that process many files
+var jobs int
+
+// addJobsFlag adds --jobs, which sets how many files are processed at once
+func addJobsFlag(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&jobs, "jobs", 0, "number of files to encrypt or decrypt at once (default: one per CPU)")
+}
+
+// checkJobs rejects a --jobs value that cannot be a number of workers
+func checkJobs() error {
+	if jobs < 0 {
+		return fmt.Errorf("--jobs must be 0 (one per CPU) or more, got %d", jobs)
+	}
+	return nil
+}
+
+// resolvePassword returns the password from the first configured source,
+// in the precedence order documented on password.Source, and falls back to
+// an interactive prompt
+func resolvePassword(cfg *config.Config, prompt string) (string, error) {
+	src := passwordSource(cfg)
+	pwd, found, err := password.Resolve(src, os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return pwd, nil
+	}
+
+	if nonInteractive {
+		return "", fmt.Errorf("password required in non-interactive mode (use --password-env, --password-file, --password-stdin, --password-command or set %s)", src.DefaultEnv)
+	}
+
+	pwd, err = utils.ReadPassword(utils.Colorize(utils.ColorBlue, prompt))
+	if err != nil {
+		return "", err
+	}
+	if pwd == "" {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+	return pwd, nil
+}
+
+// loadConfig reads and validates the config file, selects the --env
+// environment and expands its glob and directory entries
+func loadConfig() (*config.Config, error) {
+	cfg, err := secureflow.LoadConfig(cfgFile, envName)
+	if err != nil {
+		return nil, &configError{err}
+	}
+	return cfg, nil
+}
+
+// passwordSource collects the password flags. Without any, the config's
+// password source is used; its environment variable takes the place of
+// SECUREFLOW_PASSWORD, so a prompt is still offered when it is unset.
+func passwordSource(cfg *config.Config) password.Source {
+	src := password.Source{
+		Value:      passwordFlag,
+		Env:        passwordEnv,
+		File:       passwordFile,
+		Stdin:      passwordStdin,
+		Command:    passwordCommand,
+		DefaultEnv: password.DefaultEnv,
+	}
+	flagged := src.Value != "" || src.Env != "" || src.File != "" || src.Stdin || src.Command != ""
+	if !flagged && cfg.Password != nil {
+		src.File = cfg.Password.File
+		src.Command = cfg.Password.Command
+		if cfg.Password.Env != "" {
+			src.DefaultEnv = cfg.Password.Env
+		}
+	}
+	return src
+}
+
+// cryptoOptions builds the encryption options from the config and global flags
+func cryptoOptions(cfg *config.Config) (crypto.Options, error) {
+	opts, err := secureflow.OptionsFromConfig(cfg)
+	if err != nil {
+		return crypto.Options{}, &configError{err}
+	}
+	opts.PBKDF2Iter = pbkdf2Iter
+
+	for _, path := range identityFiles {
+		identities, err := loadIdentities(path)
+		if err != nil {
+			return crypto.Options{}, err
+		}
+		opts.Identities = append(opts.Identities, identities...)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return crypto.Options{}, &configError{fmt.Errorf("invalid config: %w", err)}
+	}
+
+	return opts, nil
+}
+
+// loadIdentities reads the private keys from an identity file
+func loadIdentities(path string) ([]*crypto.Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+	defer file.Close()
+
+	identities, err := crypto.ParseIdentities(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file %s: %w", path, err)
+	}
+	return identities, nil
 }
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/password"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
+	"github.com/spf13/cobra"
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt all files under a new password",
+	Long: `Re-encrypts every file listed in secureflow.yaml under a new password
+without writing plaintext to disk.
+
+Each encrypted file in the output directory is decrypted in memory with the
+current password and re-encrypted with the new one, using the format and
+key derivation currently configured. The current password, and each file
+and its decrypted content, are checked against manifest.json first. If any
+file fails to decrypt or does not match, nothing is changed. Otherwise each
+encrypted file is replaced atomically.
+
+A missing encrypted file also aborts the rotation, with exit status 2,
+unless its entry is marked "optional: true" or --allow-missing is given.
+
+When "recipients" are configured, files are re-encrypted to the current
+recipient list instead, which revokes access for removed recipients.`,
+	RunE: runRotate,
+}
+
+var (
+	newPassword     string
+	newPasswordEnv  string
+	newPasswordFile string
+)
+
+func init() {
+	rootCmd.AddCommand(rotateCmd)
+	rotateCmd.Flags().StringVar(&newPassword, "new-password", "", "new encryption password (visible in process listings; prefer the options below)")
+	rotateCmd.Flags().StringVar(&newPasswordEnv, "new-password-env", "", "read the new password from this environment variable")
+	rotateCmd.Flags().StringVar(&newPasswordFile, "new-password-file", "", "read the new password from the first line of this file")
+	rotateCmd.Flags().BoolVar(&allowMissing, "allow-missing", false, allowMissingUsage)
+}
+
+// rotation is one encrypted file staged for replacement
+type rotation struct {
+	fileMapping config.FileMapping
+	path        string
+	ciphertext  []byte
+	fingerprint string
+	tmpPath     string
+}
+
+func runRotate(cmd *cobra.Command, args []string) error {
+	// Load config
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Get current password (not needed when decrypting with identities)
+	var oldPwd string
+	if len(opts.Identities) > 0 {
+		fmt.Printf("%s 🔑 Using %d identity key(s)\n", utils.ColorBlue, len(opts.Identities))
+	} else if oldPwd, err = resolvePassword(cfg, "🔐 Enter current password: "); err != nil {
+		return err
+	}
+
+	// Get new password (not needed when encrypting to recipients)
+	var newPwd string
+	if len(opts.Recipients) > 0 {
+		fmt.Printf("%s 🔑 Re-encrypting to %d recipient(s)\n", utils.ColorBlue, len(opts.Recipients))
+	} else if newPwd, err = resolveNewPassword(); err != nil {
+		return err
+	}
+
+	// The manifest's plaintext fingerprints are keyed by the password, so
+	// they check the current password and are then recomputed under a
+	// fresh salt for the new one
+	m, err := manifest.Load(cfg.OutputDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var oldKey, key []byte
+	keyChecked := false
+	if m != nil {
+		if oldKey, err = m.Key(oldPwd); err != nil {
+			return decryptionError("rotation aborted, no files were changed", err)
+		}
+		keyChecked = oldKey != nil && m.HasKeyCheck()
+
+		m.HMACSalt = ""
+		m.SetKeyCheck(nil)
+		if len(opts.Recipients) == 0 {
+			if err := m.NewSalt(); err != nil {
+				return err
+			}
+			if key, err = m.Key(newPwd); err != nil {
+				return err
+			}
+			m.SetKeyCheck(key)
+		}
+		m.Format = string(opts.Format)
+		m.KDF = opts.KDFSummary()
+		m.Recipients = len(opts.Recipients)
+	}
+
+	fmt.Println()
+	fmt.Printf("%s 🔄 Decrypting with current password...\n\n", utils.ColorYellow)
+
+	// Decrypt and re-encrypt everything in memory before touching any file
+	var rotations []*rotation
+	for _, fileMapping := range cfg.Files {
+		encryptedPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
+
+		if !utils.FileExists(encryptedPath) {
+			if fileMapping.Optional || allowMissing {
+				fmt.Printf("%s ⚠️  Warning: %s not found, skipping\n", utils.ColorYellow, encryptedPath)
+				continue
+			}
+			fmt.Printf("%s ❌ %s not found\n\n", utils.ColorRed, encryptedPath)
+			return fmt.Errorf("rotation aborted, no files were changed: %s: %w", encryptedPath, secureflow.ErrMissing)
+		}
+
+		// Rotation gives every value a new salt, so nothing is kept
+		fileOpts, err := secureflow.FileOptions(opts, fileMapping, nil)
+		if err != nil {
+			return err
+		}
+		ciphertext, fingerprint, err := reencrypt(encryptedPath, m.Entry(fileMapping.Output), oldPwd, newPwd, fileOpts, oldKey, keyChecked, key)
+		if err != nil {
+			fmt.Printf("%s ❌ Failed to decrypt %s: %v\n\n", utils.ColorRed, encryptedPath, err)
+			return decryptionError("rotation aborted, no files were changed", err)
+		}
+
+		fmt.Printf("%s ✅ %s\n", utils.ColorGreen, encryptedPath)
+		rotations = append(rotations, &rotation{
+			fileMapping: fileMapping,
+			path:        encryptedPath,
+			ciphertext:  ciphertext,
+			fingerprint: fingerprint,
+		})
+	}
+
+	if len(rotations) == 0 {
+		return fmt.Errorf("no files were rotated")
+	}
+
+	// Stage every replacement next to its target, then swap them in
+	for _, r := range rotations {
+		ciphertext := r.ciphertext
+		r.tmpPath, err = utils.StageFile(r.path, 0644, func(w io.Writer) error {
+			_, err := w.Write(ciphertext)
+			return err
+		})
+		if err != nil {
+			for _, staged := range rotations {
+				if staged.tmpPath != "" {
+					os.Remove(staged.tmpPath)
+				}
+			}
+			return fmt.Errorf("rotation aborted, no files were changed: %w", err)
+		}
+	}
+
+	for i, r := range rotations {
+		if err := utils.ReplaceFile(r.tmpPath, r.path); err != nil {
+			for _, pending := range rotations[i:] {
+				os.Remove(pending.tmpPath)
+			}
+			return fmt.Errorf("failed to replace %s after rotating %d file(s): %w", r.path, i, err)
+		}
+	}
+
+	if m != nil {
+		for _, r := range rotations {
+			if entry := m.Entry(r.fileMapping.Output); entry != nil {
+				entry.CiphertextSHA256 = manifest.SHA256(r.ciphertext)
+				entry.CiphertextHMAC = ""
+				if key != nil {
+					entry.CiphertextHMAC = manifest.HMAC(key, r.ciphertext)
+				}
+				entry.PlaintextHMAC = r.fingerprint
+			}
+		}
+		if err := m.Save(cfg.OutputDir); err != nil {
+			return fmt.Errorf("files were rotated but the manifest was not updated: %w", err)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("%s 🎉 Rotation complete! (%d file(s))\n", utils.ColorGreen, len(rotations))
+	if len(opts.Recipients) == 0 {
+		fmt.Println("Remember to update the password in your CI/CD secrets.")
+	}
+
+	return nil
+}
+
+// resolveNewPassword returns the new password from its flags, or prompts
+// for it twice
+func resolveNewPassword() (string, error) {
+	src := password.Source{Value: newPassword, Env: newPasswordEnv, File: newPasswordFile}
+	pwd, found, err := password.Resolve(src, os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return pwd, nil
+	}
+
+	if nonInteractive {
+		return "", fmt.Errorf("new password required in non-interactive mode (use --new-password-env or --new-password-file)")
+	}
+
+	pwd, err = utils.ReadPassword(utils.Colorize(utils.ColorBlue, "🔐 Enter new password: "))
+	if err != nil {
+		return "", err
+	}
+	if pwd == "" {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+	confirm, err := utils.ReadPassword(utils.Colorize(utils.ColorBlue, "🔐 Confirm new password: "))
+	if err != nil {
+		return "", err
+	}
+	if confirm != pwd {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return pwd, nil
+}
+
+// reencrypt decrypts the file at path and encrypts the plaintext again,
+// keeping both in memory. When the manifest has an entry for the file, the
+// ciphertext and, under oldKey, the plaintext must match it, since an
+// openssl file can decrypt to garbage under a wrong password. keyChecked
+// means the manifest confirmed oldKey. When key is set it also returns the
+// plaintext fingerprint for the manifest.
+func reencrypt(path string, entry *manifest.Entry, oldPwd, newPwd string, opts crypto.Options, oldKey []byte, keyChecked bool, key []byte) ([]byte, string, error) {
+	if entry != nil {
+		if err := entry.VerifyCiphertext(path); err != nil {
+			return nil, "", err
+		}
+		if err := entry.AuthenticateCiphertext(oldKey, path); err != nil {
+			if !keyChecked && errors.Is(err, manifest.ErrMismatch) {
+				// The checksum matched, so the key is the likelier culprit
+				return nil, "", fmt.Errorf("%w: %s fingerprint does not match", crypto.ErrWrongPassword, manifest.FileName)
+			}
+			return nil, "", err
+		}
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+	defer in.Close()
+
+	var plaintext bytes.Buffer
+	if err := crypto.Decrypt(in, &plaintext, oldPwd, opts); err != nil {
+		return nil, "", err
+	}
+	if entry != nil && oldKey != nil && entry.PlaintextHMAC != "" {
+		if entry.CheckPlaintext(manifest.HMAC(oldKey, plaintext.Bytes())) != nil {
+			// The ciphertext is the one recorded, so the password is wrong
+			return nil, "", fmt.Errorf("%w: decrypted content does not match %s", crypto.ErrWrongPassword, manifest.FileName)
+		}
+	}
+
+	var fingerprint string
+	if key != nil {
+		fingerprint = manifest.HMAC(key, plaintext.Bytes())
+	}
+
+	var ciphertext bytes.Buffer
+	if err := crypto.Encrypt(&plaintext, &ciphertext, newPwd, opts); err != nil {
+		return nil, "", err
+	}
+
+	return ciphertext.Bytes(), fingerprint, nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
+)
+
+// setFlag sets a command-line flag variable for the duration of a test
+func setFlag[T any](t *testing.T, flag *T, value T) {
+	t.Helper()
+	old := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = old })
+}
+
+// writeFiles writes files, keyed by path, under the working directory
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+// encryptProject encrypts the project configured in secureflow.yaml with
+// pwd, using few PBKDF2 iterations to keep tests fast
+func encryptProject(t *testing.T, pwd string) *secureflow.Project {
+	t.Helper()
+	p, err := secureflow.NewProject("secureflow.yaml", "")
+	if err != nil {
+		t.Fatalf("NewProject failed: %v", err)
+	}
+	p.Password = pwd
+	p.Options.PBKDF2Iter = 1000
+	if _, err := p.Encrypt(secureflow.EncryptOptions{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	return p
+}
+
+func TestRotateWrongPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyCheck bool
+	}{
+		{name: "ManifestKeyCheck", keyCheck: true},
+		// Older manifests only have per-file fingerprints
+		{name: "FileFingerprints", keyCheck: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			writeFiles(t, map[string]string{
+				"secureflow.yaml": "output_dir: enc\ntest_output_dir: test_dec\nfiles:\n  - input: .env\n    output: env.encrypted\n",
+				".env":            "API_KEY=secret\n",
+			})
+			setFlag(t, &cfgFile, "secureflow.yaml")
+			setFlag(t, &nonInteractive, true)
+			setFlag(t, &pbkdf2Iter, 1000)
+
+			p, err := secureflow.NewProject("secureflow.yaml", "")
+			if err != nil {
+				t.Fatalf("NewProject failed: %v", err)
+			}
+			p.Password = "right"
+			p.Options.PBKDF2Iter = pbkdf2Iter
+			if _, err := p.Encrypt(secureflow.EncryptOptions{}); err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			if !tt.keyCheck {
+				m, err := manifest.Load("enc")
+				if err != nil {
+					t.Fatalf("Failed to load manifest: %v", err)
+				}
+				m.SetKeyCheck(nil)
+				if err := m.Save("enc"); err != nil {
+					t.Fatalf("Failed to save manifest: %v", err)
+				}
+			}
+
+			encryptedPath := filepath.Join("enc", "env.encrypted")
+			ciphertext, err := os.ReadFile(encryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", encryptedPath, err)
+			}
+			manifestData, err := os.ReadFile(manifest.Path("enc"))
+			if err != nil {
+				t.Fatalf("Failed to read manifest: %v", err)
+			}
+
+			// A wrong password the openssl format's padding check lets through
+			wrong := ""
+			for i := 0; wrong == ""; i++ {
+				candidate := fmt.Sprintf("wrong%d", i)
+				if crypto.Decrypt(bytes.NewReader(ciphertext), io.Discard, candidate, p.Options) == nil {
+					wrong = candidate
+				}
+			}
+			setFlag(t, &passwordFlag, wrong)
+			setFlag(t, &newPassword, "new")
+
+			if err := runRotate(rotateCmd, nil); !errors.Is(err, crypto.ErrWrongPassword) {
+				t.Errorf("Expected ErrWrongPassword, got %v", err)
+			}
+
+			if got, _ := os.ReadFile(encryptedPath); !bytes.Equal(got, ciphertext) {
+				t.Error("Expected the encrypted file to be unchanged")
+			}
+			if got, _ := os.ReadFile(manifest.Path("enc")); !bytes.Equal(got, manifestData) {
+				t.Error("Expected the manifest to be unchanged")
+			}
+		})
+	}
+}
+
+func TestRotateMissingFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{
+		"secureflow.yaml": "output_dir: enc\ntest_output_dir: test_dec\nfiles:\n" +
+			"  - input: .env\n    output: env.encrypted\n" +
+			"  - input: missing.txt\n    output: missing.txt.encrypted\n",
+		".env": "API_KEY=secret\n",
+	})
+	setFlag(t, &cfgFile, "secureflow.yaml")
+	setFlag(t, &nonInteractive, true)
+	setFlag(t, &pbkdf2Iter, 1000)
+	setFlag(t, &passwordFlag, "old")
+	setFlag(t, &newPassword, "new")
+
+	p, err := secureflow.NewProject("secureflow.yaml", "")
+	if err != nil {
+		t.Fatalf("NewProject failed: %v", err)
+	}
+	p.Password = "old"
+	p.Options.PBKDF2Iter = pbkdf2Iter
+	p.AllowMissing = true
+	if _, err := p.Encrypt(secureflow.EncryptOptions{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	encryptedPath := filepath.Join("enc", "env.encrypted")
+	ciphertext, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", encryptedPath, err)
+	}
+
+	err = runRotate(rotateCmd, nil)
+	if !errors.Is(err, secureflow.ErrMissing) || errorStatus(err) != exitMissing {
+		t.Errorf("Expected ErrMissing with exit status %d, got %v", exitMissing, err)
+	}
+	if got, _ := os.ReadFile(encryptedPath); !bytes.Equal(got, ciphertext) {
+		t.Error("Expected the encrypted file to be unchanged")
+	}
+
+	setFlag(t, &allowMissing, true)
+	if err := runRotate(rotateCmd, nil); err != nil {
+		t.Errorf("Rotate with --allow-missing failed: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the commands that can act on a subset of the configured files
+var (
+	onlyFiles       []string
+	exceptFiles     []string
+	strictSelection bool
+)
+
+// addSelectionFlags registers --only, --except and --strict on cmd
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&onlyFiles, "only", nil, "act only on files matching this input path, output name, glob or tag; repeatable")
+	cmd.Flags().StringArrayVar(&exceptFiles, "except", nil, "skip files matching this input path, output name, glob or tag; repeatable")
+	cmd.Flags().BoolVar(&strictSelection, "strict", false, "fail if a file argument, --only or --except matches nothing in the config")
+}
+
+// selectFiles narrows cfg.Files to the files named by args and --only, minus
+// those matching --except. It reports whether a subset was selected.
+func selectFiles(cfg *config.Config, args []string) (bool, error) {
+	selector := config.Selector{
+		Only:   append(append([]string{}, args...), onlyFiles...),
+		Except: exceptFiles,
+	}
+	if selector.Empty() {
+		return false, nil
+	}
+
+	unmatched := cfg.Select(selector)
+	if len(unmatched) > 0 {
+		if strictSelection {
+			return true, fmt.Errorf("not in %s: %s", cfgFile, strings.Join(unmatched, ", "))
+		}
+		for _, pattern := range unmatched {
+			fmt.Printf("%s ⚠️  Warning: %s matches no file in %s\n", utils.ColorYellow, pattern, cfgFile)
+		}
+	}
+	if len(cfg.Files) == 0 {
+		return true, fmt.Errorf("no files selected")
+	}
+	return true, nil
+}
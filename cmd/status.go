@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/password"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [file...]",
+	Short: "Show which files have changed since they were last encrypted",
+	Long: `Compares every file listed in secureflow.yaml with its encrypted copy and
+reports whether the plaintext is missing, the encrypted file is missing, or
+the plaintext has changed since it was last encrypted.
+
+Without a password, changes are detected from the size and modification time
+recorded in manifest.json. When a password is available from --password-env,
+SECUREFLOW_PASSWORD or the other password flags, or with --content, the
+content itself is compared using the manifest's keyed fingerprint, or by
+decrypting in memory when there is none.
+
+Exits with a non-zero status when any file is out of sync, so it can gate a
+pre-commit hook or CI job. File arguments, --only and --except limit the
+check to some files.`,
+	RunE: runStatus,
+	// Drift is reported in the table; usage would only bury it
+	SilenceUsage: true,
+}
+
+var statusContent bool
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusContent, "content", false, "compare file contents, prompting for the password if needed")
+	addSelectionFlags(statusCmd)
+}
+
+// fileState is the sync state of one file mapping
+type fileState int
+
+const (
+	stateUpToDate fileState = iota
+	stateModified
+	statePlaintextMissing
+	stateEncryptedMissing
+	stateManifestStale
+	stateUnknown
+	stateError
+)
+
+func (s fileState) String() string {
+	switch s {
+	case stateUpToDate:
+		return "up to date"
+	case stateModified:
+		return "modified since last encrypt"
+	case statePlaintextMissing:
+		return "plaintext missing"
+	case stateEncryptedMissing:
+		return "not encrypted"
+	case stateManifestStale:
+		return "encrypted file changed since manifest was written"
+	case stateUnknown:
+		return "unknown (not in manifest; use --content)"
+	default:
+		return "error"
+	}
+}
+
+// statusChecker compares plaintext files with their encrypted copies
+type statusChecker struct {
+	cfg  *config.Config
+	opts crypto.Options
+	m    *manifest.Manifest
+
+	// content is set when plaintext can be compared by content
+	content bool
+	pwd     string
+	key     []byte
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	// Load config
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := selectFiles(cfg, args); err != nil {
+		return err
+	}
+
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	c, err := newStatusChecker(cfg, opts, statusContent)
+	if err != nil {
+		return err
+	}
+
+	if c.content {
+		fmt.Printf("%s 🔍 Comparing file contents\n\n", utils.ColorBlue)
+	} else {
+		fmt.Printf("%s 🔍 Comparing size and modification time (pass a password to compare contents)\n\n", utils.ColorBlue)
+	}
+
+	outOfSync := 0
+	for _, fileMapping := range cfg.Files {
+		state, err := c.check(fileMapping)
+
+		switch state {
+		case stateUpToDate:
+			fmt.Printf("%s ✅ %-40s %s\n", utils.ColorGreen, fileMapping.Input, state)
+		case stateError:
+			fmt.Printf("%s ❌ %-40s %v\n", utils.ColorRed, fileMapping.Input, err)
+		default:
+			fmt.Printf("%s ⚠️  %-40s %s\n", utils.ColorYellow, fileMapping.Input, state)
+		}
+
+		if state != stateUpToDate {
+			outOfSync++
+		}
+	}
+
+	fmt.Println()
+	if outOfSync > 0 {
+		return fmt.Errorf("%d of %d file(s) out of sync; run secureflow encrypt", outOfSync, len(cfg.Files))
+	}
+
+	fmt.Printf("%s 🎉 All %d file(s) up to date\n", utils.ColorGreen, len(cfg.Files))
+	return nil
+}
+
+// newStatusChecker loads the manifest and, when prompt is set or a password
+// is available without prompting, sets up comparing by content
+func newStatusChecker(cfg *config.Config, opts crypto.Options, prompt bool) (*statusChecker, error) {
+	c := &statusChecker{cfg: cfg, opts: opts}
+
+	var err error
+	c.m, err = manifest.Load(cfg.OutputDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Compare content whenever it can be done without prompting
+	switch {
+	case len(opts.Identities) > 0:
+		c.content = true
+	case prompt:
+		if c.pwd, err = resolvePassword(cfg, "🔐 Enter password to compare contents: "); err != nil {
+			return nil, err
+		}
+		c.content = true
+	default:
+		var found bool
+		if c.pwd, found, err = password.Resolve(passwordSource(c.cfg), os.Stdin); err != nil {
+			return nil, err
+		}
+		c.content = found
+	}
+	if c.content && c.m != nil {
+		if c.key, err = c.m.Key(c.pwd); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// check determines the state of one file mapping
+func (c *statusChecker) check(fileMapping config.FileMapping) (fileState, error) {
+	encryptedPath := filepath.Join(c.cfg.OutputDir, fileMapping.Output)
+
+	info, err := os.Stat(fileMapping.Input)
+	if err != nil {
+		return statePlaintextMissing, nil
+	}
+	if !utils.FileExists(encryptedPath) {
+		return stateEncryptedMissing, nil
+	}
+
+	var entry *manifest.Entry
+	if c.m != nil {
+		entry = c.m.Entry(fileMapping.Output)
+	}
+	if entry != nil {
+		if err := entry.VerifyCiphertext(encryptedPath); errors.Is(err, manifest.ErrMismatch) {
+			return stateManifestStale, nil
+		} else if err != nil {
+			return stateError, err
+		}
+	}
+
+	if !c.content {
+		if entry == nil {
+			return stateUnknown, nil
+		}
+		if info.Size() != entry.Size || !info.ModTime().Equal(entry.ModifiedAt) {
+			return stateModified, nil
+		}
+		return stateUpToDate, nil
+	}
+
+	// Compare content, preferring the keyed fingerprint to decrypting
+	if entry != nil && c.key != nil && entry.PlaintextHMAC != "" {
+		if err := entry.VerifyPlaintext(c.key, fileMapping.Input); errors.Is(err, manifest.ErrMismatch) {
+			return stateModified, nil
+		} else if err != nil {
+			return stateError, err
+		}
+		return stateUpToDate, nil
+	}
+
+	same, err := c.decryptAndCompare(encryptedPath, fileMapping.Input)
+	if err != nil {
+		return stateError, decryptionError("cannot decrypt", err)
+	}
+	if !same {
+		return stateModified, nil
+	}
+	return stateUpToDate, nil
+}
+
+// decryptAndCompare decrypts encryptedPath in memory and compares the result
+// with the plaintext file, without writing anything to disk
+func (c *statusChecker) decryptAndCompare(encryptedPath, plaintextPath string) (bool, error) {
+	in, err := os.Open(encryptedPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open encrypted file: %w", err)
+	}
+	defer in.Close()
+
+	plaintext, err := os.Open(plaintextPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open plaintext file: %w", err)
+	}
+	defer plaintext.Close()
+
+	cw := &compareWriter{r: plaintext, same: true}
+	if err := crypto.Decrypt(in, cw, c.pwd, c.opts); err != nil {
+		return false, err
+	}
+
+	// The plaintext must not have anything left over
+	if cw.same {
+		n, _ := plaintext.Read(make([]byte, 1))
+		cw.same = n == 0
+	}
+	return cw.same, nil
+}
+
+// compareWriter compares everything written to it with the contents of r
+type compareWriter struct {
+	r    io.Reader
+	same bool
+	buf  []byte
+}
+
+func (w *compareWriter) Write(p []byte) (int, error) {
+	if !w.same {
+		return len(p), nil
+	}
+	if cap(w.buf) < len(p) {
+		w.buf = make([]byte, len(p))
+	}
+	buf := w.buf[:len(p)]
+	if _, err := io.ReadFull(w.r, buf); err != nil || !bytes.Equal(buf, p) {
+		w.same = false
+	}
+	return len(p), nil
+}
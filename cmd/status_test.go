@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+)
+
+func TestStatusCheck(t *testing.T) {
+	const encryptedPath = "enc/env.encrypted"
+
+	// rewrite replaces the plaintext, keeping the modification time the
+	// manifest recorded
+	rewrite := func(content string) func(t *testing.T) {
+		return func(t *testing.T) {
+			info, err := os.Stat(".env")
+			if err != nil {
+				t.Fatalf("Failed to stat .env: %v", err)
+			}
+			writeFiles(t, map[string]string{".env": content})
+			if err := os.Chtimes(".env", info.ModTime(), info.ModTime()); err != nil {
+				t.Fatalf("Failed to restore modification time: %v", err)
+			}
+		}
+	}
+	remove := func(path string) func(t *testing.T) {
+		return func(t *testing.T) {
+			if err := os.Remove(path); err != nil {
+				t.Fatalf("Failed to remove %s: %v", path, err)
+			}
+		}
+	}
+	both := func(fs ...func(t *testing.T)) func(t *testing.T) {
+		return func(t *testing.T) {
+			for _, f := range fs {
+				f(t)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		password string
+		change   func(t *testing.T)
+		expected fileState
+		err      error
+	}{
+		{name: "UpToDate", password: "password", expected: stateUpToDate},
+		{name: "UpToDateWithoutPassword", expected: stateUpToDate},
+		{name: "UpToDateWithoutManifest", password: "password", change: remove(manifest.Path("enc")), expected: stateUpToDate},
+		// Without a password only size and modification time are compared
+		{name: "ModifiedSize", change: rewrite("API_KEY=changed\n"), expected: stateModified},
+		{name: "ModifiedSameSizeAndTime", password: "password", change: rewrite("API_KEY=SECRET\n"), expected: stateModified},
+		{name: "ModifiedWithoutManifest", password: "password", change: both(remove(manifest.Path("enc")), rewrite("API_KEY=SECRET\n")), expected: stateModified},
+		{name: "PlaintextLonger", password: "password", change: both(remove(manifest.Path("enc")), rewrite("API_KEY=secret\nDEBUG=1\n")), expected: stateModified},
+		{name: "PlaintextShorter", password: "password", change: both(remove(manifest.Path("enc")), rewrite("API_KEY=sec")), expected: stateModified},
+		{name: "PlaintextMissing", password: "password", change: remove(".env"), expected: statePlaintextMissing},
+		{name: "EncryptedMissing", password: "password", change: remove(encryptedPath), expected: stateEncryptedMissing},
+		{name: "ManifestStale", password: "password", change: func(t *testing.T) { flipLastByte(t, encryptedPath) }, expected: stateManifestStale},
+		{name: "Unknown", change: remove(manifest.Path("enc")), expected: stateUnknown},
+		{name: "WrongPassword", password: "wrong", err: crypto.ErrWrongPassword},
+		{name: "WrongPasswordWithoutManifest", format: "aead", password: "wrong", change: remove(manifest.Path("enc")), expected: stateError, err: crypto.ErrWrongPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			config := "output_dir: enc\nfiles:\n  - input: .env\n    output: env.encrypted\n"
+			if tt.format != "" {
+				config = "format: " + tt.format + "\n" + config
+			}
+			writeFiles(t, map[string]string{
+				"secureflow.yaml": config,
+				".env":            "API_KEY=secret\n",
+			})
+			setFlag(t, &cfgFile, "secureflow.yaml")
+			setFlag(t, &pbkdf2Iter, 1000)
+			setFlag(t, &passwordFlag, tt.password)
+			t.Setenv("SECUREFLOW_PASSWORD", "")
+			encryptProject(t, "password")
+			if tt.change != nil {
+				tt.change(t)
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+			opts, err := cryptoOptions(cfg)
+			if err != nil {
+				t.Fatalf("Failed to build options: %v", err)
+			}
+			c, err := newStatusChecker(cfg, opts, false)
+			if err != nil {
+				// The manifest rejects a wrong password up front
+				if tt.err == nil || !errors.Is(err, tt.err) {
+					t.Fatalf("newStatusChecker failed: %v", err)
+				}
+				return
+			}
+			if tt.err != nil && tt.expected != stateError {
+				t.Fatalf("Expected newStatusChecker to fail with %v", tt.err)
+			}
+
+			state, err := c.check(cfg.Files[0])
+			if state != tt.expected {
+				t.Errorf("Expected %q, got %q (%v)", tt.expected, state, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Expected error %v, got %v", tt.err, err)
+			}
+			if tt.err == nil && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCompareWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		same     bool
+		leftover bool
+	}{
+		{name: "Equal", writes: []string{"API_KEY=", "secret\n"}, same: true},
+		{name: "Different", writes: []string{"API_KEY=", "SECRET\n"}, same: false},
+		{name: "Longer", writes: []string{"API_KEY=secret\n", "DEBUG=1\n"}, same: false},
+		// Everything written matches, so only the leftover tells them apart
+		{name: "Shorter", writes: []string{"API_KEY="}, same: true, leftover: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader("API_KEY=secret\n")
+			w := &compareWriter{r: r, same: true}
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write returned %d, %v", n, err)
+				}
+			}
+			if w.same != tt.same {
+				t.Errorf("Expected same=%v, got %v", tt.same, w.same)
+			}
+			if got := r.Len() > 0; got != tt.leftover {
+				t.Errorf("Expected leftover=%v, got %v", tt.leftover, got)
+			}
+		})
+	}
+}
+
+// flipLastByte corrupts the file at path by inverting its last byte
+func flipLastByte(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
@@ -2,89 +2,80 @@ package cmd
 
 import (
 	"fmt"
-	"path/filepath"
 
-	"github.com/MayR-Labs/secureflow-go/internal/config"
-	"github.com/MayR-Labs/secureflow-go/internal/crypto"
 	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/pkg/secureflow"
 	"github.com/spf13/cobra"
 )
 
 var testCmd = &cobra.Command{
-	Use:   "test",
+	Use:   "test [file...]",
 	Short: "Test decryption without overwriting existing files",
 	Long: `Decrypts files into a separate test directory to verify the encryption 
-password is correct without overwriting existing secrets.`,
+password is correct without overwriting existing secrets.
+
+File arguments, --only and --except select files as for decrypt, and
+missing and failed files are handled as they are by decrypt.`,
 	RunE: runTest,
 }
 
 func init() {
 	rootCmd.AddCommand(testCmd)
+	addSelectionFlags(testCmd)
+	addJobsFlag(testCmd)
+	addFailureFlags(testCmd)
 }
 
 func runTest(cmd *cobra.Command, args []string) error {
+	if err := checkJobs(); err != nil {
+		return err
+	}
+
 	// Load config
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
+	}
+	if _, err := selectFiles(cfg, args); err != nil {
+		return err
 	}
 
-	// Get password
-	var pwd string
-	if password != "" {
-		pwd = password
-	} else if nonInteractive {
-		return fmt.Errorf("password required in non-interactive mode (use --password flag)")
-	} else {
-		pwd, err = utils.ReadPassword(utils.Colorize(utils.ColorBlue, "🔐 [TEST] Enter password to test decrypt your secrets: "))
-		if err != nil {
-			return err
-		}
-		if pwd == "" {
-			return fmt.Errorf("password cannot be empty")
-		}
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
 	}
 
-	// Ensure test output directory exists
-	if err := utils.EnsureDir(cfg.TestOutputDir); err != nil {
+	// Get password (not needed when decrypting with identities)
+	var pwd string
+	if len(opts.Identities) > 0 {
+		fmt.Printf("%s 🔑 Using %d identity key(s)\n", utils.ColorBlue, len(opts.Identities))
+	} else if pwd, err = resolvePassword(cfg, "🔐 [TEST] Enter password to test decrypt your secrets: "); err != nil {
 		return err
 	}
 
 	fmt.Println()
 	fmt.Printf("%s 🔐 [TEST] Starting decryption process...\n\n", utils.ColorYellow)
 
-	// Decrypt each file to test directory
-	successCount := 0
-	for _, fileMapping := range cfg.Files {
-		encryptedPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
-
-		fmt.Printf("%s 📄 Decrypting %s...\n", utils.ColorYellow, encryptedPath)
-
-		// Check if encrypted file exists
-		if !utils.FileExists(encryptedPath) {
-			fmt.Printf("%s ⚠️  Warning: %s not found, skipping\n\n", utils.ColorYellow, encryptedPath)
-			continue
-		}
-
-		// Get just the filename for test output
-		testOutputPath := filepath.Join(cfg.TestOutputDir, filepath.Base(fileMapping.Input))
-
-		// Decrypt file
-		if err := crypto.DecryptFile(encryptedPath, testOutputPath, pwd); err != nil {
-			fmt.Printf("%s ❌ Failed to decrypt %s: %v\n\n", utils.ColorRed, encryptedPath, err)
-			return fmt.Errorf("test decryption failed (wrong password?)")
-		}
-
-		fmt.Printf("%s ✅ %s decrypted successfully -> %s\n\n", utils.ColorGreen, encryptedPath, testOutputPath)
-		successCount++
+	project := &secureflow.Project{
+		Config:       cfg,
+		Options:      opts,
+		Password:     pwd,
+		Progress:     printDecryptProgress(false),
+		Jobs:         jobs,
+		AllowMissing: allowMissing,
+		FailFast:     failFast,
 	}
-
-	if successCount == 0 {
-		return fmt.Errorf("no files were decrypted")
+	res, err := project.Test()
+	if err := checkResult(cmd, "test decryption failed", res, err); err != nil {
+		return err
 	}
 
 	fmt.Println()
-	fmt.Printf("%s 🎉 Test decryption successful! (%d file(s))\n", utils.ColorGreen, successCount)
+	if len(res.Failed) > 0 {
+		fmt.Printf("%s ✅ %d file(s) test decrypted\n", utils.ColorGreen, res.Done)
+	} else {
+		fmt.Printf("%s 🎉 Test decryption successful! (%d file(s))\n", utils.ColorGreen, res.Done)
+	}
 	fmt.Printf("📁 Test files saved to: %s\n", cfg.TestOutputDir)
 
 	return nil
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check secureflow.yaml for mistakes",
+	Long: `Checks the config file without encrypting or decrypting anything:
+
+  - unknown fields, such as a misspelled "outptu:"
+  - a missing output_dir
+  - two entries writing the same encrypted file
+  - outputs that are paths or contain ..
+  - inputs inside output_dir
+  - copy_to paths that would overwrite another entry's input
+  - invalid format, kdf or recipients settings
+
+It also warns about plaintext paths that git does not ignore.
+
+Every environment is checked, and glob and directory entries are expanded.
+Problems are reported as file:line:column. Other commands run the same
+checks before doing any work.`,
+	Args: cobra.NoArgs,
+	RunE: runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	cfg, err := config.Load(cfgFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			fmt.Printf("%s ❌ %s\n", utils.ColorRed, problem)
+		}
+		fmt.Println()
+		fmt.Printf("%s %d problem(s) found in %s\n", utils.ColorRed, len(invalid.Problems), cfgFile)
+		cmd.SilenceErrors = true
+		return exitConfig
+	}
+	if err != nil {
+		return &configError{fmt.Errorf("failed to load config: %w", err)}
+	}
+
+	names := cfg.UsedEnvironments()
+
+	var warnings []string
+	warned := make(map[string]bool)
+	for _, name := range names {
+		selected, err := cfg.ForEnvironment(name)
+		if err == nil {
+			err = selected.ExpandFiles()
+		}
+		if err == nil {
+			_, err = cryptoOptions(selected)
+		}
+
+		label := "default"
+		if name != "" {
+			label = "environment " + name
+		}
+		if err != nil {
+			return &configError{fmt.Errorf("%s: %w", label, err)}
+		}
+		fmt.Printf("%s ✅ %s: %d file(s) -> %s\n", utils.ColorGreen, label, len(selected.Files), selected.OutputDir)
+
+		unignored, err := unignoredPaths(selected)
+		if err != nil {
+			return err
+		}
+		for _, p := range unignored {
+			if !warned[p] {
+				warned[p] = true
+				warnings = append(warnings, p)
+			}
+		}
+	}
+
+	if len(warnings) > 0 {
+		fmt.Println()
+		for _, p := range warnings {
+			fmt.Printf("%s ⚠️  %s is not ignored by git; run secureflow gitignore sync\n", utils.ColorYellow, p)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("%s 🎉 %s is valid\n", utils.ColorGreen, cfgFile)
+	return nil
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [file...]",
+	Short: "Check encrypted files for corruption and tampering",
+	Long: `Checks each encrypted file without writing any plaintext to disk:
+
+  - the file exists and has a valid OpenSSL or SecureFlow header
+  - the whole file decrypts: every chunk's authentication tag for the aead
+    format, the padding for the openssl format
+  - its checksum and password-keyed fingerprints match manifest.json, which
+    authenticates openssl files as well
+
+The exit status tells failures apart:
+
+  0  every file verified
+  2  an encrypted file is missing
+  3  wrong password or identity
+  4  a file is corrupted or has been tampered with
+
+When files fail for different reasons the highest status is returned. An
+openssl file with no manifest entry cannot tell a wrong password from
+corruption, and is reported as a wrong password. A missing file whose entry
+is marked "optional: true" is skipped, as is every missing file with
+--allow-missing.
+
+File arguments, --only and --except select files as for decrypt.`,
+	RunE: runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	addSelectionFlags(verifyCmd)
+	verifyCmd.Flags().BoolVar(&allowMissing, "allow-missing", false, allowMissingUsage)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := selectFiles(cfg, args); err != nil {
+		return err
+	}
+
+	opts, err := cryptoOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Get password (not needed when decrypting with identities)
+	var pwd string
+	if len(opts.Identities) > 0 {
+		fmt.Printf("%s 🔑 Using %d identity key(s)\n", utils.ColorBlue, len(opts.Identities))
+	} else if pwd, err = resolvePassword(cfg, "🔐 Enter password to verify your secrets: "); err != nil {
+		return err
+	}
+
+	m, key, err := loadManifest(cfg, pwd)
+	if errors.Is(err, manifest.ErrWrongPassword) {
+		fmt.Printf("%s ❌ %v\n", utils.ColorRed, err)
+		cmd.SilenceErrors = true
+		return exitWrongPassword
+	}
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		fmt.Printf("%s ⚠️  No %s in %s, checking structure and decryption only\n", utils.ColorYellow, manifest.FileName, cfg.OutputDir)
+	}
+	keyChecked := key != nil && m.HasKeyCheck()
+
+	fmt.Println()
+
+	var status exitCode
+	failed, skipped := 0, 0
+	for _, fileMapping := range cfg.Files {
+		encryptedPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
+		if (fileMapping.Optional || allowMissing) && !utils.FileExists(encryptedPath) {
+			fmt.Printf("%s ⚠️  %s: not found, skipping\n", utils.ColorYellow, encryptedPath)
+			skipped++
+			continue
+		}
+
+		code, err := verifyFile(encryptedPath, m.Entry(fileMapping.Output), pwd, opts, key, keyChecked)
+		if code == 0 && err != nil {
+			return err
+		}
+		if code != 0 {
+			fmt.Printf("%s ❌ %s: %v\n", utils.ColorRed, encryptedPath, err)
+			status = max(status, code)
+			failed++
+			continue
+		}
+		fmt.Printf("%s ✅ %s\n", utils.ColorGreen, encryptedPath)
+	}
+
+	fmt.Println()
+	if status != 0 {
+		fmt.Printf("%s ❌ %d of %d file(s) failed verification\n", utils.ColorRed, failed, len(cfg.Files)-skipped)
+		cmd.SilenceErrors = true
+		return status
+	}
+	fmt.Printf("%s 🎉 All %d file(s) verified\n", utils.ColorGreen, len(cfg.Files)-skipped)
+	return nil
+}
+
+// verifyFile checks one encrypted file, decrypting it into the manifest
+// fingerprint rather than to disk. A failure is returned with its exit
+// status; an error with status 0 is unexpected, such as an unreadable file.
+// keyChecked means the manifest confirmed the password.
+func verifyFile(path string, entry *manifest.Entry, pwd string, opts crypto.Options, key []byte, keyChecked bool) (exitCode, error) {
+	if !utils.FileExists(path) {
+		return exitMissing, fmt.Errorf("not found")
+	}
+
+	if entry != nil {
+		if err := entry.VerifyCiphertext(path); err != nil {
+			return mismatchStatus(err)
+		}
+		if err := entry.AuthenticateCiphertext(key, path); err != nil {
+			if !keyChecked && errors.Is(err, manifest.ErrMismatch) {
+				// The checksum matched, so the key is the likelier culprit
+				return exitWrongPassword, fmt.Errorf("%w: %s fingerprint does not match", crypto.ErrWrongPassword, manifest.FileName)
+			}
+			return mismatchStatus(err)
+		}
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+	defer in.Close()
+
+	var fingerprint hash.Hash
+	var w io.Writer = io.Discard
+	if entry != nil && key != nil && entry.PlaintextHMAC != "" {
+		fingerprint = manifest.NewHMAC(key)
+		w = fingerprint
+	}
+
+	err = crypto.Decrypt(in, w, pwd, opts)
+	switch {
+	case err == nil:
+	case errors.Is(err, crypto.ErrWrongPassword), errors.Is(err, crypto.ErrNoIdentity):
+		return exitWrongPassword, err
+	case errors.Is(err, crypto.ErrCorrupted), errors.Is(err, crypto.ErrInvalidFormat):
+		return exitCorrupted, err
+	case errors.Is(err, crypto.ErrDecryptionFailed):
+		// The OpenSSL format cannot say which it was, but the manifest can
+		switch {
+		case keyChecked && entry != nil:
+			return exitWrongPassword, fmt.Errorf("%w: the password and file match %s, so the kdf settings differ from those used to encrypt", crypto.ErrWrongPassword, manifest.FileName)
+		case keyChecked:
+			return exitCorrupted, fmt.Errorf("%w: the password matches %s but the file does not decrypt", crypto.ErrCorrupted, manifest.FileName)
+		case entry != nil:
+			return exitWrongPassword, fmt.Errorf("%w: the file matches its recorded checksum but does not decrypt", crypto.ErrWrongPassword)
+		default:
+			return exitWrongPassword, fmt.Errorf("%w (no manifest entry to tell which)", err)
+		}
+	default:
+		return 0, err
+	}
+
+	if fingerprint != nil {
+		if err := entry.CheckPlaintext(hex.EncodeToString(fingerprint.Sum(nil))); err != nil {
+			if keyChecked {
+				return exitCorrupted, err
+			}
+			// The ciphertext is the one recorded, so other plaintext means
+			// another password happened to produce valid padding
+			return exitWrongPassword, fmt.Errorf("%w: decrypted content does not match %s", crypto.ErrWrongPassword, manifest.FileName)
+		}
+	}
+	return 0, nil
+}
+
+// mismatchStatus reports a manifest mismatch as corruption and passes any
+// other error through as unexpected
+func mismatchStatus(err error) (exitCode, error) {
+	if errors.Is(err, manifest.ErrMismatch) {
+		return exitCorrupted, err
+	}
+	return 0, err
+}
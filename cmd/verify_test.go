@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+)
+
+func TestVerifyExitStatus(t *testing.T) {
+	encryptedPath := filepath.Join("enc", "env.encrypted")
+	removeManifest := func(t *testing.T) {
+		if err := os.Remove(manifest.Path("enc")); err != nil {
+			t.Fatalf("Failed to remove manifest: %v", err)
+		}
+	}
+	// editEntry changes the manifest entry of the encrypted file
+	editEntry := func(edit func(e *manifest.Entry)) func(t *testing.T) {
+		return func(t *testing.T) {
+			m, err := manifest.Load("enc")
+			if err != nil {
+				t.Fatalf("Failed to load manifest: %v", err)
+			}
+			edit(m.Entry("env.encrypted"))
+			if err := m.Save("enc"); err != nil {
+				t.Fatalf("Failed to save manifest: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		change   func(t *testing.T)
+		expected error
+	}{
+		{name: "Verified", password: "password", expected: nil},
+		{name: "FlippedByte", password: "password", change: func(t *testing.T) { flipLastByte(t, encryptedPath) }, expected: exitCorrupted},
+		{name: "FlippedByteWithoutManifest", password: "password", change: func(t *testing.T) {
+			removeManifest(t)
+			flipLastByte(t, encryptedPath)
+		}, expected: exitCorrupted},
+		{name: "WrongPassword", password: "wrong", expected: exitWrongPassword},
+		{name: "WrongPasswordWithoutManifest", password: "wrong", change: removeManifest, expected: exitWrongPassword},
+		{name: "Missing", password: "password", change: func(t *testing.T) {
+			if err := os.Remove(encryptedPath); err != nil {
+				t.Fatalf("Failed to remove %s: %v", encryptedPath, err)
+			}
+		}, expected: exitMissing},
+		{name: "ChecksumMismatch", password: "password", change: editEntry(func(e *manifest.Entry) {
+			e.CiphertextSHA256 = manifest.SHA256([]byte("something else"))
+		}), expected: exitCorrupted},
+		{name: "PlaintextMismatch", password: "password", change: editEntry(func(e *manifest.Entry) {
+			e.PlaintextHMAC = manifest.SHA256([]byte("something else"))
+		}), expected: exitCorrupted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			writeFiles(t, map[string]string{
+				"secureflow.yaml": "format: aead\noutput_dir: enc\nfiles:\n  - input: .env\n    output: env.encrypted\n",
+				".env":            "API_KEY=secret\n",
+			})
+			setFlag(t, &cfgFile, "secureflow.yaml")
+			setFlag(t, &nonInteractive, true)
+			setFlag(t, &passwordFlag, tt.password)
+			setFlag(t, &allowMissing, false)
+			encryptProject(t, "password")
+			if tt.change != nil {
+				tt.change(t)
+			}
+
+			if err := runVerify(verifyCmd, nil); err != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -3,22 +3,111 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
 	"gopkg.in/yaml.v3"
 )
 
-// FileMapping represents a file to be encrypted or decrypted
+// FileMapping represents a file to be encrypted or decrypted. Input may
+// also be a glob or a directory, which ExpandFiles turns into one mapping
+// per file.
 type FileMapping struct {
-	Input  string `yaml:"input"`
-	Output string `yaml:"output"`
-	CopyTo string `yaml:"copy_to,omitempty"` // Optional: copy decrypted file to this path
+	Input   string   `yaml:"input"`
+	Output  string   `yaml:"output,omitempty"`  // For globs and directories: the folder under output_dir
+	CopyTo  string   `yaml:"copy_to,omitempty"` // Optional: copy decrypted file to this path
+	Exclude []string `yaml:"exclude,omitempty"` // Optional: patterns to leave out of a glob or directory
+	Tags    []string `yaml:"tags,omitempty"`    // Optional: names for selecting files with --only and --except
+	Mode    FileMode `yaml:"mode,omitempty"`    // Optional: permissions for the decrypted file, e.g. 0400
+
+	// Optional lets the file be missing: it is skipped with a warning
+	// instead of failing encrypt, decrypt, test and verify
+	Optional bool `yaml:"optional,omitempty"`
+
+	// K8s optionally says which Secret decrypt --output-format k8s-secret
+	// puts the file in
+	K8s *K8sSecret `yaml:"k8s,omitempty"`
+
+	// Encryption is "file" (the default) to encrypt the file as one blob, or
+	// "values" to encrypt each value of a dotenv, YAML or JSON file and
+	// leave its keys readable
+	Encryption string `yaml:"encryption,omitempty"`
+
+	// Pattern is the glob or directory this mapping was expanded from
+	Pattern string `yaml:"-"`
+
+	pos position // where the entry starts in the config file
+}
+
+// Encryption modes of a file mapping
+const (
+	EncryptFile   = "file"
+	EncryptValues = "values"
+)
+
+// FileMode is a file permission mode written in octal, such as 0600
+type FileMode os.FileMode
+
+// UnmarshalYAML reads the mode from its octal digits, so 0400 and "0400"
+// mean the same
+func (m *FileMode) UnmarshalYAML(node *yaml.Node) error {
+	digits := strings.TrimPrefix(strings.TrimPrefix(node.Value, "0o"), "0O")
+	mode, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil || mode > 0777 {
+		if node.Value == EncryptValues {
+			return fmt.Errorf("line %d: invalid mode %q: mode sets file permissions; for per-value encryption use encryption: values", node.Line, node.Value)
+		}
+		return fmt.Errorf("line %d: invalid mode %q: expected octal permissions such as 0600", node.Line, node.Value)
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// MarshalYAML writes the mode as octal digits
+func (m FileMode) MarshalYAML() (interface{}, error) {
+	return m.String(), nil
+}
+
+// String formats the mode as octal digits, such as 0600
+func (m FileMode) String() string {
+	return fmt.Sprintf("%04o", uint32(m))
+}
+
+// KDFConfig selects the key derivation function used by the aead format.
+// Zero-valued fields take the defaults for the selected algorithm.
+type KDFConfig struct {
+	Algorithm   string `yaml:"algorithm,omitempty"`   // pbkdf2, scrypt or argon2id (default)
+	Iterations  uint32 `yaml:"iterations,omitempty"`  // pbkdf2 iterations
+	N           uint32 `yaml:"n,omitempty"`           // scrypt cost, a power of two
+	R           uint32 `yaml:"r,omitempty"`           // scrypt block size
+	P           uint32 `yaml:"p,omitempty"`           // scrypt parallelization
+	Time        uint32 `yaml:"time,omitempty"`        // argon2id passes
+	Memory      uint32 `yaml:"memory,omitempty"`      // argon2id memory in KiB
+	Parallelism uint8  `yaml:"parallelism,omitempty"` // argon2id lanes
+}
+
+// PasswordConfig names where to read the password from when no password
+// flag is given. Set one field; the password itself never goes in the config.
+type PasswordConfig struct {
+	Env     string `yaml:"env,omitempty"`     // environment variable holding the password
+	File    string `yaml:"file,omitempty"`    // file whose first line is the password
+	Command string `yaml:"command,omitempty"` // shell command that prints the password
 }
 
 // Config represents the secureflow.yaml configuration
 type Config struct {
-	OutputDir     string        `yaml:"output_dir"`
-	TestOutputDir string        `yaml:"test_output_dir"`
-	Files         []FileMapping `yaml:"files"`
+	OutputDir     string                  `yaml:"output_dir"`
+	TestOutputDir string                  `yaml:"test_output_dir"`
+	Format        string                  `yaml:"format,omitempty"`       // Optional: "openssl" (default) or "aead"
+	KDF           *KDFConfig              `yaml:"kdf,omitempty"`          // Optional: key derivation for the aead format
+	Recipients    []string                `yaml:"recipients,omitempty"`   // Optional: public keys to encrypt to instead of a password
+	Password      *PasswordConfig         `yaml:"password,omitempty"`     // Optional: where to read the password from
+	Files         []FileMapping           `yaml:"files"`                  // Shared by every environment
+	Environments  map[string]*Environment `yaml:"environments,omitempty"` // Optional: named profiles selected with --env
+
+	path string // file the config was loaded from, for error messages
 }
 
 // DefaultConfig returns a default configuration
@@ -145,19 +234,35 @@ func MicroservicesConfig() *Config {
 	}
 }
 
-// Load reads and parses a secureflow.yaml file
+// Load reads and parses a secureflow.yaml file. Unknown fields are
+// rejected with their line and column, so typos are not silently ignored.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	cfg := &Config{path: path}
+	if len(root.Content) == 0 {
+		// Empty file
+		return cfg, nil
+	}
+	doc := root.Content[0]
+
+	if problems := unknownFields(path, doc, reflect.TypeOf(cfg), ""); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	if err := doc.Decode(cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	cfg.recordPositions(doc)
 
-	return &cfg, nil
+	return cfg, nil
 }
 
 // Save writes the configuration to a YAML file
@@ -167,7 +272,7 @@ func (c *Config) Save(path string) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := utils.WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -193,6 +193,46 @@ func TestCopyToField(t *testing.T) {
 	}
 }
 
+func TestModeField(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected FileMode
+		wantErr  bool
+	}{
+		{name: "Octal", value: "0400", expected: 0400},
+		{name: "NoLeadingZero", value: "640", expected: 0640},
+		{name: "Prefixed", value: `"0o640"`, expected: 0640},
+		{name: "NotOctal", value: "0800", wantErr: true},
+		{name: "TooLarge", value: "01777", wantErr: true},
+		{name: "Values", value: "values", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secureflow.yaml")
+			data := "output_dir: enc\nfiles:\n  - input: a\n    output: a.encrypted\n    mode: " + tt.value + "\n"
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+
+			cfg, err := Load(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error for mode %s, got %v", tt.value, cfg.Files[0].Mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if cfg.Files[0].Mode != tt.expected {
+				t.Errorf("Expected mode %s, got %s", tt.expected, cfg.Files[0].Mode)
+			}
+		})
+	}
+}
+
 func TestTemplateConfig(t *testing.T) {
 	tests := []struct {
 		name         string
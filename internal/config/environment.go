@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Environment is a named profile, such as prod or staging, with its own
+// output directory, files and password source. Fields left empty take the
+// top-level value, and its files are added after the top-level files.
+type Environment struct {
+	OutputDir     string          `yaml:"output_dir,omitempty"`
+	TestOutputDir string          `yaml:"test_output_dir,omitempty"`
+	Format        string          `yaml:"format,omitempty"`
+	KDF           *KDFConfig      `yaml:"kdf,omitempty"`
+	Recipients    []string        `yaml:"recipients,omitempty"`
+	Password      *PasswordConfig `yaml:"password,omitempty"`
+	Files         []FileMapping   `yaml:"files,omitempty"`
+
+	pos position // where the environment's name appears in the config file
+}
+
+// ForEnvironment returns the configuration for the named environment, with
+// the top-level settings as defaults. An empty name selects the top-level
+// configuration, which must then list files of its own.
+func (c *Config) ForEnvironment(name string) (*Config, error) {
+	if name == "" {
+		if len(c.Files) == 0 && len(c.Environments) > 0 {
+			return nil, fmt.Errorf("no files outside environments; select one with --env (%s)", strings.Join(c.EnvironmentNames(), ", "))
+		}
+		return c, nil
+	}
+
+	env, ok := c.Environments[name]
+	if !ok || env == nil {
+		if len(c.Environments) == 0 {
+			return nil, fmt.Errorf("unknown environment %q: no environments are defined", name)
+		}
+		return nil, fmt.Errorf("unknown environment %q (available: %s)", name, strings.Join(c.EnvironmentNames(), ", "))
+	}
+
+	cfg := &Config{
+		OutputDir:     firstNonEmpty(env.OutputDir, c.OutputDir),
+		TestOutputDir: firstNonEmpty(env.TestOutputDir, c.TestOutputDir),
+		Format:        firstNonEmpty(env.Format, c.Format),
+		KDF:           c.KDF,
+		Recipients:    c.Recipients,
+		Password:      c.Password,
+		path:          c.path,
+	}
+	if env.KDF != nil {
+		cfg.KDF = env.KDF
+	}
+	if len(env.Recipients) > 0 {
+		cfg.Recipients = env.Recipients
+	}
+	if env.Password != nil {
+		cfg.Password = env.Password
+	}
+	cfg.Files = append(append([]FileMapping{}, c.Files...), env.Files...)
+
+	if cfg.OutputDir == "" {
+		return nil, fmt.Errorf("environment %q has no output_dir", name)
+	}
+	return cfg, nil
+}
+
+// EnvironmentNames lists the environment names in sorted order
+func (c *Config) EnvironmentNames() []string {
+	names := make([]string, 0, len(c.Environments))
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UsedEnvironments lists every name ForEnvironment can select: the
+// environment names, after "" for the top level when it is used on its own
+func (c *Config) UsedEnvironments() []string {
+	names := c.EnvironmentNames()
+	if len(names) == 0 || len(c.Files) > 0 {
+		names = append([]string{""}, names...)
+	}
+	return names
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
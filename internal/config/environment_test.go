@@ -0,0 +1,160 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const environmentsYAML = `output_dir: secrets/shared
+test_output_dir: secrets/test
+format: aead
+password:
+  env: DEFAULT_PASSWORD
+files:
+  - input: shared.json
+    output: shared.json.encrypted
+environments:
+  prod:
+    output_dir: secrets/prod
+    password:
+      env: PROD_PASSWORD
+    files:
+      - input: .env.prod
+        output: .env.prod.encrypted
+        copy_to: .env
+  staging:
+    output_dir: secrets/staging
+    format: openssl
+    files:
+      - input: .env.staging
+        output: .env.staging.encrypted
+`
+
+// writeConfig writes data to a config file in a temporary directory
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secureflow.yaml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func loadEnvironmentsConfig(t *testing.T, data string) *Config {
+	t.Helper()
+	cfg, err := Load(writeConfig(t, data))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return cfg
+}
+
+func TestForEnvironment(t *testing.T) {
+	cfg := loadEnvironmentsConfig(t, environmentsYAML)
+
+	t.Run("Prod", func(t *testing.T) {
+		prod, err := cfg.ForEnvironment("prod")
+		if err != nil {
+			t.Fatalf("ForEnvironment failed: %v", err)
+		}
+		if prod.OutputDir != "secrets/prod" {
+			t.Errorf("Expected output_dir 'secrets/prod', got '%s'", prod.OutputDir)
+		}
+		if prod.TestOutputDir != "secrets/test" {
+			t.Errorf("Expected inherited test_output_dir 'secrets/test', got '%s'", prod.TestOutputDir)
+		}
+		if prod.Format != "aead" {
+			t.Errorf("Expected inherited format 'aead', got '%s'", prod.Format)
+		}
+		if prod.Password == nil || prod.Password.Env != "PROD_PASSWORD" {
+			t.Errorf("Expected password env PROD_PASSWORD, got %+v", prod.Password)
+		}
+		if len(prod.Files) != 2 || prod.Files[0].Input != "shared.json" || prod.Files[1].CopyTo != ".env" {
+			t.Errorf("Expected shared file followed by .env.prod, got %+v", prod.Files)
+		}
+		if prod.Environments != nil {
+			t.Error("Expected selected config to have no environments")
+		}
+	})
+
+	t.Run("Staging", func(t *testing.T) {
+		staging, err := cfg.ForEnvironment("staging")
+		if err != nil {
+			t.Fatalf("ForEnvironment failed: %v", err)
+		}
+		if staging.Format != "openssl" {
+			t.Errorf("Expected format 'openssl', got '%s'", staging.Format)
+		}
+		if staging.Password == nil || staging.Password.Env != "DEFAULT_PASSWORD" {
+			t.Errorf("Expected inherited password env DEFAULT_PASSWORD, got %+v", staging.Password)
+		}
+	})
+
+	t.Run("DoesNotModifyTopLevel", func(t *testing.T) {
+		if len(cfg.Files) != 1 {
+			t.Errorf("Expected top-level config to keep 1 file, got %d", len(cfg.Files))
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		top, err := cfg.ForEnvironment("")
+		if err != nil {
+			t.Fatalf("ForEnvironment failed: %v", err)
+		}
+		if top != cfg {
+			t.Error("Expected the top-level config without --env")
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := cfg.ForEnvironment("qa")
+		if err == nil || !strings.Contains(err.Error(), "prod, staging") {
+			t.Errorf("Expected error listing environments, got %v", err)
+		}
+	})
+}
+
+func TestForEnvironmentRequiresSelection(t *testing.T) {
+	cfg := loadEnvironmentsConfig(t, `environments:
+  prod:
+    output_dir: secrets/prod
+    files:
+      - input: .env.prod
+        output: .env.prod.encrypted
+`)
+
+	if _, err := cfg.ForEnvironment(""); err == nil {
+		t.Error("Expected error when only environments list files and none is selected")
+	}
+	if _, err := (&Config{OutputDir: "enc"}).ForEnvironment("prod"); err == nil {
+		t.Error("Expected error for --env without environments")
+	}
+
+	noDir := loadEnvironmentsConfig(t, "environments:\n  prod: {}\n")
+	if _, err := noDir.ForEnvironment("prod"); err == nil {
+		t.Error("Expected error for environment without output_dir")
+	}
+}
+
+func TestUsedEnvironments(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "TopLevelAndEnvironments", data: environmentsYAML, want: ",prod,staging"},
+		{name: "EnvironmentsOnly", data: "environments:\n  prod: {}\n  staging: {}\n", want: "prod,staging"},
+		{name: "TopLevelOnly", data: "output_dir: enc\n", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(loadEnvironmentsConfig(t, tt.data).UsedEnvironments(), ",")
+			if got != tt.want {
+				t.Errorf("Expected environments %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
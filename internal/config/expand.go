@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EncryptedSuffix is appended to the output names of files expanded from a
+// glob or directory entry
+const EncryptedSuffix = ".encrypted"
+
+// ExpandFiles replaces every glob or directory entry in Files with one entry
+// per matching file, in sorted order.
+//
+// Matches are collected from both sides: plaintext files under the input and
+// encrypted files under the output, so decrypt can restore a tree that does
+// not exist locally yet. Each file keeps its path relative to the glob's
+// fixed leading directories, under the entry's output name (by default the
+// last of those directories) inside OutputDir, with ".encrypted" appended.
+func (c *Config) ExpandFiles() error {
+	var files []FileMapping
+	for _, f := range c.Files {
+		if !f.isTree(c.OutputDir) {
+			files = append(files, f)
+			continue
+		}
+		expanded, err := c.expand(f)
+		if err != nil {
+			return err
+		}
+		files = append(files, expanded...)
+	}
+
+	// A glob can reach a file that is also listed on its own
+	written := make(map[string]string, len(files))
+	for _, f := range files {
+		if other, ok := written[f.Output]; ok && other != f.Input {
+			return fmt.Errorf("%s and %s would both be encrypted to %s", other, f.Input, f.Output)
+		}
+		written[f.Output] = f.Input
+	}
+
+	c.Files = files
+	return nil
+}
+
+// isTree reports whether the entry names a glob or directory rather than a
+// single file
+func (f FileMapping) isTree(outputDir string) bool {
+	if hasMeta(f.Input) || strings.HasSuffix(f.Input, "/") {
+		return true
+	}
+	if info, err := os.Stat(f.Input); err == nil && info.IsDir() {
+		return true
+	}
+	if f.Output != "" {
+		if info, err := os.Stat(filepath.Join(outputDir, f.Output)); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// expand lists the files matched by a glob or directory entry
+func (c *Config) expand(f FileMapping) ([]FileMapping, error) {
+	base, pattern := splitPattern(f.Input)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", f.Input, err)
+	}
+	for _, exclude := range f.Exclude {
+		if _, err := path.Match(exclude, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", exclude, err)
+		}
+	}
+
+	prefix := strings.Trim(filepath.ToSlash(f.Output), "/")
+	if prefix == "" {
+		prefix = path.Base(filepath.ToSlash(base))
+	}
+
+	matches := make(map[string]bool)
+	include := func(rel string) {
+		if (pattern == "" || matchPath(pattern, rel)) && !f.excludes(rel) {
+			matches[rel] = true
+		}
+	}
+
+	// Plaintext side, leaving out SecureFlow's own directories
+	skip := []string{c.OutputDir, c.TestOutputDir}
+	if err := walkFiles(base, pattern, skip, include); err != nil {
+		return nil, err
+	}
+
+	// Encrypted side
+	err := walkFiles(filepath.Join(c.OutputDir, filepath.FromSlash(prefix)), "", nil, func(rel string) {
+		if strings.HasSuffix(rel, EncryptedSuffix) {
+			include(strings.TrimSuffix(rel, EncryptedSuffix))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rels := make([]string, 0, len(matches))
+	for rel := range matches {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	files := make([]FileMapping, 0, len(rels))
+	for _, rel := range rels {
+		mapping := FileMapping{
+			Input:      filepath.Join(base, filepath.FromSlash(rel)),
+			Output:     path.Join(prefix, rel+EncryptedSuffix),
+			Tags:       f.Tags,
+			Mode:       f.Mode,
+			Optional:   f.Optional,
+			K8s:        f.K8s,
+			Encryption: f.Encryption,
+			Pattern:    f.Input,
+			pos:        f.pos,
+		}
+		if f.CopyTo != "" {
+			mapping.CopyTo = filepath.Join(f.CopyTo, filepath.FromSlash(rel))
+		}
+		files = append(files, mapping)
+	}
+	return files, nil
+}
+
+// excludes reports whether rel, or any directory above it, matches one of
+// the entry's exclude patterns by full relative path or by name
+func (f FileMapping) excludes(rel string) bool {
+	for _, exclude := range f.Exclude {
+		exclude = strings.Trim(filepath.ToSlash(exclude), "/")
+		for p := rel; p != "." && p != ""; p = path.Dir(p) {
+			if matchPath(exclude, p) || matchPath(exclude, path.Base(p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitPattern splits input into its fixed leading directories and the
+// slash-separated glob below them. A directory gives an empty glob, which
+// matches everything.
+func splitPattern(input string) (base, pattern string) {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(input)), "/")
+	for i, part := range parts {
+		if hasMeta(part) {
+			return filepath.FromSlash(strings.Join(parts[:i], "/")), strings.Join(parts[i:], "/")
+		}
+	}
+	return filepath.Clean(input), ""
+}
+
+// walkFiles calls fn with the slash-separated path, relative to root, of
+// every regular file under root. Directories in skip and .git are not
+// entered, nor directories deeper than pattern can match.
+func walkFiles(root, pattern string, skip []string, fn func(rel string)) error {
+	if root == "" {
+		root = "."
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	skipped := make(map[string]bool)
+	for _, dir := range skip {
+		if abs, err := filepath.Abs(dir); err == nil && dir != "" {
+			skipped[abs] = true
+		}
+	}
+	maxDepth := -1
+	if pattern != "" {
+		maxDepth = strings.Count(pattern, "/") + 1
+	}
+
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			abs, _ := filepath.Abs(p)
+			if d.Name() == ".git" || skipped[abs] || (maxDepth >= 0 && strings.Count(rel, "/")+1 >= maxDepth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			fn(rel)
+		}
+		return nil
+	})
+}
+
+// matchPath is path.Match without the error, for validated patterns
+func matchPath(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// hasMeta reports whether s contains glob metacharacters
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
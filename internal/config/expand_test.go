@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree creates empty files at the given slash-separated paths
+func writeTree(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(full, nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+}
+
+// chdir switches to dir for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir failed: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestExpandFiles(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeTree(t, ".",
+		"certs/a.pem", "certs/b.pem", "certs/readme.txt", "certs/sub/c.pem",
+		"k8s/secrets/db.yaml", "k8s/secrets/tmp/scratch.yaml", "k8s/secrets/api.yaml.bak",
+		"services/auth/.env.prod", "services/api/.env.prod", "services/api/.env.dev",
+		".env.prod",
+	)
+
+	tests := []struct {
+		name     string
+		mapping  FileMapping
+		expected []FileMapping
+	}{
+		{
+			name:    "SingleFile",
+			mapping: FileMapping{Input: ".env.prod", Output: ".env.prod.encrypted"},
+			expected: []FileMapping{
+				{Input: ".env.prod", Output: ".env.prod.encrypted"},
+			},
+		},
+		{
+			name:    "Glob",
+			mapping: FileMapping{Input: "certs/*.pem"},
+			expected: []FileMapping{
+				{Input: filepath.Join("certs", "a.pem"), Output: "certs/a.pem.encrypted", Pattern: "certs/*.pem"},
+				{Input: filepath.Join("certs", "b.pem"), Output: "certs/b.pem.encrypted", Pattern: "certs/*.pem"},
+			},
+		},
+		{
+			name:    "GlobInDirectory",
+			mapping: FileMapping{Input: "services/*/.env.prod", Output: "svc", CopyTo: "out"},
+			expected: []FileMapping{
+				{Input: filepath.Join("services", "api", ".env.prod"), Output: "svc/api/.env.prod.encrypted", CopyTo: filepath.Join("out", "api", ".env.prod"), Pattern: "services/*/.env.prod"},
+				{Input: filepath.Join("services", "auth", ".env.prod"), Output: "svc/auth/.env.prod.encrypted", CopyTo: filepath.Join("out", "auth", ".env.prod"), Pattern: "services/*/.env.prod"},
+			},
+		},
+		{
+			name:    "DirectoryWithExcludes",
+			mapping: FileMapping{Input: "k8s/secrets/", Exclude: []string{"*.bak", "tmp"}},
+			expected: []FileMapping{
+				{Input: filepath.Join("k8s", "secrets", "db.yaml"), Output: "secrets/db.yaml.encrypted", Pattern: "k8s/secrets/"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{OutputDir: "enc", Files: []FileMapping{tt.mapping}}
+			if err := cfg.ExpandFiles(); err != nil {
+				t.Fatalf("ExpandFiles failed: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.Files, tt.expected) {
+				t.Errorf("ExpandFiles() =\n%+v\nexpected\n%+v", cfg.Files, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandFilesFromEncryptedTree(t *testing.T) {
+	// Decrypting on a fresh checkout: only the encrypted tree exists
+	chdir(t, t.TempDir())
+	writeTree(t, ".", "enc/secrets/db.yaml.encrypted", "enc/secrets/nested/api.yaml.encrypted", "enc/secrets/notes.txt")
+
+	cfg := &Config{OutputDir: "enc", Files: []FileMapping{{Input: "k8s/secrets", Output: "secrets"}}}
+	if err := cfg.ExpandFiles(); err != nil {
+		t.Fatalf("ExpandFiles failed: %v", err)
+	}
+
+	expected := []FileMapping{
+		{Input: filepath.Join("k8s", "secrets", "db.yaml"), Output: "secrets/db.yaml.encrypted", Pattern: "k8s/secrets"},
+		{Input: filepath.Join("k8s", "secrets", "nested", "api.yaml"), Output: "secrets/nested/api.yaml.encrypted", Pattern: "k8s/secrets"},
+	}
+	if !reflect.DeepEqual(cfg.Files, expected) {
+		t.Errorf("ExpandFiles() =\n%+v\nexpected\n%+v", cfg.Files, expected)
+	}
+}
+
+func TestExpandFilesSkipsOutputDir(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeTree(t, ".", "secrets/a.txt", "secrets/enc/a.txt.encrypted")
+
+	cfg := &Config{OutputDir: "secrets/enc", Files: []FileMapping{{Input: "secrets/"}}}
+	if err := cfg.ExpandFiles(); err != nil {
+		t.Fatalf("ExpandFiles failed: %v", err)
+	}
+	if len(cfg.Files) != 1 || cfg.Files[0].Input != filepath.Join("secrets", "a.txt") {
+		t.Errorf("Expected only secrets/a.txt, got %+v", cfg.Files)
+	}
+}
+
+func TestExpandFilesInvalidPattern(t *testing.T) {
+	cfg := &Config{OutputDir: "enc", Files: []FileMapping{{Input: "certs/[.pem"}}}
+	if err := cfg.ExpandFiles(); err == nil {
+		t.Error("Expected error for malformed glob")
+	}
+}
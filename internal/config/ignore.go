@@ -0,0 +1,85 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnorePatterns returns the .gitignore patterns, anchored to the working
+// directory, that cover the plaintext side of every environment: inputs,
+// copy_to destinations and test_output_dir. With inputs false the inputs
+// are left out, for when git encrypts them itself.
+//
+// A glob or directory entry gives a single pattern, so files added under it
+// later are covered too; one with exclude patterns lists its files instead,
+// since the excluded ones may be meant for git. Paths outside the working
+// directory cannot be ignored from here and are left out.
+func (c *Config) IgnorePatterns(inputs bool) ([]string, error) {
+	names := c.UsedEnvironments()
+
+	var patterns []string
+	seen := make(map[string]bool)
+	add := func(p string, dir bool) {
+		p = filepath.ToSlash(filepath.Clean(p))
+		if p == "." || filepath.IsAbs(p) || hasDotDot(p) {
+			return
+		}
+		p = "/" + escapeIgnore(p)
+		if dir {
+			p += "/"
+		}
+		if !seen[p] {
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+
+	for _, name := range names {
+		env, err := c.ForEnvironment(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range env.Files {
+			tree := f.isTree(env.OutputDir)
+			if f.CopyTo != "" {
+				add(f.CopyTo, tree)
+			}
+			if !inputs {
+				continue
+			}
+			switch {
+			case !tree:
+				add(f.Input, false)
+			case len(f.Exclude) > 0:
+				files, err := env.expand(f)
+				if err != nil {
+					return nil, err
+				}
+				for _, file := range files {
+					add(file.Input, false)
+				}
+			case hasMeta(f.Input):
+				add(f.Input, false)
+			default:
+				add(f.Input, true)
+			}
+		}
+		if env.TestOutputDir != "" {
+			add(env.TestOutputDir, true)
+		}
+	}
+	return patterns, nil
+}
+
+// escapeIgnore escapes the characters of a literal path that .gitignore
+// would otherwise read as syntax, keeping the glob characters of a pattern
+func escapeIgnore(p string) string {
+	p = strings.ReplaceAll(p, `\`, `\\`)
+	base := path.Base(p)
+	if trimmed := strings.TrimRight(base, " "); trimmed != base {
+		// Trailing spaces are dropped unless escaped
+		p = strings.TrimSuffix(p, base) + trimmed + strings.Repeat(`\ `, len(base)-len(trimmed))
+	}
+	return p
+}
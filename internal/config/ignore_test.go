@@ -0,0 +1,96 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeTree(t, ".",
+		"certs/a.pem", "k8s/secrets/db.yaml", "k8s/secrets/api.yaml.bak",
+	)
+
+	tests := []struct {
+		name     string
+		cfg      *Config
+		inputs   bool
+		expected []string
+	}{
+		{
+			name: "FilesAndCopies",
+			cfg: &Config{OutputDir: "enc", TestOutputDir: "test_dec", Files: []FileMapping{
+				{Input: ".env.prod", Output: ".env.prod.encrypted", CopyTo: "app/.env"},
+				{Input: "./android/key.properties", Output: "key.properties.encrypted"},
+			}},
+			inputs:   true,
+			expected: []string{"/app/.env", "/.env.prod", "/android/key.properties", "/test_dec/"},
+		},
+		{
+			name: "GlobAndDirectory",
+			cfg: &Config{OutputDir: "enc", Files: []FileMapping{
+				{Input: "certs/*.pem", CopyTo: "out/certs"},
+				{Input: "k8s/secrets/"},
+			}},
+			inputs:   true,
+			expected: []string{"/out/certs/", "/certs/*.pem", "/k8s/secrets/"},
+		},
+		{
+			name: "DirectoryWithExcludes",
+			cfg: &Config{OutputDir: "enc", Files: []FileMapping{
+				{Input: "k8s/secrets/", Exclude: []string{"*.bak"}},
+			}},
+			inputs:   true,
+			expected: []string{"/k8s/secrets/db.yaml"},
+		},
+		{
+			name: "WithoutInputs",
+			cfg: &Config{OutputDir: "enc", TestOutputDir: "test_dec", Files: []FileMapping{
+				{Input: ".env.prod", Output: ".env.prod.encrypted", CopyTo: "app/.env"},
+			}},
+			inputs:   false,
+			expected: []string{"/app/.env", "/test_dec/"},
+		},
+		{
+			name: "OutsideWorkingDirectory",
+			cfg: &Config{OutputDir: "enc", Files: []FileMapping{
+				{Input: ".env.prod", Output: ".env.prod.encrypted", CopyTo: "../shared/.env"},
+			}},
+			inputs:   true,
+			expected: []string{"/.env.prod"},
+		},
+		{
+			name: "Environments",
+			cfg: &Config{
+				OutputDir:     "enc",
+				TestOutputDir: "test_dec",
+				Environments: map[string]*Environment{
+					"dev":  {Files: []FileMapping{{Input: ".env.dev", Output: ".env.dev.encrypted"}}},
+					"prod": {TestOutputDir: "test_prod", Files: []FileMapping{{Input: ".env.prod", Output: ".env.prod.encrypted"}}},
+				},
+			},
+			inputs:   true,
+			expected: []string{"/.env.dev", "/test_dec/", "/.env.prod", "/test_prod/"},
+		},
+		{
+			name: "TrailingSpace",
+			cfg: &Config{OutputDir: "enc", Files: []FileMapping{
+				{Input: "odd name ", Output: "odd.encrypted"},
+			}},
+			inputs:   true,
+			expected: []string{`/odd name\ `},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns, err := tt.cfg.IgnorePatterns(tt.inputs)
+			if err != nil {
+				t.Fatalf("IgnorePatterns failed: %v", err)
+			}
+			if !reflect.DeepEqual(patterns, tt.expected) {
+				t.Errorf("IgnorePatterns() = %q, expected %q", patterns, tt.expected)
+			}
+		})
+	}
+}
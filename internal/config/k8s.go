@@ -0,0 +1,70 @@
+package config
+
+import "regexp"
+
+// K8sSecret places a file in a Kubernetes Secret when decrypting with
+// --output-format k8s-secret. Empty fields take the --name and --namespace
+// flags and the defaults below.
+type K8sSecret struct {
+	Name      string `yaml:"name,omitempty"`      // Secret name
+	Namespace string `yaml:"namespace,omitempty"` // Secret namespace
+	Type      string `yaml:"type,omitempty"`      // Secret type, Opaque by default
+	From      string `yaml:"from,omitempty"`      // "file" for one key, "dotenv" for a key per variable; dotenv for .env files
+	Key       string `yaml:"key,omitempty"`       // data key for from: file, the file name by default
+}
+
+// Ways a file becomes Secret data
+const (
+	K8sFromFile   = "file"
+	K8sFromDotenv = "dotenv"
+)
+
+var (
+	secretKeyPattern  = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	secretNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	namespacePattern  = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// ValidSecretKey reports whether key can name an entry of a Secret's data
+func ValidSecretKey(key string) bool {
+	return len(key) <= 253 && secretKeyPattern.MatchString(key)
+}
+
+// ValidSecretName reports whether name is a valid Secret name, a DNS
+// subdomain
+func ValidSecretName(name string) bool {
+	return len(name) <= 253 && secretNamePattern.MatchString(name)
+}
+
+// ValidNamespace reports whether name is a valid namespace, a DNS label
+func ValidNamespace(name string) bool {
+	return len(name) <= 63 && namespacePattern.MatchString(name)
+}
+
+// checkK8s validates a file entry's k8s block
+func (v *validator) checkK8s(f FileMapping, tree bool) {
+	k := f.K8s
+	switch k.From {
+	case "", K8sFromFile, K8sFromDotenv:
+	default:
+		v.add(f.pos, "k8s.from %q must be %s or %s", k.From, K8sFromFile, K8sFromDotenv)
+	}
+	if k.Name != "" && !ValidSecretName(k.Name) {
+		v.add(f.pos, "k8s.name %q is not a valid Secret name (lowercase letters, digits, '-' and '.')", k.Name)
+	}
+	if k.Namespace != "" && !ValidNamespace(k.Namespace) {
+		v.add(f.pos, "k8s.namespace %q is not a valid namespace (lowercase letters, digits and '-')", k.Namespace)
+	}
+
+	if k.Key == "" {
+		return
+	}
+	switch {
+	case k.From == K8sFromDotenv:
+		v.add(f.pos, "k8s.key cannot be used with from: dotenv, which keys each variable by name")
+	case tree:
+		v.add(f.pos, "k8s.key would give every file matching %s the same key", f.Input)
+	case !ValidSecretKey(k.Key):
+		v.add(f.pos, "k8s.key %q may only contain letters, digits, '-', '_' and '.'", k.Key)
+	}
+}
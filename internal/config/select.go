@@ -0,0 +1,79 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Selector picks a subset of the configured files by pattern. A pattern
+// names a file by its input path or a directory above it, its output name,
+// a glob over either, the glob or directory entry it was expanded from, or
+// one of its tags.
+type Selector struct {
+	Only   []string // keep only files matching one of these; all when empty
+	Except []string // then drop files matching any of these
+}
+
+// Empty reports whether the selector keeps every file
+func (s Selector) Empty() bool {
+	return len(s.Only) == 0 && len(s.Except) == 0
+}
+
+// Select narrows Files to those picked by s, keeping their order, and
+// returns the patterns that matched no configured file
+func (c *Config) Select(s Selector) (unmatched []string) {
+	used := make(map[string]bool)
+	var files []FileMapping
+	for _, f := range c.Files {
+		keep := len(s.Only) == 0
+		for _, p := range s.Only {
+			if f.Matches(p) {
+				keep = true
+				used[p] = true
+			}
+		}
+		for _, p := range s.Except {
+			if f.Matches(p) {
+				keep = false
+				used[p] = true
+			}
+		}
+		if keep {
+			files = append(files, f)
+		}
+	}
+	c.Files = files
+
+	for _, p := range append(append([]string{}, s.Only...), s.Except...) {
+		if !used[p] {
+			unmatched = append(unmatched, p)
+			used[p] = true
+		}
+	}
+	return unmatched
+}
+
+// Matches reports whether pattern names this file; see Selector
+func (f FileMapping) Matches(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if tag == pattern {
+			return true
+		}
+	}
+
+	p := filepath.ToSlash(filepath.Clean(pattern))
+	input := filepath.ToSlash(filepath.Clean(f.Input))
+	switch {
+	case p == input || pattern == f.Output:
+		return true
+	case f.Pattern != "" && p == filepath.ToSlash(filepath.Clean(f.Pattern)):
+		return true
+	case p != "." && strings.HasPrefix(input, strings.TrimSuffix(p, "/")+"/"):
+		return true
+	}
+	return matchPath(p, input) || matchPath(p, f.Output) || matchPath(p, path.Base(f.Output))
+}
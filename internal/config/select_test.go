@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	files := []FileMapping{
+		{Input: "android/app/keystore.jks", Output: "keystore.jks.encrypted", Tags: []string{"android", "mobile"}},
+		{Input: "android/key.properties", Output: "key.properties.encrypted", Tags: []string{"android", "mobile"}},
+		{Input: "ios/GoogleService-Info.plist", Output: "GoogleService-Info.plist.encrypted", Tags: []string{"ios", "mobile"}},
+		{Input: ".env.prod", Output: ".env.prod.encrypted", Tags: []string{"backend"}},
+		{Input: "certs/a.pem", Output: "certs/a.pem.encrypted", Pattern: "certs/*.pem"},
+	}
+
+	tests := []struct {
+		name      string
+		selector  Selector
+		expected  []string
+		unmatched []string
+	}{
+		{
+			name:     "All",
+			expected: []string{"android/app/keystore.jks", "android/key.properties", "ios/GoogleService-Info.plist", ".env.prod", "certs/a.pem"},
+		},
+		{
+			name:     "InputPath",
+			selector: Selector{Only: []string{"./android/app/keystore.jks"}},
+			expected: []string{"android/app/keystore.jks"},
+		},
+		{
+			name:     "OutputName",
+			selector: Selector{Only: []string{".env.prod.encrypted"}},
+			expected: []string{".env.prod"},
+		},
+		{
+			name:     "Directory",
+			selector: Selector{Only: []string{"android/"}},
+			expected: []string{"android/app/keystore.jks", "android/key.properties"},
+		},
+		{
+			name:     "Glob",
+			selector: Selector{Only: []string{"*/*.plist", "*.jks.encrypted"}},
+			expected: []string{"android/app/keystore.jks", "ios/GoogleService-Info.plist"},
+		},
+		{
+			name:     "ExpandedFrom",
+			selector: Selector{Only: []string{"certs/*.pem"}},
+			expected: []string{"certs/a.pem"},
+		},
+		{
+			name:     "Tag",
+			selector: Selector{Only: []string{"ios", "backend"}},
+			expected: []string{"ios/GoogleService-Info.plist", ".env.prod"},
+		},
+		{
+			name:     "Except",
+			selector: Selector{Only: []string{"mobile"}, Except: []string{"ios"}},
+			expected: []string{"android/app/keystore.jks", "android/key.properties"},
+		},
+		{
+			name:     "ExceptOnly",
+			selector: Selector{Except: []string{"mobile", "certs/a.pem"}},
+			expected: []string{".env.prod"},
+		},
+		{
+			name:      "Unmatched",
+			selector:  Selector{Only: []string{"backend", "web"}, Except: []string{"staging"}},
+			expected:  []string{".env.prod"},
+			unmatched: []string{"web", "staging"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Files: append([]FileMapping{}, files...)}
+			unmatched := cfg.Select(tt.selector)
+
+			var inputs []string
+			for _, f := range cfg.Files {
+				inputs = append(inputs, f.Input)
+			}
+			if !reflect.DeepEqual(inputs, tt.expected) {
+				t.Errorf("Selected %v, expected %v", inputs, tt.expected)
+			}
+			if !reflect.DeepEqual(unmatched, tt.unmatched) {
+				t.Errorf("Unmatched %v, expected %v", unmatched, tt.unmatched)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/values"
+	"gopkg.in/yaml.v3"
+)
+
+// position is where a value starts in the config file; zero when unknown
+type position struct {
+	line, column int
+}
+
+// Problem is one mistake found in a config file
+type Problem struct {
+	Path    string // config file, empty when not loaded from a file
+	Line    int    // zero when the position is unknown
+	Column  int
+	Message string
+}
+
+// String renders the problem as path:line:column: message
+func (p Problem) String() string {
+	switch {
+	case p.Path != "" && p.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", p.Path, p.Line, p.Column, p.Message)
+	case p.Line > 0:
+		return fmt.Sprintf("line %d, column %d: %s", p.Line, p.Column, p.Message)
+	case p.Path != "":
+		return fmt.Sprintf("%s: %s", p.Path, p.Message)
+	default:
+		return p.Message
+	}
+}
+
+// ValidationError lists every problem found in a config file
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].String()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  " + p.String()
+	}
+	return fmt.Sprintf("%d problems:\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// Validate checks the top level and every environment for mistakes that
+// decoding alone does not catch, such as two entries writing the same
+// encrypted file. It returns a *ValidationError listing all of them.
+func (c *Config) Validate() error {
+	v := &validator{path: c.path, seen: make(map[Problem]bool)}
+
+	if len(c.Environments) == 0 || len(c.Files) > 0 {
+		v.check(c)
+	}
+	for _, name := range c.EnvironmentNames() {
+		env, err := c.ForEnvironment(name)
+		if err != nil {
+			pos := position{}
+			if e := c.Environments[name]; e != nil {
+				pos = e.pos
+			}
+			v.add(pos, "%v", err)
+			continue
+		}
+		v.check(env)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator collects problems, reporting each one once even when the
+// entry is shared by several environments
+type validator struct {
+	path     string
+	problems []Problem
+	seen     map[Problem]bool
+}
+
+func (v *validator) add(pos position, format string, args ...interface{}) {
+	p := Problem{Path: v.path, Line: pos.line, Column: pos.column, Message: fmt.Sprintf(format, args...)}
+	if !v.seen[p] {
+		v.seen[p] = true
+		v.problems = append(v.problems, p)
+	}
+}
+
+// check validates one selected configuration
+func (v *validator) check(c *Config) {
+	if c.OutputDir == "" {
+		v.add(position{}, "output_dir is required")
+	}
+	if p := c.Password; p != nil {
+		set := 0
+		for _, s := range []string{p.Env, p.File, p.Command} {
+			if s != "" {
+				set++
+			}
+		}
+		if set > 1 {
+			v.add(position{}, "password: set only one of env, file and command")
+		}
+	}
+
+	outputs := make(map[string]FileMapping)
+	inputs := make(map[string]FileMapping)
+	for _, f := range c.Files {
+		if f.Input != "" && !f.isTree(c.OutputDir) {
+			inputs[filepath.Clean(f.Input)] = f
+		}
+	}
+
+	for _, f := range c.Files {
+		if f.Input == "" {
+			v.add(f.pos, "input is required")
+			continue
+		}
+		tree := f.isTree(c.OutputDir)
+
+		switch {
+		case f.Output == "" && !tree:
+			v.add(f.pos, "output is required for %s", f.Input)
+		case filepath.IsAbs(f.Output) || strings.HasPrefix(f.Output, "/"):
+			v.add(f.pos, "output %q must be relative to output_dir", f.Output)
+		case hasDotDot(f.Output):
+			v.add(f.pos, "output %q must not contain ..", f.Output)
+		case !tree && strings.ContainsAny(f.Output, `/\`):
+			v.add(f.pos, "output %q must be a file name, not a path", f.Output)
+		case f.Output != "":
+			if other, ok := outputs[f.Output]; ok {
+				v.add(f.pos, "output %q is also used by %s%s", f.Output, other.Input, other.pos.onLine())
+			} else {
+				outputs[f.Output] = f
+			}
+		}
+
+		base := f.Input
+		if tree {
+			base, _ = splitPattern(f.Input)
+		}
+		if c.OutputDir != "" && within(c.OutputDir, base) {
+			v.add(f.pos, "input %s is inside output_dir %s", f.Input, c.OutputDir)
+		}
+
+		if f.Mode != 0 && f.Mode&0400 == 0 {
+			v.add(f.pos, "mode %s would leave %s unreadable by its owner", f.Mode, f.Input)
+		}
+
+		if f.K8s != nil {
+			v.checkK8s(f, tree)
+		}
+
+		switch f.Encryption {
+		case "", EncryptFile:
+		case EncryptValues:
+			if _, err := values.DetectSyntax(f.Input); err != nil && !tree {
+				v.add(f.pos, "%s", err)
+			}
+		default:
+			v.add(f.pos, "encryption %q must be %s or %s", f.Encryption, EncryptFile, EncryptValues)
+		}
+
+		if f.CopyTo != "" && !tree {
+			if other, ok := inputs[filepath.Clean(f.CopyTo)]; ok {
+				v.add(f.pos, "copy_to %s would overwrite the input of %s%s", f.CopyTo, other.Input, other.pos.onLine())
+			}
+		}
+	}
+}
+
+// onLine describes the position for messages about another entry
+func (p position) onLine() string {
+	if p.line == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (line %d)", p.line)
+}
+
+// hasDotDot reports whether any element of a slash- or
+// backslash-separated path is ..
+func hasDotDot(p string) bool {
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is dir or lies below it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// unknownFields walks a decoded YAML node alongside the Go type it will be
+// decoded into and reports every mapping key that has no matching field
+func unknownFields(path string, node *yaml.Node, t reflect.Type, where string) []Problem {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var problems []Problem
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				// Merge key
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown field %q%s", key.Value, where)
+				if suggestion := closest(key.Value, fields); suggestion != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				problems = append(problems, Problem{Path: path, Line: key.Line, Column: key.Column, Message: msg})
+				continue
+			}
+			problems = append(problems, unknownFields(path, value, field, " in "+key.Value)...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			problems = append(problems, unknownFields(path, item, t.Elem(), where)...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			problems = append(problems, unknownFields(path, node.Content[i], t.Elem(), where)...)
+		}
+	}
+	return problems
+}
+
+// yamlFields maps the YAML names of a struct's fields to their types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// closest returns the field name within two edits of name, if any
+func closest(name string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for field := range fields {
+		if d := editDistance(name, field); d < bestDistance || (d == bestDistance && field < best) {
+			best, bestDistance = field, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// recordPositions remembers where each file entry and environment starts,
+// for validation messages
+func (c *Config) recordPositions(doc *yaml.Node) {
+	for i, item := range sequence(mappingValue(doc, "files")) {
+		if i < len(c.Files) {
+			c.Files[i].pos = position{item.Line, item.Column}
+		}
+	}
+
+	envs := mappingValue(doc, "environments")
+	if envs == nil || envs.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(envs.Content); i += 2 {
+		key, value := envs.Content[i], envs.Content[i+1]
+		env := c.Environments[key.Value]
+		if env == nil {
+			continue
+		}
+		env.pos = position{key.Line, key.Column}
+		for j, item := range sequence(mappingValue(value, "files")) {
+			if j < len(env.Files) {
+				env.Files[j].pos = position{item.Line, item.Column}
+			}
+		}
+	}
+}
+
+// mappingValue returns the value for key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequence returns the items of a sequence node, or nil
+func sequence(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
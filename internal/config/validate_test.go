@@ -0,0 +1,278 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadUnknownField(t *testing.T) {
+	cfg := `output_dir: enc
+files:
+  - input: .env
+    outptu: .env.encrypted
+`
+	path := writeConfig(t, cfg)
+
+	_, err := Load(path)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	if len(invalid.Problems) != 1 {
+		t.Fatalf("Expected 1 problem, got %d", len(invalid.Problems))
+	}
+
+	p := invalid.Problems[0]
+	if p.Line != 4 || p.Column != 5 {
+		t.Errorf("Expected line 4, column 5, got line %d, column %d", p.Line, p.Column)
+	}
+	if !strings.Contains(p.Message, `"outptu"`) || !strings.Contains(p.Message, `did you mean "output"`) {
+		t.Errorf("Unexpected message: %s", p.Message)
+	}
+	if !strings.HasPrefix(p.String(), path+":4:5: ") {
+		t.Errorf("Expected path:line:column prefix, got %s", p)
+	}
+}
+
+func TestLoadUnknownTopLevelField(t *testing.T) {
+	path := writeConfig(t, "output_dir: enc\noutput_directory: enc\nfiles: []\n")
+
+	var invalid *ValidationError
+	if _, err := Load(path); !errors.As(err, &invalid) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		problem string // empty when the config is valid
+		line    int
+	}{
+		{
+			name: "Valid",
+			yaml: `output_dir: enc
+files:
+  - input: .env.prod
+    output: .env.prod.encrypted
+    copy_to: .env
+`,
+		},
+		{
+			name:    "MissingOutputDir",
+			yaml:    "files:\n  - input: a\n    output: a.encrypted\n",
+			problem: "output_dir is required",
+		},
+		{
+			name: "DuplicateOutput",
+			yaml: `output_dir: enc
+files:
+  - input: a
+    output: secret.encrypted
+  - input: b
+    output: secret.encrypted
+`,
+			problem: `output "secret.encrypted" is also used by a (line 3)`,
+			line:    5,
+		},
+		{
+			name:    "OutputIsPath",
+			yaml:    "output_dir: enc\nfiles:\n  - input: a\n    output: sub/a.encrypted\n",
+			problem: "must be a file name",
+			line:    3,
+		},
+		{
+			name:    "OutputEscapes",
+			yaml:    "output_dir: enc\nfiles:\n  - input: a\n    output: ../a.encrypted\n",
+			problem: "must not contain ..",
+			line:    3,
+		},
+		{
+			name:    "MissingOutput",
+			yaml:    "output_dir: enc\nfiles:\n  - input: a\n",
+			problem: "output is required",
+			line:    3,
+		},
+		{
+			name:    "UnreadableMode",
+			yaml:    "output_dir: enc\nfiles:\n  - input: a\n    output: a.encrypted\n    mode: 0200\n",
+			problem: "mode 0200 would leave a unreadable by its owner",
+			line:    3,
+		},
+		{
+			name:    "InputInsideOutputDir",
+			yaml:    "output_dir: secrets/enc\nfiles:\n  - input: secrets/enc/a\n    output: a.encrypted\n",
+			problem: "inside output_dir",
+			line:    3,
+		},
+		{
+			name: "CopyToOverwritesInput",
+			yaml: `output_dir: enc
+files:
+  - input: .env
+    output: env.encrypted
+  - input: .env.prod
+    output: env.prod.encrypted
+    copy_to: ./.env
+`,
+			problem: "copy_to ./.env would overwrite the input of .env",
+			line:    5,
+		},
+		{
+			name: "EnvironmentDuplicateOutput",
+			yaml: `output_dir: enc
+files:
+  - input: shared
+    output: shared.encrypted
+environments:
+  prod:
+    files:
+      - input: other
+        output: shared.encrypted
+`,
+			problem: `output "shared.encrypted" is also used by shared`,
+			line:    8,
+		},
+		{
+			name:    "EnvironmentWithoutOutputDir",
+			yaml:    "environments:\n  prod:\n    files:\n      - input: a\n        output: a.encrypted\n",
+			problem: `environment "prod" has no output_dir`,
+			line:    2,
+		},
+		{
+			name:    "SeveralPasswordSources",
+			yaml:    "output_dir: enc\npassword:\n  env: PW\n  file: pw.txt\nfiles: []\n",
+			problem: "set only one of env, file and command",
+		},
+		{
+			name: "ValidK8s",
+			yaml: `output_dir: enc
+files:
+  - input: certs/tls.crt
+    output: tls.crt.encrypted
+    k8s:
+      name: web-tls
+      namespace: prod
+      type: kubernetes.io/tls
+      key: tls.crt
+`,
+		},
+		{
+			name:    "K8sUnknownFrom",
+			yaml:    "output_dir: enc\nfiles:\n  - input: a\n    output: a.encrypted\n    k8s:\n      from: json\n",
+			problem: `k8s.from "json" must be file or dotenv`,
+			line:    3,
+		},
+		{
+			name:    "K8sInvalidName",
+			yaml:    "output_dir: enc\nfiles:\n  - input: a\n    output: a.encrypted\n    k8s:\n      name: My_Secret\n",
+			problem: `k8s.name "My_Secret" is not a valid Secret name`,
+			line:    3,
+		},
+		{
+			name:    "K8sKeyWithDotenv",
+			yaml:    "output_dir: enc\nfiles:\n  - input: .env\n    output: env.encrypted\n    k8s:\n      from: dotenv\n      key: env\n",
+			problem: "k8s.key cannot be used with from: dotenv",
+			line:    3,
+		},
+		{
+			name:    "K8sKeyOnGlob",
+			yaml:    "output_dir: enc\nfiles:\n  - input: certs/*.pem\n    output: certs\n    k8s:\n      key: cert.pem\n",
+			problem: "k8s.key would give every file matching certs/*.pem the same key",
+			line:    3,
+		},
+		{
+			name: "ValuesEncryption",
+			yaml: "output_dir: enc\nformat: aead\nfiles:\n  - input: .env\n    output: .env.encrypted\n    encryption: values\n  - input: config/*.yaml\n    output: config\n    encryption: values\n",
+		},
+		{
+			name:    "UnknownEncryption",
+			yaml:    "output_dir: enc\nfiles:\n  - input: .env\n    output: .env.encrypted\n    encryption: keys\n",
+			problem: `encryption "keys" must be file or values`,
+			line:    3,
+		},
+		{
+			name:    "ValuesEncryptionUnknownSyntax",
+			yaml:    "output_dir: enc\nfiles:\n  - input: keystore.jks\n    output: keystore.jks.encrypted\n    encryption: values\n",
+			problem: "cannot tell the syntax of keystore.jks",
+			line:    3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, tt.yaml))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			err = cfg.Validate()
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("Expected valid config, got %v", err)
+				}
+				return
+			}
+
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Expected ValidationError, got %v", err)
+			}
+			for _, p := range invalid.Problems {
+				if strings.Contains(p.Message, tt.problem) {
+					if p.Line != tt.line {
+						t.Errorf("Expected problem on line %d, got %d", tt.line, p.Line)
+					}
+					return
+				}
+			}
+			t.Errorf("Expected problem containing %q, got %v", tt.problem, err)
+		})
+	}
+}
+
+func TestValidateReportsSharedProblemsOnce(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `output_dir: enc
+files:
+  - input: a
+environments:
+  prod:
+    output_dir: prod
+  staging:
+    output_dir: staging
+`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var invalid *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &invalid) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	if len(invalid.Problems) != 1 {
+		t.Errorf("Expected 1 problem, got %d: %v", len(invalid.Problems), invalid)
+	}
+}
+
+func TestTemplatesAreValid(t *testing.T) {
+	for _, name := range []string{"default", "reactnative", "flutter", "web", "docker", "k8s", "microservices"} {
+		if err := TemplateConfig(name).Validate(); err != nil {
+			t.Errorf("Template %s is invalid: %v", name, err)
+		}
+	}
+}
+
+func TestExpandFilesDuplicateOutput(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeTree(t, ".", "certs/a.pem")
+
+	cfg := &Config{OutputDir: "enc", Files: []FileMapping{
+		{Input: "certs/*.pem"},
+		{Input: "other.pem", Output: "certs/a.pem.encrypted"},
+	}}
+	if err := cfg.ExpandFiles(); err == nil {
+		t.Error("Expected error when a glob and a file share an output")
+	}
+}
@@ -0,0 +1,399 @@
+package crypto
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// The AEAD container is laid out as:
+//
+//	magic (8) | version (1) | kdf id (1) | kdf params length (2) |
+//	kdf params | salt (16) | nonce (12) | header MAC (32) | payload
+//
+// The kdf params length covers both the function-specific parameters and
+// the salt that follows them.
+//
+// The header MAC is an HMAC-SHA256 over every preceding header byte, keyed
+// with a subkey of the password-derived key. It lets decryption tell a wrong
+// password apart from a corrupted or tampered payload.
+//
+// In version 2 the payload is a sequence of AES-256-GCM sealed chunks of
+// aeadChunkSize plaintext bytes, the last of which may be shorter (or empty).
+// Chunk i is sealed with the header nonce XORed with i, and with the whole
+// header plus a final-chunk flag as additional data, so chunks cannot be
+// reordered, dropped or truncated without detection. Version 1, a single
+// sealed payload, is still accepted when decrypting. A version 3 header has
+// no payload; it keys the values of a values document (see values.go).
+const (
+	aeadMagic         = "SFLOWENC"
+	aeadVersion1      = 1
+	aeadVersion2      = 2
+	aeadVersionValues = 3
+	aeadSaltSize      = 16
+	aeadNonceSize     = 12
+	headerMACSize     = sha256.Size
+	aeadChunkSize     = 64 * 1024
+)
+
+// aeadHeader holds the parsed fields of an AEAD container header. A file is
+// either password-based (kdf) or encrypted to recipients (stanzas).
+type aeadHeader struct {
+	version byte
+	kdf     KDFParams
+	stanzas [][]byte
+	salt    []byte
+	nonce   []byte
+}
+
+// marshal encodes the header fields that precede the header MAC
+func (h *aeadHeader) marshal() []byte {
+	var id byte
+	var params []byte
+	if len(h.stanzas) > 0 {
+		id, params = kdfX25519, marshalStanzas(h.stanzas)
+	} else {
+		id, params = h.kdf.marshal()
+	}
+	params = append(params, h.salt...)
+
+	out := []byte(aeadMagic)
+	out = append(out, h.version, id)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(params)))
+	out = append(out, params...)
+	return append(out, h.nonce...)
+}
+
+// parseAEADHeader decodes the header at the start of data. It returns the
+// header, the raw bytes covered by the header MAC, and the MAC itself.
+func parseAEADHeader(data []byte) (*aeadHeader, []byte, []byte, error) {
+	fixed := len(aeadMagic) + 4
+	if len(data) < fixed || string(data[:len(aeadMagic)]) != aeadMagic {
+		return nil, nil, nil, ErrInvalidFormat
+	}
+
+	h := &aeadHeader{version: data[len(aeadMagic)]}
+	if h.version != aeadVersion1 && h.version != aeadVersion2 && h.version != aeadVersionValues {
+		return nil, nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidFormat, h.version)
+	}
+
+	paramsLen := int(binary.BigEndian.Uint16(data[len(aeadMagic)+2:]))
+	end := fixed + paramsLen + aeadNonceSize
+	if len(data) < end+headerMACSize {
+		return nil, nil, nil, fmt.Errorf("%w: truncated header", ErrInvalidFormat)
+	}
+	if paramsLen < aeadSaltSize {
+		return nil, nil, nil, fmt.Errorf("%w: malformed key derivation parameters", ErrInvalidFormat)
+	}
+	params := data[fixed : fixed+paramsLen-aeadSaltSize]
+
+	if id := data[len(aeadMagic)+1]; id == kdfX25519 {
+		stanzas, err := unmarshalStanzas(params)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		h.stanzas = stanzas
+	} else {
+		kdf, err := unmarshalKDFParams(id, params)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		h.kdf = kdf
+	}
+	h.salt = data[fixed+paramsLen-aeadSaltSize : fixed+paramsLen]
+
+	h.nonce = data[fixed+paramsLen : end]
+	return h, data[:end], data[end : end+headerMACSize], nil
+}
+
+// masterKey recovers the key that the payload and header MAC keys are derived
+// from: the password-derived key, or the file key unwrapped by an identity.
+// keys, when set, remembers password-derived keys.
+func (h *aeadHeader) masterKey(password []byte, identities []*Identity, keys *KeyCache) ([]byte, error) {
+	if len(h.stanzas) == 0 {
+		return keys.deriveKey(h.kdf, password, h.salt)
+	}
+	return unwrapFileKey(h.stanzas, h.salt, identities)
+}
+
+// deriveAEADKeys derives the payload key and the header MAC key from master
+func deriveAEADKeys(master []byte) (encKey, macKey []byte, err error) {
+	encKey = make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("secureflow aead payload")), encKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	macKey = make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("secureflow aead header")), macKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return encKey, macKey, nil
+}
+
+// headerMAC computes the MAC that authenticates the encoded header
+func headerMAC(macKey, raw []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(raw)
+	return mac.Sum(nil)
+}
+
+// newGCM returns an AES-256-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// readAEADHeader reads and parses the header at the start of r
+func readAEADHeader(r io.Reader) (*aeadHeader, []byte, []byte, error) {
+	fixed := make([]byte, len(aeadMagic)+4)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: truncated header", ErrInvalidFormat)
+	}
+
+	paramsLen := int(binary.BigEndian.Uint16(fixed[len(aeadMagic)+2:]))
+	data := make([]byte, len(fixed)+paramsLen+aeadNonceSize+headerMACSize)
+	copy(data, fixed)
+	if _, err := io.ReadFull(r, data[len(fixed):]); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: truncated header", ErrInvalidFormat)
+	}
+
+	return parseAEADHeader(data)
+}
+
+// chunkNonce returns the nonce for chunk i: the base nonce with the
+// big-endian chunk counter XORed into its last eight bytes
+func chunkNonce(base []byte, i uint64) []byte {
+	nonce := append([]byte(nil), base...)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], i)
+	for j, b := range counter {
+		nonce[len(nonce)-8+j] ^= b
+	}
+	return nonce
+}
+
+// chunkAAD returns the additional data for a chunk
+func chunkAAD(header []byte, final bool) []byte {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	return append(append([]byte(nil), header...), flag)
+}
+
+// newAEADHeader creates a header with a fresh salt and nonce read from rnd,
+// and returns it with its master key: a random file key wrapped to each
+// recipient, or the key derived from the password. With shared set, a
+// password-based header takes the salt, and so the key, that shared gives
+// every file; only its nonce is fresh.
+func newAEADHeader(version byte, password []byte, kdf KDFParams, recipients []*Recipient, rnd io.Reader, shared *KeyCache) (*aeadHeader, []byte, error) {
+	h := &aeadHeader{
+		version: version,
+		salt:    make([]byte, aeadSaltSize),
+		nonce:   make([]byte, aeadNonceSize),
+	}
+	if len(recipients) == 0 {
+		var err error
+		if h.kdf, err = kdf.withDefaults(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(recipients) == 0 && shared != nil {
+		salt, err := shared.sharedSalt(h.kdf, password, rnd)
+		if err != nil {
+			return nil, nil, err
+		}
+		copy(h.salt, salt)
+	} else if _, err := io.ReadFull(rnd, h.salt); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := io.ReadFull(rnd, h.nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	if len(recipients) > 0 {
+		master := make([]byte, keySize)
+		if _, err := io.ReadFull(rand.Reader, master); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate file key: %w", err)
+		}
+		for _, recipient := range recipients {
+			stanza, err := wrapFileKey(master, h.salt, recipient)
+			if err != nil {
+				return nil, nil, err
+			}
+			h.stanzas = append(h.stanzas, stanza)
+		}
+		return h, master, nil
+	}
+
+	master, err := shared.deriveKey(h.kdf, password, h.salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, master, nil
+}
+
+// sealAEAD streams plaintext from r into an AEAD container written to w.
+// With recipients, a random file key is wrapped to each of them and the
+// password is ignored. shared is passed to newAEADHeader.
+func sealAEAD(r io.Reader, w io.Writer, password []byte, kdf KDFParams, recipients []*Recipient, rnd io.Reader, shared *KeyCache) error {
+	h, master, err := newAEADHeader(aeadVersion2, password, kdf, recipients, rnd, shared)
+	if err != nil {
+		return err
+	}
+
+	encKey, macKey, err := deriveAEADKeys(master)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(encKey)
+	if err != nil {
+		return err
+	}
+
+	header := h.marshal()
+	header = append(header, headerMAC(macKey, header)...)
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return sealChunks(r, w, gcm, h.nonce, header)
+}
+
+// sealChunks encrypts r chunk by chunk, flagging the chunk that ends the input
+func sealChunks(r io.Reader, w io.Writer, gcm cipher.AEAD, nonce, header []byte) error {
+	br := bufio.NewReaderSize(r, aeadChunkSize)
+	buf := make([]byte, aeadChunkSize)
+	out := make([]byte, 0, aeadChunkSize+gcm.Overhead())
+
+	for i := uint64(0); ; i++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		final := err != nil
+		if !final {
+			if _, err := br.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+		}
+
+		out = gcm.Seal(out[:0], chunkNonce(nonce, i), buf[:n], chunkAAD(header, final))
+		if _, err := w.Write(out); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+
+		if final {
+			return nil
+		}
+	}
+}
+
+// openAEAD authenticates and decrypts an AEAD container read from r
+func openAEAD(r io.Reader, w io.Writer, password []byte, identities []*Identity, keys *KeyCache) error {
+	h, raw, mac, err := readAEADHeader(r)
+	if err != nil {
+		return err
+	}
+	if h.version == aeadVersionValues {
+		// Only found inside a values document, never at the start of a file
+		return fmt.Errorf("%w: unexpected version %d", ErrInvalidFormat, h.version)
+	}
+
+	master, err := h.masterKey(password, identities, keys)
+	if err != nil {
+		return err
+	}
+	encKey, macKey, err := deriveAEADKeys(master)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, headerMAC(macKey, raw)) {
+		if len(h.stanzas) > 0 {
+			// The file key authenticated, so the header itself was altered
+			return ErrCorrupted
+		}
+		return ErrWrongPassword
+	}
+
+	gcm, err := newGCM(encKey)
+	if err != nil {
+		return err
+	}
+
+	header := append(append([]byte(nil), raw...), mac...)
+	if h.version == aeadVersion1 {
+		return openSingle(r, w, gcm, h.nonce, header)
+	}
+	return openChunks(r, w, gcm, h.nonce, header)
+}
+
+// openSingle decrypts a version 1 payload sealed as one GCM message
+func openSingle(r io.Reader, w io.Writer, gcm cipher.AEAD, nonce, header []byte) error {
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted data: %w", err)
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return ErrCorrupted
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// openChunks decrypts a version 2 chunked payload, writing each chunk only
+// after it has been authenticated
+func openChunks(r io.Reader, w io.Writer, gcm cipher.AEAD, nonce, header []byte) error {
+	br := bufio.NewReaderSize(r, aeadChunkSize+gcm.Overhead())
+	buf := make([]byte, aeadChunkSize+gcm.Overhead())
+	out := make([]byte, 0, aeadChunkSize)
+
+	for i := uint64(0); ; i++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read encrypted data: %w", err)
+		}
+
+		final := err != nil
+		if !final {
+			if _, err := br.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return fmt.Errorf("failed to read encrypted data: %w", err)
+			}
+		}
+
+		out, err = gcm.Open(out[:0], chunkNonce(nonce, i), buf[:n], chunkAAD(header, final))
+		if err != nil {
+			return ErrCorrupted
+		}
+		if _, err := w.Write(out); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+
+		if final {
+			return nil
+		}
+	}
+}
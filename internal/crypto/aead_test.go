@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecryptFileAEAD(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	inputPath := filepath.Join(tmpDir, "secret.txt")
+	testContent := "API_KEY=abc123\nDB_PASSWORD=hunter2\n"
+	if err := os.WriteFile(inputPath, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test input file: %v", err)
+	}
+
+	encryptedPath := filepath.Join(tmpDir, "secret.txt.encrypted")
+	decryptedPath := filepath.Join(tmpDir, "secret_decrypted.txt")
+	password := "test_password_123"
+
+	if err := EncryptFileWithOptions(inputPath, encryptedPath, password, Options{Format: FormatAEAD}); err != nil {
+		t.Fatalf("EncryptFileWithOptions failed: %v", err)
+	}
+
+	data, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatalf("Failed to read encrypted file: %v", err)
+	}
+	if format, err := DetectFormat(data); err != nil || format != FormatAEAD {
+		t.Fatalf("Expected AEAD format, got %q (err: %v)", format, err)
+	}
+
+	t.Run("Decrypt", func(t *testing.T) {
+		if err := DecryptFile(encryptedPath, decryptedPath, password); err != nil {
+			t.Fatalf("DecryptFile failed: %v", err)
+		}
+
+		decrypted, err := os.ReadFile(decryptedPath)
+		if err != nil {
+			t.Fatalf("Failed to read decrypted file: %v", err)
+		}
+		if string(decrypted) != testContent {
+			t.Errorf("Decrypted content doesn't match original.\nExpected: %q\nGot: %q", testContent, string(decrypted))
+		}
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		err := DecryptFile(encryptedPath, decryptedPath, "wrong_password")
+		if !errors.Is(err, ErrWrongPassword) {
+			t.Fatalf("Expected ErrWrongPassword, got %v", err)
+		}
+	})
+
+	t.Run("TamperedPayload", func(t *testing.T) {
+		tampered := append([]byte(nil), data...)
+		tampered[len(tampered)-20] ^= 0x01
+		tamperedPath := filepath.Join(tmpDir, "tampered.encrypted")
+		if err := os.WriteFile(tamperedPath, tampered, 0644); err != nil {
+			t.Fatalf("Failed to write tampered file: %v", err)
+		}
+
+		err := DecryptFile(tamperedPath, decryptedPath, password)
+		if !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("Expected ErrCorrupted, got %v", err)
+		}
+	})
+
+	t.Run("TruncatedHeader", func(t *testing.T) {
+		truncatedPath := filepath.Join(tmpDir, "truncated.encrypted")
+		if err := os.WriteFile(truncatedPath, data[:len(aeadMagic)+6], 0644); err != nil {
+			t.Fatalf("Failed to write truncated file: %v", err)
+		}
+
+		err := DecryptFile(truncatedPath, decryptedPath, password)
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Fatalf("Expected ErrInvalidFormat, got %v", err)
+		}
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Format
+		wantErr  bool
+	}{
+		{"", FormatOpenSSL, false},
+		{"openssl", FormatOpenSSL, false},
+		{"cbc", FormatOpenSSL, false},
+		{"aead", FormatAEAD, false},
+		{"gcm", FormatAEAD, false},
+		{"rot13", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ParseFormat(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if format != tt.expected {
+				t.Errorf("ParseFormat(%q) = %q, expected %q", tt.name, format, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	if format, err := DetectFormat([]byte(saltedPrefix + "12345678")); err != nil || format != FormatOpenSSL {
+		t.Errorf("Expected OpenSSL format, got %q (err: %v)", format, err)
+	}
+	if _, err := DetectFormat([]byte("plain text")); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat, got %v", err)
+	}
+}
@@ -1,14 +1,19 @@
 package crypto
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"github.com/MayR-Labs/secureflow-go/internal/values"
 	"golang.org/x/crypto/pbkdf2"
 )
 
@@ -19,113 +24,448 @@ const (
 	keySize      = 32 // AES-256
 	ivSize       = 16 // AES block size
 	pbkdf2Iter   = 10000
+
+	// streamChunkSize is how much plaintext is processed at a time; it is a
+	// multiple of the AES block size
+	streamChunkSize = 64 * 1024
+)
+
+// Format identifies the container format of an encrypted file
+type Format string
+
+const (
+	// FormatOpenSSL is the legacy OpenSSL-compatible AES-256-CBC format
+	FormatOpenSSL Format = "openssl"
+	// FormatAEAD is the versioned, authenticated AES-256-GCM format
+	FormatAEAD Format = "aead"
+)
+
+var (
+	// ErrInvalidFormat is returned when data is not a recognised encrypted file
+	ErrInvalidFormat = errors.New("invalid encrypted file format")
+	// ErrWrongPassword is returned when an authenticated file rejects the password
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrCorrupted is returned when an authenticated file fails its integrity
+	// check, or a file of either format is structurally damaged
+	ErrCorrupted = errors.New("encrypted file is corrupted or has been tampered with")
+	// ErrDecryptionFailed is returned when an OpenSSL file decrypts to
+	// invalid padding. The format has no MAC, so this is either a wrong
+	// password or corruption; the manifest can tell which.
+	ErrDecryptionFailed = errors.New("wrong password or corrupted file")
 )
 
+// ParseFormat converts a format name from configuration into a Format.
+// An empty name selects the OpenSSL format for backward compatibility.
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "", "openssl", "cbc":
+		return FormatOpenSSL, nil
+	case "aead", "gcm":
+		return FormatAEAD, nil
+	default:
+		return "", fmt.Errorf("unknown format %q (expected openssl or aead)", name)
+	}
+}
+
+// DetectFormat reports which format the encrypted data is stored in
+func DetectFormat(data []byte) (Format, error) {
+	switch {
+	case len(data) >= len(aeadMagic) && string(data[:len(aeadMagic)]) == aeadMagic:
+		return FormatAEAD, nil
+	case len(data) >= len(saltedPrefix) && string(data[:len(saltedPrefix)]) == saltedPrefix:
+		return FormatOpenSSL, nil
+	default:
+		return "", ErrInvalidFormat
+	}
+}
+
+// Options controls how files are encrypted and decrypted
+type Options struct {
+	// Format selects the container format; the zero value is FormatOpenSSL
+	Format Format
+
+	// KDF selects the key derivation for new AEAD files. Decryption reads
+	// the KDF from the file header instead.
+	KDF KDFParams
+
+	// Recipients encrypts new files to these public keys instead of the
+	// password. It requires the AEAD format.
+	Recipients []*Recipient
+
+	// Identities are the private keys tried when decrypting files that
+	// were encrypted to recipients
+	Identities []*Identity
+
+	// PBKDF2Iter is the PBKDF2 iteration count for the OpenSSL format,
+	// equivalent to openssl enc -iter N. The format does not record it, so
+	// the same value must be used to decrypt. Zero means 10000.
+	PBKDF2Iter int
+
+	// Values encrypts each value of a document of this syntax, keeping its
+	// keys and layout readable, instead of the whole input. It requires the
+	// AEAD format.
+	Values values.Syntax
+
+	// Previous is the current encrypted form of the document, if any. When
+	// encrypting values with the same password and KDF, its salt is kept so
+	// that unchanged values encrypt to the same text.
+	Previous []byte
+
+	// IgnoreDocumentMAC accepts a values document whose values authenticate
+	// but whose document MAC does not, as after merging two versions of it.
+	// Its keys, comments and layout are then not authenticated.
+	IgnoreDocumentMAC bool
+
+	// SIVKey makes encryption deterministic: salts, nonces and IVs are
+	// derived from this key and the plaintext instead of being random, so
+	// the same content always encrypts to the same bytes. It is meant for
+	// the git filter, where an unchanged file must not show as modified, and
+	// reveals when two files are equal. When encrypting values, they are
+	// derived from the key alone, so unchanged values keep their ciphertext.
+	// Get one from DeriveSIVKey; it cannot be used with recipients.
+	SIVKey []byte
+
+	// Keys, when set, remembers password-derived keys across the files of
+	// a run, and lets whole files encrypted in the aead format share one
+	// salt (see KeyCache)
+	Keys *KeyCache
+}
+
+// Validate checks that the options describe a supported combination
+func (o Options) Validate() error {
+	switch o.Format {
+	case "", FormatOpenSSL:
+		if o.KDF.Algorithm != "" && o.KDF.Algorithm != KDFPBKDF2 {
+			return fmt.Errorf("kdf %s requires the aead format; the openssl format only supports pbkdf2", o.KDF.Algorithm)
+		}
+		if len(o.Recipients) > 0 {
+			return fmt.Errorf("recipients require the aead format")
+		}
+	case FormatAEAD:
+		if _, err := o.KDF.withDefaults(); err != nil {
+			return err
+		}
+		if len(o.Recipients) > maxRecipients {
+			return fmt.Errorf("too many recipients: %d (maximum %d)", len(o.Recipients), maxRecipients)
+		}
+	default:
+		return fmt.Errorf("unknown format %q", o.Format)
+	}
+
+	if len(o.SIVKey) > 0 && len(o.Recipients) > 0 {
+		return fmt.Errorf("deterministic encryption cannot be used with recipients")
+	}
+
+	if o.Values != "" && o.Format != FormatAEAD {
+		return fmt.Errorf("per-value encryption requires the aead format")
+	}
+
+	if o.PBKDF2Iter < 0 || o.PBKDF2Iter > maxPBKDF2Iterations {
+		return fmt.Errorf("pbkdf2 iterations out of range: %d", o.PBKDF2Iter)
+	}
+	return nil
+}
+
+// pbkdf2Iterations returns the OpenSSL-format iteration count to use
+func (o Options) pbkdf2Iterations() int {
+	if o.PBKDF2Iter > 0 {
+		return o.PBKDF2Iter
+	}
+	return pbkdf2Iter
+}
+
+// KDFSummary describes how new files are keyed, for reports and manifests:
+// "pbkdf2-sha256 iter=10000" for the OpenSSL format, the AEAD KDF and its
+// work factors, or "x25519" when encrypting to recipients
+func (o Options) KDFSummary() string {
+	if o.Format != FormatAEAD {
+		return fmt.Sprintf("pbkdf2-sha256 iter=%d", o.pbkdf2Iterations())
+	}
+	if len(o.Recipients) > 0 {
+		return "x25519"
+	}
+	p, err := o.KDF.withDefaults()
+	if err != nil {
+		return string(o.KDF.Algorithm)
+	}
+	switch p.Algorithm {
+	case KDFPBKDF2:
+		return fmt.Sprintf("pbkdf2-sha256 iter=%d", p.Iterations)
+	case KDFScrypt:
+		return fmt.Sprintf("scrypt N=%d r=%d p=%d", p.ScryptN, p.ScryptR, p.ScryptP)
+	default:
+		return fmt.Sprintf("argon2id t=%d m=%d p=%d", p.Time, p.Memory, p.Parallelism)
+	}
+}
+
 // deriveKeyAndIV derives a key and IV from password and salt using PBKDF2
 // This matches OpenSSL's key derivation when using -pbkdf2
 func deriveKeyAndIV(password, salt []byte) (key, iv []byte) {
+	return deriveKeyAndIVIter(password, salt, pbkdf2Iter)
+}
+
+// deriveKeyAndIVIter is deriveKeyAndIV with an explicit iteration count,
+// matching OpenSSL's -pbkdf2 -iter N
+func deriveKeyAndIVIter(password, salt []byte, iter int) (key, iv []byte) {
 	// OpenSSL uses PBKDF2 with SHA256 for -pbkdf2 flag
-	keyIV := pbkdf2.Key(password, salt, pbkdf2Iter, keySize+ivSize, sha256.New)
+	keyIV := pbkdf2.Key(password, salt, iter, keySize+ivSize, sha256.New)
 	key = keyIV[:keySize]
 	iv = keyIV[keySize:]
 	return
 }
 
+// Encrypt reads plaintext from r and writes an encrypted container in the
+// format selected by opts to w. The input is processed in fixed-size chunks,
+// so memory use does not grow with the size of the data, except when
+// encrypting values or deterministically, which needs the whole input.
+func Encrypt(r io.Reader, w io.Writer, password string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	rnd, shared := rand.Reader, opts.Keys
+	if len(opts.SIVKey) > 0 {
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		seed := plaintext
+		if opts.Values != "" {
+			seed = nil
+		}
+		rnd = sivReader(opts.SIVKey, seed)
+		r = bytes.NewReader(plaintext)
+		// The salt must follow from the content alone
+		shared = nil
+	}
+
+	if opts.Values != "" {
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		doc, err := encryptValues(plaintext, []byte(password), opts, rnd)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(doc); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return nil
+	}
+
+	switch opts.Format {
+	case FormatAEAD:
+		return sealAEAD(r, w, []byte(password), opts.KDF, opts.Recipients, rnd, shared)
+	default:
+		return encryptOpenSSL(r, w, []byte(password), opts.pbkdf2Iterations(), rnd)
+	}
+}
+
+// Decrypt reads an encrypted container or values document from r, detecting
+// its format from the header, and writes the plaintext to w. Plaintext is
+// written as it is decrypted, so w may have received partial output when an
+// error is returned.
+func Decrypt(r io.Reader, w io.Writer, password string, opts Options) error {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(valuesPeekSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return fmt.Errorf("failed to read encrypted data: %w", err)
+	}
+
+	if IsValuesDocument(magic) {
+		doc, err := io.ReadAll(br)
+		if err != nil {
+			return fmt.Errorf("failed to read encrypted data: %w", err)
+		}
+		plaintext, err := decryptValues(doc, []byte(password), opts)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(plaintext); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return nil
+	}
+
+	format, err := DetectFormat(magic)
+	if err != nil {
+		return fmt.Errorf("%w: missing 'Salted__' or '%s' header", err, aeadMagic)
+	}
+
+	switch format {
+	case FormatAEAD:
+		return openAEAD(br, w, []byte(password), opts.Identities, opts.Keys)
+	default:
+		return decryptOpenSSL(br, w, []byte(password), opts.pbkdf2Iterations())
+	}
+}
+
 // EncryptFile encrypts a file using AES-256-CBC in OpenSSL-compatible format
 func EncryptFile(inputPath, outputPath, password string) error {
-	// Read input file
-	plaintext, err := os.ReadFile(inputPath)
+	return EncryptFileWithOptions(inputPath, outputPath, password, Options{})
+}
+
+// EncryptFileWithOptions encrypts a file in the format selected by opts
+func EncryptFileWithOptions(inputPath, outputPath, password string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	// Open input file
+	in, err := os.Open(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to read input file: %w", err)
 	}
+	defer in.Close()
+
+	return utils.WriteAtomic(outputPath, 0644, func(w io.Writer) error {
+		return Encrypt(in, w, password, opts)
+	})
+}
+
+// DecryptFile decrypts a file in either supported format, detected from its header
+func DecryptFile(inputPath, outputPath, password string) error {
+	return DecryptFileWithOptions(inputPath, outputPath, password, Options{})
+}
+
+// DecryptFileWithOptions decrypts a file, using opts for the parameters that
+// the OpenSSL format does not record. The output file is only replaced once
+// the whole input has been decrypted successfully, and is readable only by
+// the current user.
+func DecryptFileWithOptions(inputPath, outputPath, password string, opts Options) error {
+	return DecryptFileMode(inputPath, outputPath, password, opts, 0600)
+}
+
+// DecryptFileMode is DecryptFileWithOptions with the output file's mode
+func DecryptFileMode(inputPath, outputPath, password string, opts Options, perm os.FileMode) error {
+	// Open encrypted file
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+	defer in.Close()
+
+	return utils.WriteAtomic(outputPath, perm, func(w io.Writer) error {
+		return Decrypt(in, w, password, opts)
+	})
+}
 
+// encryptOpenSSL streams "Salted__" + salt + AES-256-CBC ciphertext to w,
+// reading the salt from rnd
+func encryptOpenSSL(r io.Reader, w io.Writer, password []byte, iter int, rnd io.Reader) error {
 	// Generate random salt
 	salt := make([]byte, saltSize)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+	if _, err := io.ReadFull(rnd, salt); err != nil {
 		return fmt.Errorf("failed to generate salt: %w", err)
 	}
 
 	// Derive key and IV
-	key, iv := deriveKeyAndIV([]byte(password), salt)
+	key, iv := deriveKeyAndIVIter(password, salt, iter)
 
 	// Create cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return fmt.Errorf("failed to create cipher: %w", err)
 	}
-
-	// Apply PKCS7 padding
-	plaintext = pkcs7Pad(plaintext, aes.BlockSize)
-
-	// Encrypt
-	ciphertext := make([]byte, len(plaintext))
 	mode := cipher.NewCBCEncrypter(block, iv)
-	mode.CryptBlocks(ciphertext, plaintext)
-
-	// Create output: "Salted__" + salt + ciphertext
-	output := make([]byte, len(saltedPrefix)+saltSize+len(ciphertext))
-	copy(output, []byte(saltedPrefix))
-	copy(output[len(saltedPrefix):], salt)
-	copy(output[len(saltedPrefix)+saltSize:], ciphertext)
 
-	// Write to file
-	if err := os.WriteFile(outputPath, output, 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+	// Write header: "Salted__" + salt
+	if _, err := w.Write(append([]byte(saltedPrefix), salt...)); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
 	}
 
-	return nil
-}
+	// Encrypt full chunks as they arrive; the final, partial chunk is padded
+	buf := make([]byte, streamChunkSize, streamChunkSize+aes.BlockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
 
-// DecryptFile decrypts a file that was encrypted using OpenSSL-compatible format
-func DecryptFile(inputPath, outputPath, password string) error {
-	// Read encrypted file
-	data, err := os.ReadFile(inputPath)
-	if err != nil {
-		return fmt.Errorf("failed to read encrypted file: %w", err)
+		chunk := buf[:n]
+		if err != nil {
+			chunk = pkcs7Pad(chunk, aes.BlockSize)
+		}
+		mode.CryptBlocks(chunk, chunk)
+		if _, werr := w.Write(chunk); werr != nil {
+			return fmt.Errorf("failed to write output: %w", werr)
+		}
+
+		if err != nil {
+			return nil
+		}
 	}
+}
 
-	// Check for "Salted__" prefix
-	if len(data) < len(saltedPrefix)+saltSize {
-		return fmt.Errorf("invalid encrypted file format")
+// decryptOpenSSL decrypts a stream produced by encryptOpenSSL or openssl enc
+func decryptOpenSSL(r io.Reader, w io.Writer, password []byte, iter int) error {
+	// Read "Salted__" prefix and salt
+	header := make([]byte, len(saltedPrefix)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("%w: truncated header", ErrInvalidFormat)
 	}
 
-	prefix := string(data[:len(saltedPrefix)])
+	prefix := string(header[:len(saltedPrefix)])
 	if prefix != saltedPrefix {
-		return fmt.Errorf("invalid encrypted file format: missing 'Salted__' prefix")
+		return fmt.Errorf("%w: missing 'Salted__' prefix", ErrInvalidFormat)
 	}
-
-	// Extract salt and ciphertext
-	salt := data[len(saltedPrefix) : len(saltedPrefix)+saltSize]
-	ciphertext := data[len(saltedPrefix)+saltSize:]
+	salt := header[len(saltedPrefix):]
 
 	// Derive key and IV
-	key, iv := deriveKeyAndIV([]byte(password), salt)
+	key, iv := deriveKeyAndIVIter(password, salt, iter)
 
 	// Create cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return fmt.Errorf("failed to create cipher: %w", err)
 	}
+	mode := cipher.NewCBCDecrypter(block, iv)
 
-	// Check ciphertext length
-	if len(ciphertext)%aes.BlockSize != 0 {
-		return fmt.Errorf("ciphertext is not a multiple of block size")
-	}
+	// Decrypt chunk by chunk, holding back the latest chunk until the end of
+	// the input so its PKCS7 padding can be removed
+	cur := make([]byte, streamChunkSize)
+	var prev []byte
+	for {
+		n, err := io.ReadFull(r, cur)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read encrypted data: %w", err)
+		}
 
-	// Decrypt
-	plaintext := make([]byte, len(ciphertext))
-	mode := cipher.NewCBCDecrypter(block, iv)
-	mode.CryptBlocks(plaintext, ciphertext)
+		// Check ciphertext length
+		if n%aes.BlockSize != 0 {
+			return fmt.Errorf("%w: ciphertext is not a multiple of block size", ErrCorrupted)
+		}
+
+		if n > 0 {
+			if prev != nil {
+				if _, werr := w.Write(prev); werr != nil {
+					return fmt.Errorf("failed to write output: %w", werr)
+				}
+			}
+			mode.CryptBlocks(cur[:n], cur[:n])
+			next := prev
+			if next == nil {
+				next = make([]byte, streamChunkSize)
+			}
+			prev, cur = cur[:n], next[:streamChunkSize]
+		}
+
+		if err != nil {
+			break
+		}
+	}
 
 	// Remove PKCS7 padding
-	plaintext, err = pkcs7Unpad(plaintext)
+	if prev == nil {
+		return fmt.Errorf("%w: no ciphertext after the header", ErrCorrupted)
+	}
+	plaintext, err := pkcs7Unpad(prev)
 	if err != nil {
-		return fmt.Errorf("decryption failed (wrong password?): %w", err)
+		return fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
 	}
-
-	// Write to file
-	if err := os.WriteFile(outputPath, plaintext, 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+	if _, err := w.Write(plaintext); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
 	}
 
 	return nil
@@ -1,8 +1,13 @@
 package crypto
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -108,6 +113,42 @@ func TestDecryptFileInvalidFormat(t *testing.T) {
 	}
 }
 
+func TestDecryptOpenSSLErrors(t *testing.T) {
+	var encrypted bytes.Buffer
+	if err := Encrypt(strings.NewReader("API_KEY=secret\n"), &encrypted, "password", Options{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	data := encrypted.Bytes()
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{name: "TruncatedHeader", data: data[:10], expected: ErrInvalidFormat},
+		{name: "HeaderOnly", data: data[:len(saltedPrefix)+saltSize], expected: ErrCorrupted},
+		{name: "PartialBlock", data: data[:len(data)-1], expected: ErrCorrupted},
+		{name: "WrongPassword", data: data, expected: ErrDecryptionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password := "password"
+			if tt.expected == ErrDecryptionFailed {
+				password = "wrong_password"
+			}
+			err := Decrypt(bytes.NewReader(tt.data), io.Discard, password, Options{})
+			if tt.expected == ErrDecryptionFailed && err == nil {
+				// CBC padding occasionally looks valid under another password
+				t.Skip("wrong password produced valid padding")
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
 func TestPkcs7Padding(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -233,3 +274,145 @@ func TestDeriveKeyAndIV(t *testing.T) {
 		t.Error("Different salts should produce different IVs")
 	}
 }
+
+func TestEncryptDecryptStream(t *testing.T) {
+	sizes := []int{0, 1, 15, 16, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3*aeadChunkSize + 5}
+	formats := []Options{
+		{Format: FormatOpenSSL},
+		{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}},
+	}
+
+	for _, opts := range formats {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s/%d", opts.Format, size), func(t *testing.T) {
+				plaintext := make([]byte, size)
+				for i := range plaintext {
+					plaintext[i] = byte(i * 7)
+				}
+
+				var encrypted bytes.Buffer
+				if err := Encrypt(bytes.NewReader(plaintext), &encrypted, "password", opts); err != nil {
+					t.Fatalf("Encrypt failed: %v", err)
+				}
+
+				var decrypted bytes.Buffer
+				if err := Decrypt(&encrypted, &decrypted, "password", opts); err != nil {
+					t.Fatalf("Decrypt failed: %v", err)
+				}
+
+				if !bytes.Equal(decrypted.Bytes(), plaintext) {
+					t.Errorf("Decrypted stream doesn't match original (%d bytes, got %d)", size, decrypted.Len())
+				}
+			})
+		}
+	}
+}
+
+func TestDecryptStreamDetectsTruncation(t *testing.T) {
+	opts := Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}}
+	plaintext := bytes.Repeat([]byte("x"), 2*aeadChunkSize+10)
+
+	var encrypted bytes.Buffer
+	if err := Encrypt(bytes.NewReader(plaintext), &encrypted, "password", opts); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	data := encrypted.Bytes()
+
+	// Drop the final chunk, leaving a stream that ends on a chunk boundary
+	lastChunk := 10 + 16
+	truncated := data[:len(data)-lastChunk]
+
+	err := Decrypt(bytes.NewReader(truncated), io.Discard, "password", opts)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted for truncated stream, got %v", err)
+	}
+}
+
+func TestDecryptFileMode(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	if err := os.WriteFile(inputPath, []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to create test input file: %v", err)
+	}
+	encryptedPath := filepath.Join(tmpDir, "input.txt.encrypted")
+	if err := EncryptFile(inputPath, encryptedPath, "password"); err != nil {
+		t.Fatalf("EncryptFile failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		decrypt  func(outputPath string) error
+		expected os.FileMode
+	}{
+		{
+			name:     "Default",
+			decrypt:  func(outputPath string) error { return DecryptFile(encryptedPath, outputPath, "password") },
+			expected: 0600,
+		},
+		{
+			name: "Explicit",
+			decrypt: func(outputPath string) error {
+				return DecryptFileMode(encryptedPath, outputPath, "password", Options{}, 0640)
+			},
+			expected: 0640,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "output.txt")
+			if err := tt.decrypt(outputPath); err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			info, err := os.Stat(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to stat output file: %v", err)
+			}
+			if info.Mode().Perm() != tt.expected {
+				t.Errorf("Expected mode %04o, got %04o", tt.expected, info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestDecryptFileKeepsExistingOutputOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	if err := os.WriteFile(inputPath, []byte("new secret"), 0644); err != nil {
+		t.Fatalf("Failed to create test input file: %v", err)
+	}
+	// The AEAD format always rejects a wrong password; CBC padding can
+	// occasionally still look valid
+	encryptedPath := filepath.Join(tmpDir, "input.txt.encrypted")
+	opts := Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}}
+	if err := EncryptFileWithOptions(inputPath, encryptedPath, "password", opts); err != nil {
+		t.Fatalf("EncryptFileWithOptions failed: %v", err)
+	}
+
+	outputPath := filepath.Join(tmpDir, "existing.txt")
+	if err := os.WriteFile(outputPath, []byte("old secret"), 0644); err != nil {
+		t.Fatalf("Failed to create existing output file: %v", err)
+	}
+
+	if err := DecryptFile(encryptedPath, outputPath, "wrong_password"); err == nil {
+		t.Fatal("Expected error with wrong password, got nil")
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if string(content) != "old secret" {
+		t.Errorf("Existing output was modified: %q", string(content))
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("Expected no leftover temporary files, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,227 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF identifies a password-based key derivation function
+type KDF string
+
+const (
+	// KDFPBKDF2 is PBKDF2-HMAC-SHA256
+	KDFPBKDF2 KDF = "pbkdf2"
+	// KDFScrypt is scrypt
+	KDFScrypt KDF = "scrypt"
+	// KDFArgon2id is Argon2id, the default for new AEAD files
+	KDFArgon2id KDF = "argon2id"
+)
+
+// KDF identifiers as stored in the AEAD header
+const (
+	kdfPBKDF2SHA256 = 1
+	kdfScrypt       = 2
+	kdfArgon2id     = 3
+)
+
+// Default work factors for new AEAD files
+const (
+	DefaultPBKDF2Iterations  = 600000
+	DefaultScryptN           = 1 << 15
+	DefaultScryptR           = 8
+	DefaultScryptP           = 1
+	DefaultArgon2Time        = 3
+	DefaultArgon2Memory      = 64 * 1024 // KiB
+	DefaultArgon2Parallelism = 4
+)
+
+// Upper bounds accepted when reading a header, so a crafted file cannot make
+// decryption allocate unbounded memory or spin forever
+const (
+	maxPBKDF2Iterations = 100000000
+	maxScryptMemory     = 1 << 31 // bytes, 128 * N * r
+	maxArgon2Memory     = 4 * 1024 * 1024
+	maxArgon2Time       = 1000
+)
+
+// KDFParams selects a key derivation function and its work factors.
+// Zero-valued fields take the defaults for the selected function.
+type KDFParams struct {
+	Algorithm KDF
+
+	// Iterations is the PBKDF2 iteration count
+	Iterations uint32
+
+	// ScryptN is the scrypt CPU/memory cost; it must be a power of two
+	ScryptN uint32
+	// ScryptR is the scrypt block size
+	ScryptR uint32
+	// ScryptP is the scrypt parallelization factor
+	ScryptP uint32
+
+	// Time is the number of Argon2id passes
+	Time uint32
+	// Memory is the Argon2id memory cost in KiB
+	Memory uint32
+	// Parallelism is the number of Argon2id lanes
+	Parallelism uint8
+}
+
+// ParseKDF converts a KDF name from configuration into a KDF.
+// An empty name selects Argon2id.
+func ParseKDF(name string) (KDF, error) {
+	switch name {
+	case "", "argon2id", "argon2":
+		return KDFArgon2id, nil
+	case "scrypt":
+		return KDFScrypt, nil
+	case "pbkdf2", "pbkdf2-sha256":
+		return KDFPBKDF2, nil
+	default:
+		return "", fmt.Errorf("unknown kdf %q (expected pbkdf2, scrypt or argon2id)", name)
+	}
+}
+
+// withDefaults returns a copy of p with zero fields filled in and validated
+func (p KDFParams) withDefaults() (KDFParams, error) {
+	if p.Algorithm == "" {
+		p.Algorithm = KDFArgon2id
+	}
+
+	switch p.Algorithm {
+	case KDFPBKDF2:
+		if p.Iterations == 0 {
+			p.Iterations = DefaultPBKDF2Iterations
+		}
+	case KDFScrypt:
+		if p.ScryptN == 0 {
+			p.ScryptN = DefaultScryptN
+		}
+		if p.ScryptR == 0 {
+			p.ScryptR = DefaultScryptR
+		}
+		if p.ScryptP == 0 {
+			p.ScryptP = DefaultScryptP
+		}
+	case KDFArgon2id:
+		if p.Time == 0 {
+			p.Time = DefaultArgon2Time
+		}
+		if p.Memory == 0 {
+			p.Memory = DefaultArgon2Memory
+		}
+		if p.Parallelism == 0 {
+			p.Parallelism = DefaultArgon2Parallelism
+		}
+	default:
+		return p, fmt.Errorf("unknown kdf %q", p.Algorithm)
+	}
+
+	return p, p.validate()
+}
+
+// validate checks that the parameters are usable and within safe bounds
+func (p KDFParams) validate() error {
+	switch p.Algorithm {
+	case KDFPBKDF2:
+		if p.Iterations == 0 || p.Iterations > maxPBKDF2Iterations {
+			return fmt.Errorf("pbkdf2 iterations out of range: %d", p.Iterations)
+		}
+	case KDFScrypt:
+		if p.ScryptN < 2 || bits.OnesCount32(p.ScryptN) != 1 {
+			return fmt.Errorf("scrypt N must be a power of two greater than 1, got %d", p.ScryptN)
+		}
+		if p.ScryptR == 0 || p.ScryptP == 0 || uint64(p.ScryptR)*uint64(p.ScryptP) >= 1<<30 {
+			return fmt.Errorf("scrypt r and p out of range: r=%d p=%d", p.ScryptR, p.ScryptP)
+		}
+		if 128*uint64(p.ScryptN)*uint64(p.ScryptR) > maxScryptMemory {
+			return fmt.Errorf("scrypt memory cost too large: N=%d r=%d", p.ScryptN, p.ScryptR)
+		}
+	case KDFArgon2id:
+		if p.Time == 0 || p.Time > maxArgon2Time {
+			return fmt.Errorf("argon2id time out of range: %d", p.Time)
+		}
+		if p.Parallelism == 0 || p.Memory < 8*uint32(p.Parallelism) || p.Memory > maxArgon2Memory {
+			return fmt.Errorf("argon2id memory out of range: %d KiB", p.Memory)
+		}
+	default:
+		return fmt.Errorf("unknown kdf %q", p.Algorithm)
+	}
+	return nil
+}
+
+// deriveKey stretches password into a key of keySize bytes
+func (p KDFParams) deriveKey(password, salt []byte) ([]byte, error) {
+	switch p.Algorithm {
+	case KDFPBKDF2:
+		return pbkdf2.Key(password, salt, int(p.Iterations), keySize, sha256.New), nil
+	case KDFScrypt:
+		key, err := scrypt.Key(password, salt, int(p.ScryptN), int(p.ScryptR), int(p.ScryptP), keySize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		return key, nil
+	case KDFArgon2id:
+		return argon2.IDKey(password, salt, p.Time, p.Memory, p.Parallelism, keySize), nil
+	default:
+		return nil, fmt.Errorf("unknown kdf %q", p.Algorithm)
+	}
+}
+
+// marshal encodes the KDF identifier and its parameters for the AEAD header
+func (p KDFParams) marshal() (id byte, params []byte) {
+	switch p.Algorithm {
+	case KDFPBKDF2:
+		return kdfPBKDF2SHA256, binary.BigEndian.AppendUint32(nil, p.Iterations)
+	case KDFScrypt:
+		params = []byte{byte(bits.TrailingZeros32(p.ScryptN))}
+		params = binary.BigEndian.AppendUint32(params, p.ScryptR)
+		return kdfScrypt, binary.BigEndian.AppendUint32(params, p.ScryptP)
+	default:
+		params = binary.BigEndian.AppendUint32(nil, p.Time)
+		params = binary.BigEndian.AppendUint32(params, p.Memory)
+		return kdfArgon2id, append(params, p.Parallelism)
+	}
+}
+
+// unmarshalKDFParams decodes the parameters stored in an AEAD header
+func unmarshalKDFParams(id byte, params []byte) (KDFParams, error) {
+	var p KDFParams
+	switch id {
+	case kdfPBKDF2SHA256:
+		if len(params) != 4 {
+			return p, fmt.Errorf("%w: malformed PBKDF2 parameters", ErrInvalidFormat)
+		}
+		p.Algorithm = KDFPBKDF2
+		p.Iterations = binary.BigEndian.Uint32(params)
+	case kdfScrypt:
+		if len(params) != 9 || params[0] >= 32 {
+			return p, fmt.Errorf("%w: malformed scrypt parameters", ErrInvalidFormat)
+		}
+		p.Algorithm = KDFScrypt
+		p.ScryptN = 1 << params[0]
+		p.ScryptR = binary.BigEndian.Uint32(params[1:])
+		p.ScryptP = binary.BigEndian.Uint32(params[5:])
+	case kdfArgon2id:
+		if len(params) != 9 {
+			return p, fmt.Errorf("%w: malformed Argon2id parameters", ErrInvalidFormat)
+		}
+		p.Algorithm = KDFArgon2id
+		p.Time = binary.BigEndian.Uint32(params)
+		p.Memory = binary.BigEndian.Uint32(params[4:])
+		p.Parallelism = params[8]
+	default:
+		return p, fmt.Errorf("%w: unknown key derivation function %d", ErrInvalidFormat, id)
+	}
+
+	if err := p.validate(); err != nil {
+		return p, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	return p, nil
+}
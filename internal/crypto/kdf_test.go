@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAEADKeyDerivationFunctions(t *testing.T) {
+	tests := []struct {
+		name string
+		kdf  KDFParams
+	}{
+		{"PBKDF2", KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}},
+		{"Scrypt", KDFParams{Algorithm: KDFScrypt, ScryptN: 1 << 10, ScryptR: 8, ScryptP: 1}},
+		{"Argon2id", KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: 1024, Parallelism: 1}},
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	testContent := "kdf test content"
+	if err := os.WriteFile(inputPath, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test input file: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encryptedPath := filepath.Join(tmpDir, tt.name+".encrypted")
+			decryptedPath := filepath.Join(tmpDir, tt.name+".txt")
+
+			opts := Options{Format: FormatAEAD, KDF: tt.kdf}
+			if err := EncryptFileWithOptions(inputPath, encryptedPath, "password", opts); err != nil {
+				t.Fatalf("EncryptFileWithOptions failed: %v", err)
+			}
+
+			// The header records the KDF, so no options are needed to decrypt
+			if err := DecryptFile(encryptedPath, decryptedPath, "password"); err != nil {
+				t.Fatalf("DecryptFile failed: %v", err)
+			}
+
+			decrypted, err := os.ReadFile(decryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read decrypted file: %v", err)
+			}
+			if string(decrypted) != testContent {
+				t.Errorf("Expected %q, got %q", testContent, string(decrypted))
+			}
+
+			data, err := os.ReadFile(encryptedPath)
+			if err != nil {
+				t.Fatalf("Failed to read encrypted file: %v", err)
+			}
+			h, _, _, err := parseAEADHeader(data)
+			if err != nil {
+				t.Fatalf("parseAEADHeader failed: %v", err)
+			}
+			if h.kdf != tt.kdf {
+				t.Errorf("Expected header KDF %+v, got %+v", tt.kdf, h.kdf)
+			}
+		})
+	}
+}
+
+func TestOpenSSLPBKDF2Iterations(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	if err := os.WriteFile(inputPath, []byte("iterations"), 0644); err != nil {
+		t.Fatalf("Failed to create test input file: %v", err)
+	}
+
+	encryptedPath := filepath.Join(tmpDir, "input.txt.encrypted")
+	decryptedPath := filepath.Join(tmpDir, "output.txt")
+	opts := Options{PBKDF2Iter: 20000}
+
+	if err := EncryptFileWithOptions(inputPath, encryptedPath, "password", opts); err != nil {
+		t.Fatalf("EncryptFileWithOptions failed: %v", err)
+	}
+
+	if err := DecryptFileWithOptions(encryptedPath, decryptedPath, "password", opts); err != nil {
+		t.Fatalf("DecryptFileWithOptions with matching iterations failed: %v", err)
+	}
+
+	// The OpenSSL format does not record the iteration count
+	if err := DecryptFile(encryptedPath, decryptedPath, "password"); err == nil {
+		t.Error("Expected error when decrypting with the default iteration count")
+	}
+}
+
+func TestParseKDF(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected KDF
+		wantErr  bool
+	}{
+		{"", KDFArgon2id, false},
+		{"argon2id", KDFArgon2id, false},
+		{"scrypt", KDFScrypt, false},
+		{"pbkdf2", KDFPBKDF2, false},
+		{"md5", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kdf, err := ParseKDF(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKDF(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if kdf != tt.expected {
+				t.Errorf("ParseKDF(%q) = %q, expected %q", tt.name, kdf, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"Defaults", Options{}, false},
+		{"AEADDefaults", Options{Format: FormatAEAD}, false},
+		{"OpenSSLWithPBKDF2", Options{KDF: KDFParams{Algorithm: KDFPBKDF2}}, false},
+		{"OpenSSLWithArgon2id", Options{KDF: KDFParams{Algorithm: KDFArgon2id}}, true},
+		{"ScryptNotPowerOfTwo", Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFScrypt, ScryptN: 1000}}, true},
+		{"Argon2idTooMuchMemory", Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFArgon2id, Memory: 1 << 30}}, true},
+		{"NegativeIterations", Options{PBKDF2Iter: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalKDFParamsRejectsExcessiveCost(t *testing.T) {
+	p := KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: maxArgon2Memory + 1, Parallelism: 1}
+	id, params := p.marshal()
+
+	if _, err := unmarshalKDFParams(id, params); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("Expected ErrInvalidFormat, got %v", err)
+	}
+}
+
+func TestKDFSummary(t *testing.T) {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"OpenSSLDefault", Options{}, "pbkdf2-sha256 iter=10000"},
+		{"OpenSSLIterations", Options{PBKDF2Iter: 50000}, "pbkdf2-sha256 iter=50000"},
+		{"AEADDefault", Options{Format: FormatAEAD}, "argon2id t=3 m=65536 p=4"},
+		{"AEADScrypt", Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFScrypt}}, "scrypt N=32768 r=8 p=1"},
+		{"Recipients", Options{Format: FormatAEAD, Recipients: []*Recipient{identity.Recipient()}}, "x25519"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.KDFSummary(); got != tt.expected {
+				t.Errorf("KDFSummary() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
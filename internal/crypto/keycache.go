@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// KeyCache remembers the keys derived from passwords while many files are
+// processed, so each salt costs one run of the KDF. Whole files encrypted in
+// the aead format with a cache share a salt, and so a key, with the other
+// files encrypted through it; each still gets its own random nonce. The
+// OpenSSL format, whose IV comes from the salt, and values documents, whose
+// nonces are derived from their content, always get a salt of their own.
+//
+// Set Options.Keys to a cache for the duration of a run. It is safe for
+// concurrent use.
+type KeyCache struct {
+	mu    sync.Mutex
+	keys  map[string]*cachedKey
+	salts map[string][]byte
+}
+
+// cachedKey is a key derived once, however many files ask for it at once
+type cachedKey struct {
+	once sync.Once
+	key  []byte
+	err  error
+}
+
+// NewKeyCache returns an empty cache
+func NewKeyCache() *KeyCache {
+	return &KeyCache{
+		keys:  make(map[string]*cachedKey),
+		salts: make(map[string][]byte),
+	}
+}
+
+// cacheID identifies a password and KDF settings without keeping the
+// password itself
+func cacheID(kdf KDFParams, password []byte) string {
+	id, params := kdf.marshal()
+	sum := sha256.Sum256(password)
+	return string(id) + string(params) + string(sum[:])
+}
+
+// deriveKey returns kdf.deriveKey(password, salt), deriving it only the first
+// time. A nil cache derives the key every time.
+func (c *KeyCache) deriveKey(kdf KDFParams, password, salt []byte) ([]byte, error) {
+	if c == nil {
+		return kdf.deriveKey(password, salt)
+	}
+
+	id := cacheID(kdf, password) + string(salt)
+	c.mu.Lock()
+	entry, ok := c.keys[id]
+	if !ok {
+		entry = &cachedKey{}
+		c.keys[id] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.key, entry.err = kdf.deriveKey(password, salt)
+	})
+	return entry.key, entry.err
+}
+
+// sharedSalt returns the salt that files encrypted with this password and
+// KDF share, reading it from rnd the first time
+func (c *KeyCache) sharedSalt(kdf KDFParams, password []byte, rnd io.Reader) ([]byte, error) {
+	id := cacheID(kdf, password)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if salt, ok := c.salts[id]; ok {
+		return salt, nil
+	}
+	salt := make([]byte, aeadSaltSize)
+	if _, err := io.ReadFull(rnd, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	c.salts[id] = salt
+	return salt, nil
+}
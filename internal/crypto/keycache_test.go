@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/values"
+)
+
+func TestKeyCache(t *testing.T) {
+	aead := Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}}
+
+	t.Run("AEADSharesSalt", func(t *testing.T) {
+		opts := aead
+		opts.Keys = NewKeyCache()
+
+		// Files encrypted at once still share one salt
+		encrypted := make([]string, 8)
+		var wg sync.WaitGroup
+		for i := range encrypted {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var buf bytes.Buffer
+				if err := Encrypt(strings.NewReader("secret"), &buf, "password", opts); err != nil {
+					t.Errorf("Encrypt failed: %v", err)
+				}
+				encrypted[i] = buf.String()
+			}(i)
+		}
+		wg.Wait()
+
+		for i, data := range encrypted {
+			if !bytes.Equal(saltOf(t, data), saltOf(t, encrypted[0])) {
+				t.Errorf("Expected file %d to share the salt of file 0", i)
+			}
+			if i > 0 && data == encrypted[0] {
+				t.Errorf("Expected file %d to have its own nonce", i)
+			}
+
+			var decrypted bytes.Buffer
+			if err := Decrypt(strings.NewReader(data), &decrypted, "password", opts); err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			if decrypted.String() != "secret" {
+				t.Errorf("Expected %q, got %q", "secret", decrypted.String())
+			}
+		}
+	})
+
+	t.Run("DifferentPassword", func(t *testing.T) {
+		opts := aead
+		opts.Keys = NewKeyCache()
+
+		first := encryptValuesString(t, "secret", "password", opts)
+		second := encryptValuesString(t, "secret", "other", opts)
+		if bytes.Equal(saltOf(t, first), saltOf(t, second)) {
+			t.Error("Expected files with different passwords not to share a salt")
+		}
+
+		// A key cached for one password must not open a file for another
+		var decrypted bytes.Buffer
+		if err := Decrypt(strings.NewReader(first), &decrypted, "other", opts); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("Expected ErrWrongPassword, got %v", err)
+		}
+	})
+
+	t.Run("OpenSSLKeepsOwnSalt", func(t *testing.T) {
+		opts := Options{PBKDF2Iter: 1000, Keys: NewKeyCache()}
+		first := encryptValuesString(t, "secret", "password", opts)
+		second := encryptValuesString(t, "secret", "password", opts)
+		if bytes.Equal(saltOf(t, first), saltOf(t, second)) {
+			t.Error("Expected OpenSSL files to have their own salt")
+		}
+	})
+
+	t.Run("ValuesKeepOwnSalt", func(t *testing.T) {
+		opts := valuesOptions(values.Dotenv)
+		opts.Keys = NewKeyCache()
+
+		headers := make([][]byte, 2)
+		for i := range headers {
+			doc := encryptValuesString(t, "API_KEY=abc\n", "password", opts)
+			_, header, _, _, err := parseValuesMeta([]byte(doc))
+			if err != nil {
+				t.Fatalf("Failed to parse values document: %v", err)
+			}
+			h, _, _, err := parseAEADHeader(header)
+			if err != nil {
+				t.Fatalf("Failed to parse header: %v", err)
+			}
+			headers[i] = h.salt
+
+			var decrypted bytes.Buffer
+			if err := Decrypt(strings.NewReader(doc), &decrypted, "password", opts); err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+		}
+		if bytes.Equal(headers[0], headers[1]) {
+			t.Error("Expected values documents to have their own salt")
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package crypto
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Recipient mode replaces the password KDF in the AEAD header with one
+// stanza per recipient. Each stanza carries an ephemeral X25519 public key
+// and the random file key, wrapped with AES-256-GCM under a key derived from
+// the X25519 shared secret. The file key then plays the role of the
+// password-derived master key for the header MAC and the payload.
+const (
+	// kdfX25519 identifies recipient stanzas in the AEAD header
+	kdfX25519 = 16
+
+	stanzaSize    = curve25519.PointSize + keySize + 16
+	maxRecipients = 255
+
+	recipientPrefix = "sfpub1"
+	identityPrefix  = "SFSECRET1"
+	checksumSize    = 4
+)
+
+// ErrNoIdentity is returned when none of the supplied identities can open a
+// file that was encrypted to recipients
+var ErrNoIdentity = errors.New("no identity matches any recipient of this file")
+
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Recipient is an X25519 public key that files can be encrypted to
+type Recipient struct {
+	publicKey []byte
+}
+
+// Identity is an X25519 private key that can decrypt files encrypted to its
+// Recipient
+type Identity struct {
+	secretKey []byte
+	publicKey []byte
+}
+
+// GenerateIdentity creates a new random identity
+func GenerateIdentity() (*Identity, error) {
+	secret := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return newIdentity(secret)
+}
+
+// newIdentity computes the public key for secret
+func newIdentity(secret []byte) (*Identity, error) {
+	public, err := curve25519.X25519(secret, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	return &Identity{secretKey: secret, publicKey: public}, nil
+}
+
+// Recipient returns the public key matching the identity
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{publicKey: i.publicKey}
+}
+
+// String encodes the identity as SFSECRET1 followed by base32 data
+func (i *Identity) String() string {
+	return encodeKey(identityPrefix, i.secretKey)
+}
+
+// String encodes the recipient as sfpub1 followed by lowercase base32 data
+func (r *Recipient) String() string {
+	return strings.ToLower(encodeKey(recipientPrefix, r.publicKey))
+}
+
+// ParseRecipient decodes a public key produced by Recipient.String
+func ParseRecipient(s string) (*Recipient, error) {
+	key, err := decodeKey(recipientPrefix, strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", s, err)
+	}
+	return &Recipient{publicKey: key}, nil
+}
+
+// ParseIdentity decodes a secret key produced by Identity.String
+func ParseIdentity(s string) (*Identity, error) {
+	key, err := decodeKey(identityPrefix, strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %w", err)
+	}
+	return newIdentity(key)
+}
+
+// ParseIdentities reads identities from a key file, one per line. Blank
+// lines and lines starting with # are ignored.
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var identities []*Identity
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read identities: %w", err)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identities found")
+	}
+	return identities, nil
+}
+
+// encodeKey encodes key with a prefix and a short checksum that catches typos
+func encodeKey(prefix string, key []byte) string {
+	sum := sha256.Sum256(append([]byte(prefix), key...))
+	return prefix + keyEncoding.EncodeToString(append(append([]byte(nil), key...), sum[:checksumSize]...))
+}
+
+// decodeKey reverses encodeKey, ignoring case
+func decodeKey(prefix, s string) ([]byte, error) {
+	if !strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix)) {
+		return nil, fmt.Errorf("missing %s prefix", prefix)
+	}
+	data, err := keyEncoding.DecodeString(strings.ToUpper(s[len(prefix):]))
+	if err != nil || len(data) != keySize+checksumSize {
+		return nil, fmt.Errorf("malformed key")
+	}
+	key := data[:keySize]
+	sum := sha256.Sum256(append([]byte(prefix), key...))
+	if string(sum[:checksumSize]) != string(data[keySize:]) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	return key, nil
+}
+
+// stanzaKey derives the key that wraps the file key for one recipient
+func stanzaKey(shared, ephemeral, recipient, salt []byte) ([]byte, error) {
+	info := append([]byte("secureflow x25519"), ephemeral...)
+	info = append(info, recipient...)
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, info), key); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
+
+// wrapFileKey produces a stanza that lets recipient recover fileKey
+func wrapFileKey(fileKey, salt []byte, recipient *Recipient) ([]byte, error) {
+	ephemeralSecret := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, ephemeralSecret); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	ephemeral, err := curve25519.X25519(ephemeralSecret, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	shared, err := curve25519.X25519(ephemeralSecret, recipient.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %s: %w", recipient, err)
+	}
+
+	key, err := stanzaKey(shared, ephemeral, recipient.publicKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each wrapping key is used exactly once, so a zero nonce is safe
+	nonce := make([]byte, gcm.NonceSize())
+	return gcm.Seal(ephemeral, nonce, fileKey, nil), nil
+}
+
+// unwrapFileKey tries every identity against every stanza and returns the
+// first file key that authenticates
+func unwrapFileKey(stanzas [][]byte, salt []byte, identities []*Identity) ([]byte, error) {
+	for _, stanza := range stanzas {
+		ephemeral := stanza[:curve25519.PointSize]
+		for _, identity := range identities {
+			shared, err := curve25519.X25519(identity.secretKey, ephemeral)
+			if err != nil {
+				continue
+			}
+			key, err := stanzaKey(shared, ephemeral, identity.publicKey, salt)
+			if err != nil {
+				return nil, err
+			}
+			gcm, err := newGCM(key)
+			if err != nil {
+				return nil, err
+			}
+			fileKey, err := gcm.Open(nil, make([]byte, gcm.NonceSize()), stanza[curve25519.PointSize:], nil)
+			if err == nil {
+				return fileKey, nil
+			}
+		}
+	}
+	return nil, ErrNoIdentity
+}
+
+// marshalStanzas encodes the recipient stanzas for the AEAD header
+func marshalStanzas(stanzas [][]byte) []byte {
+	params := []byte{byte(len(stanzas))}
+	for _, stanza := range stanzas {
+		params = append(params, stanza...)
+	}
+	return params
+}
+
+// unmarshalStanzas decodes the recipient stanzas stored in an AEAD header
+func unmarshalStanzas(params []byte) ([][]byte, error) {
+	if len(params) < 1 || params[0] == 0 || len(params) != 1+int(params[0])*stanzaSize {
+		return nil, fmt.Errorf("%w: malformed recipient stanzas", ErrInvalidFormat)
+	}
+	stanzas := make([][]byte, params[0])
+	for i := range stanzas {
+		start := 1 + i*stanzaSize
+		stanzas[i] = params[start : start+stanzaSize]
+	}
+	return stanzas, nil
+}
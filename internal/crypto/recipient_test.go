@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIdentityEncoding(t *testing.T) {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+
+	encoded := identity.String()
+	if !strings.HasPrefix(encoded, identityPrefix) {
+		t.Errorf("Expected identity to start with %s, got %s", identityPrefix, encoded)
+	}
+
+	parsed, err := ParseIdentity(encoded)
+	if err != nil {
+		t.Fatalf("ParseIdentity failed: %v", err)
+	}
+	if parsed.Recipient().String() != identity.Recipient().String() {
+		t.Error("Parsed identity has a different public key")
+	}
+
+	recipient := identity.Recipient().String()
+	if !strings.HasPrefix(recipient, recipientPrefix) {
+		t.Errorf("Expected recipient to start with %s, got %s", recipientPrefix, recipient)
+	}
+	if _, err := ParseRecipient(recipient); err != nil {
+		t.Fatalf("ParseRecipient failed: %v", err)
+	}
+
+	// A single changed character must be caught by the checksum
+	typo := []byte(recipient)
+	if typo[10] == 'a' {
+		typo[10] = 'b'
+	} else {
+		typo[10] = 'a'
+	}
+	if _, err := ParseRecipient(string(typo)); err == nil {
+		t.Error("Expected error for recipient with a typo")
+	}
+}
+
+func TestParseIdentities(t *testing.T) {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+
+	keyFile := "# created: today\n# public key: " + identity.Recipient().String() + "\n\n" + identity.String() + "\n"
+	identities, err := ParseIdentities(strings.NewReader(keyFile))
+	if err != nil {
+		t.Fatalf("ParseIdentities failed: %v", err)
+	}
+	if len(identities) != 1 {
+		t.Fatalf("Expected 1 identity, got %d", len(identities))
+	}
+
+	if _, err := ParseIdentities(strings.NewReader("# only comments\n")); err == nil {
+		t.Error("Expected error for key file without identities")
+	}
+}
+
+func TestEncryptDecryptRecipients(t *testing.T) {
+	alice, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	bob, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	mallory, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+
+	plaintext := []byte("shared team secret")
+	opts := Options{Format: FormatAEAD, Recipients: []*Recipient{alice.Recipient(), bob.Recipient()}}
+
+	var encrypted bytes.Buffer
+	if err := Encrypt(bytes.NewReader(plaintext), &encrypted, "", opts); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	data := encrypted.Bytes()
+
+	for name, identity := range map[string]*Identity{"Alice": alice, "Bob": bob} {
+		t.Run(name, func(t *testing.T) {
+			var decrypted bytes.Buffer
+			err := Decrypt(bytes.NewReader(data), &decrypted, "", Options{Identities: []*Identity{identity}})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			if !bytes.Equal(decrypted.Bytes(), plaintext) {
+				t.Errorf("Expected %q, got %q", plaintext, decrypted.Bytes())
+			}
+		})
+	}
+
+	t.Run("UnknownIdentity", func(t *testing.T) {
+		err := Decrypt(bytes.NewReader(data), &bytes.Buffer{}, "", Options{Identities: []*Identity{mallory}})
+		if !errors.Is(err, ErrNoIdentity) {
+			t.Fatalf("Expected ErrNoIdentity, got %v", err)
+		}
+	})
+
+	t.Run("PasswordOnly", func(t *testing.T) {
+		err := Decrypt(bytes.NewReader(data), &bytes.Buffer{}, "password", Options{})
+		if !errors.Is(err, ErrNoIdentity) {
+			t.Fatalf("Expected ErrNoIdentity, got %v", err)
+		}
+	})
+
+	t.Run("RequiresAEAD", func(t *testing.T) {
+		err := Encrypt(bytes.NewReader(plaintext), &bytes.Buffer{}, "", Options{Recipients: opts.Recipients})
+		if err == nil {
+			t.Fatal("Expected error when encrypting to recipients in the openssl format")
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// sivSalt is the fixed salt of the key that deterministic encryption
+// derives per-file salts and nonces from. Each file is still encrypted
+// under a key derived with its own salt.
+var sivSalt = []byte("secureflow siv\x00\x00")
+
+// DeriveSIVKey derives the key for deterministic encryption (Options.SIVKey)
+// from the password, with the KDF that opts selects for new files
+func DeriveSIVKey(password string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Format != FormatAEAD {
+		return pbkdf2.Key([]byte(password), sivSalt, opts.pbkdf2Iterations(), keySize, sha256.New), nil
+	}
+	kdf, err := opts.KDF.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return kdf.deriveKey([]byte(password), sivSalt)
+}
+
+// sivReader returns the stream of bytes that stands in for randomness when
+// encrypting seed deterministically: the same key and seed always give the
+// same stream, and different seeds unrelated ones
+func sivReader(key, seed []byte) io.Reader {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(seed)
+	return hkdf.Expand(sha256.New, mac.Sum(nil), []byte("secureflow siv"))
+}
@@ -0,0 +1,83 @@
+package crypto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/values"
+)
+
+func TestDeterministicEncryption(t *testing.T) {
+	tests := map[string]Options{
+		"OpenSSL": {PBKDF2Iter: 1000},
+		"AEAD":    {Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := DeriveSIVKey("password", opts)
+			if err != nil {
+				t.Fatalf("DeriveSIVKey failed: %v", err)
+			}
+			opts.SIVKey = key
+
+			first := encryptValuesString(t, "API_KEY=abc\n", "password", opts)
+			second := encryptValuesString(t, "API_KEY=abc\n", "password", opts)
+			other := encryptValuesString(t, "API_KEY=abd\n", "password", opts)
+			if first != second {
+				t.Error("Expected the same plaintext to encrypt to the same bytes")
+			}
+			if bytes.Equal(saltOf(t, first), saltOf(t, other)) {
+				t.Error("Expected a different salt for a different plaintext")
+			}
+
+			var decrypted bytes.Buffer
+			if err := Decrypt(strings.NewReader(first), &decrypted, "password", Options{PBKDF2Iter: 1000}); err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			if decrypted.String() != "API_KEY=abc\n" {
+				t.Errorf("Expected the original content, got %q", decrypted.String())
+			}
+		})
+	}
+
+	t.Run("Values", func(t *testing.T) {
+		opts := valuesOptions(values.Dotenv)
+		key, err := DeriveSIVKey("password", opts)
+		if err != nil {
+			t.Fatalf("DeriveSIVKey failed: %v", err)
+		}
+		opts.SIVKey = key
+
+		first := strings.Split(encryptValuesString(t, "A=1\nB=2\n", "password", opts), "\n")
+		second := strings.Split(encryptValuesString(t, "A=1\nB=3\n", "password", opts), "\n")
+		if first[1] != second[1] {
+			t.Error("Expected an unchanged value to keep its ciphertext")
+		}
+	})
+
+	t.Run("Recipients", func(t *testing.T) {
+		identity, err := GenerateIdentity()
+		if err != nil {
+			t.Fatalf("GenerateIdentity failed: %v", err)
+		}
+		opts := Options{Format: FormatAEAD, Recipients: []*Recipient{identity.Recipient()}, SIVKey: []byte("key")}
+		if err := opts.Validate(); err == nil {
+			t.Error("Expected error for deterministic encryption to recipients")
+		}
+	})
+}
+
+// saltOf returns the salt of an encrypted file
+func saltOf(t *testing.T, data string) []byte {
+	t.Helper()
+	if format, _ := DetectFormat([]byte(data)); format == FormatOpenSSL {
+		return []byte(data[len(saltedPrefix) : len(saltedPrefix)+saltSize])
+	}
+	h, _, _, err := parseAEADHeader([]byte(data))
+	if err != nil {
+		t.Fatalf("Failed to parse header: %v", err)
+	}
+	return h.salt
+}
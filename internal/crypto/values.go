@@ -0,0 +1,336 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/values"
+	"golang.org/x/crypto/hkdf"
+)
+
+// A values document keeps the keys, comments and layout of a dotenv, YAML or
+// JSON file readable and replaces each value with ENC[<base64>]: a nonce and
+// the value's raw text sealed with AES-256-GCM. A metadata entry, a comment
+// on the first line or the first member of a JSON object, records the
+// syntax, a version 3 AEAD header with its MAC, and an HMAC-SHA256 over the
+// whole plaintext document:
+//
+//	# secureflow-values: yaml <base64 header> <hex document MAC>
+//	{"secureflow-values": "json <base64 header> <hex document MAC>", ...
+//
+// Each value is sealed with the header and its path as additional data, so
+// values cannot be moved between keys or documents. Its nonce is an HMAC of
+// its path and text, so while the header is kept an unchanged value
+// encrypts to the same text, and diffs show only the values that changed.
+// The document MAC covers everything else.
+const (
+	valuesMetaKey     = "secureflow-values"
+	valuesComment     = "# " + valuesMetaKey + ": "
+	valuesJSONMember  = `"` + valuesMetaKey + `": "`
+	valuesTokenPrefix = "ENC["
+	valuesTokenSuffix = "]"
+
+	// valuesPeekSize is enough input to recognise a values document
+	valuesPeekSize = 64
+)
+
+// IsValuesDocument reports whether data starts like a values document
+func IsValuesDocument(data []byte) bool {
+	if bytes.HasPrefix(data, []byte(valuesComment)) {
+		return true
+	}
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return bytes.HasPrefix(trimmed, []byte("{"+valuesJSONMember))
+}
+
+// valuesKeys are the keys derived from a values document's master key
+type valuesKeys struct {
+	gcm      cipher.AEAD
+	macKey   []byte // authenticates the header
+	nonceKey []byte // derives each value's nonce
+	docKey   []byte // authenticates the plaintext document
+}
+
+func newValuesKeys(master []byte) (*valuesKeys, error) {
+	encKey, macKey, err := deriveAEADKeys(master)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(encKey)
+	if err != nil {
+		return nil, err
+	}
+	k := &valuesKeys{gcm: gcm, macKey: macKey}
+	if k.nonceKey, err = hkdfKey(master, "secureflow values nonce"); err != nil {
+		return nil, err
+	}
+	if k.docKey, err = hkdfKey(master, "secureflow values document"); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// hkdfKey derives a subkey of master for the purpose named by info
+func hkdfKey(master []byte, info string) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte(info)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
+
+// valueAAD binds a value to the document header and its path
+func valueAAD(header []byte, path string) []byte {
+	return append(append([]byte(nil), header...), path...)
+}
+
+// seal encrypts the raw text of the value at path into a token
+func (k *valuesKeys) seal(header []byte, path string, raw []byte, syntax values.Syntax) []byte {
+	mac := hmac.New(sha256.New, k.nonceKey)
+	mac.Write([]byte(path))
+	mac.Write([]byte{0})
+	mac.Write(raw)
+	nonce := mac.Sum(nil)[:aeadNonceSize]
+
+	sealed := k.gcm.Seal(append([]byte(nil), nonce...), nonce, raw, valueAAD(header, path))
+	token := valuesTokenPrefix + base64.StdEncoding.EncodeToString(sealed) + valuesTokenSuffix
+	if syntax != values.Dotenv {
+		token = `"` + token + `"`
+	}
+	return []byte(token)
+}
+
+// open decrypts the token of the value at path back to its raw text
+func (k *valuesKeys) open(header []byte, path string, token []byte, syntax values.Syntax) ([]byte, error) {
+	text := string(token)
+	if syntax != values.Dotenv {
+		if len(text) < 2 || text[0] != '"' || text[len(text)-1] != '"' {
+			return nil, fmt.Errorf("%w: value %s is not encrypted", ErrCorrupted, path)
+		}
+		text = text[1 : len(text)-1]
+	}
+	if !strings.HasPrefix(text, valuesTokenPrefix) || !strings.HasSuffix(text, valuesTokenSuffix) {
+		return nil, fmt.Errorf("%w: value %s is not encrypted", ErrCorrupted, path)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(text[len(valuesTokenPrefix) : len(text)-len(valuesTokenSuffix)])
+	if err != nil || len(sealed) < aeadNonceSize+k.gcm.Overhead() {
+		return nil, fmt.Errorf("%w: value %s is malformed", ErrCorrupted, path)
+	}
+	raw, err := k.gcm.Open(nil, sealed[:aeadNonceSize], sealed[aeadNonceSize:], valueAAD(header, path))
+	if err != nil {
+		return nil, fmt.Errorf("%w: value %s", ErrCorrupted, path)
+	}
+	return raw, nil
+}
+
+// documentMAC authenticates a whole plaintext document
+func (k *valuesKeys) documentMAC(plaintext []byte) string {
+	mac := hmac.New(sha256.New, k.docKey)
+	mac.Write(plaintext)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// openBody decrypts every value of a document without its metadata
+func (k *valuesKeys) openBody(header, body []byte, syntax values.Syntax) ([]byte, error) {
+	found, err := values.Find(body, syntax)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	return values.Replace(body, found, func(v values.Value, token []byte) ([]byte, error) {
+		return k.open(header, v.Path, token, syntax)
+	})
+}
+
+// encryptValues encrypts each value of plaintext, a document of opts.Values
+// syntax. The header of opts.Previous is kept when the password opens it and
+// its KDF settings are unchanged; otherwise a new one is made from rnd.
+func encryptValues(plaintext, password []byte, opts Options, rnd io.Reader) ([]byte, error) {
+	found, err := values.Find(plaintext, opts.Values)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt the values of this %s document: %w", opts.Values, err)
+	}
+
+	h, header, k, err := previousValuesHeader(opts.Previous, password, opts)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		var master []byte
+		if h, master, err = newAEADHeader(aeadVersionValues, password, opts.KDF, opts.Recipients, rnd, nil); err != nil {
+			return nil, err
+		}
+		if k, err = newValuesKeys(master); err != nil {
+			return nil, err
+		}
+		header = h.marshal()
+		header = append(header, headerMAC(k.macKey, header)...)
+	}
+
+	body, err := values.Replace(plaintext, found, func(v values.Value, raw []byte) ([]byte, error) {
+		return k.seal(header, v.Path, raw, opts.Values), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	meta := fmt.Sprintf("%s %s %s", opts.Values, base64.StdEncoding.EncodeToString(header), k.documentMAC(plaintext))
+	doc := addValuesMeta(body, opts.Values, meta)
+
+	// A value whose end was misjudged would not survive a round trip, so
+	// check before anything is written
+	if check, err := k.openDocument(doc, header, false); err != nil || !bytes.Equal(check, plaintext) {
+		return nil, fmt.Errorf("cannot encrypt the values of this %s document safely; use whole-file encryption", opts.Values)
+	}
+	return doc, nil
+}
+
+// openDocument decrypts a values document whose header is already trusted
+func (k *valuesKeys) openDocument(doc, header []byte, ignoreMAC bool) ([]byte, error) {
+	syntax, _, docMAC, body, err := parseValuesMeta(doc)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := k.openBody(header, body, syntax)
+	if err != nil {
+		return nil, err
+	}
+	if !ignoreMAC && !hmac.Equal([]byte(docMAC), []byte(k.documentMAC(plaintext))) {
+		return nil, ErrCorrupted
+	}
+	return plaintext, nil
+}
+
+// previousValuesHeader returns the header of the previous encryption of a
+// document, with its keys, when it can be kept. Files encrypted to
+// recipients always get a new header, since the old one may name others.
+func previousValuesHeader(previous, password []byte, opts Options) (*aeadHeader, []byte, *valuesKeys, error) {
+	if len(previous) == 0 || len(opts.Recipients) > 0 || !IsValuesDocument(previous) {
+		return nil, nil, nil, nil
+	}
+	kdf, err := opts.KDF.withDefaults()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	syntax, header, _, _, err := parseValuesMeta(previous)
+	if err != nil || syntax != opts.Values {
+		return nil, nil, nil, nil
+	}
+	h, raw, mac, err := parseAEADHeader(header)
+	if err != nil || h.version != aeadVersionValues || len(h.stanzas) > 0 || h.kdf != kdf {
+		return nil, nil, nil, nil
+	}
+	master, err := h.masterKey(password, nil, opts.Keys)
+	if err != nil {
+		return nil, nil, nil, nil
+	}
+	k, err := newValuesKeys(master)
+	if err != nil || !hmac.Equal(mac, headerMAC(k.macKey, raw)) {
+		return nil, nil, nil, nil
+	}
+	return h, header, k, nil
+}
+
+// decryptValues authenticates and decrypts a values document
+func decryptValues(doc, password []byte, opts Options) ([]byte, error) {
+	_, header, _, _, err := parseValuesMeta(doc)
+	if err != nil {
+		return nil, err
+	}
+	h, raw, mac, err := parseAEADHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if h.version != aeadVersionValues {
+		return nil, fmt.Errorf("%w: unexpected version %d", ErrInvalidFormat, h.version)
+	}
+
+	master, err := h.masterKey(password, opts.Identities, opts.Keys)
+	if err != nil {
+		return nil, err
+	}
+	k, err := newValuesKeys(master)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, headerMAC(k.macKey, raw)) {
+		if len(h.stanzas) > 0 {
+			return nil, ErrCorrupted
+		}
+		return nil, ErrWrongPassword
+	}
+	return k.openDocument(doc, header, opts.IgnoreDocumentMAC)
+}
+
+// addValuesMeta adds the metadata entry to an encrypted document body
+func addValuesMeta(body []byte, syntax values.Syntax, meta string) []byte {
+	if syntax != values.JSON {
+		return append([]byte(valuesComment+meta+"\n"), body...)
+	}
+
+	// Find guarantees the body is an object
+	brace := bytes.IndexByte(body, '{') + 1
+	member := valuesJSONMember + meta + `"`
+	if rest := bytes.TrimLeft(body[brace:], " \t\r\n"); len(rest) > 0 && rest[0] != '}' {
+		member += ","
+	}
+
+	out := make([]byte, 0, len(body)+len(member))
+	out = append(out, body[:brace]...)
+	out = append(out, member...)
+	return append(out, body[brace:]...)
+}
+
+// parseValuesMeta splits a values document into its syntax, the encoded
+// header with its MAC, the document MAC and the body that holds the values
+func parseValuesMeta(doc []byte) (syntax values.Syntax, header []byte, docMAC string, body []byte, err error) {
+	var meta string
+	if bytes.HasPrefix(doc, []byte(valuesComment)) {
+		end := bytes.IndexByte(doc, '\n')
+		if end < 0 {
+			return "", nil, "", nil, fmt.Errorf("%w: truncated metadata", ErrInvalidFormat)
+		}
+		meta = string(doc[len(valuesComment):end])
+		body = doc[end+1:]
+	} else {
+		brace := bytes.IndexByte(doc, '{') + 1
+		rest := doc[brace:]
+		if brace == 0 || !bytes.HasPrefix(rest, []byte(valuesJSONMember)) {
+			return "", nil, "", nil, ErrInvalidFormat
+		}
+		rest = rest[len(valuesJSONMember):]
+		end := bytes.IndexByte(rest, '"')
+		if end < 0 {
+			return "", nil, "", nil, fmt.Errorf("%w: truncated metadata", ErrInvalidFormat)
+		}
+		meta = string(rest[:end])
+		rest = rest[end+1:]
+		if len(rest) > 0 && rest[0] == ',' {
+			rest = rest[1:]
+		}
+		body = append(append([]byte(nil), doc[:brace]...), rest...)
+	}
+
+	fields := strings.Fields(meta)
+	if len(fields) != 3 {
+		return "", nil, "", nil, fmt.Errorf("%w: malformed metadata", ErrInvalidFormat)
+	}
+	if syntax, err = values.ParseSyntax(fields[0]); err != nil {
+		return "", nil, "", nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	if (syntax == values.JSON) != !bytes.HasPrefix(doc, []byte(valuesComment)) {
+		return "", nil, "", nil, fmt.Errorf("%w: metadata does not match the %s syntax", ErrInvalidFormat, syntax)
+	}
+	if header, err = base64.StdEncoding.DecodeString(fields[1]); err != nil {
+		return "", nil, "", nil, fmt.Errorf("%w: malformed header", ErrInvalidFormat)
+	}
+	return syntax, header, fields[2], body, nil
+}
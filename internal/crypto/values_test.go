@@ -0,0 +1,153 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/values"
+)
+
+// valuesOptions keeps the tests fast
+func valuesOptions(syntax values.Syntax) Options {
+	return Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}, Values: syntax}
+}
+
+func encryptValuesString(t *testing.T, doc, password string, opts Options) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Encrypt(strings.NewReader(doc), &out, password, opts); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	return out.String()
+}
+
+func TestEncryptDecryptValues(t *testing.T) {
+	docs := map[values.Syntax]string{
+		values.Dotenv: "# database\nDB_HOST=localhost\nDB_PASSWORD=\"hunter2\"\n",
+		values.YAML:   "db:\n  host: localhost\n  password: hunter2 # rotate yearly\n  certs:\n    - |\n      -----BEGIN-----\n      certificate-body\n",
+		values.JSON:   "{\n  \"db\": {\"host\": \"localhost\", \"port\": 54321}\n}\n",
+	}
+
+	for syntax, doc := range docs {
+		t.Run(string(syntax), func(t *testing.T) {
+			encrypted := encryptValuesString(t, doc, "password", valuesOptions(syntax))
+			for _, secret := range []string{"localhost", "hunter2", "54321", "certificate-body"} {
+				if strings.Contains(encrypted, secret) {
+					t.Errorf("Encrypted document contains %q:\n%s", secret, encrypted)
+				}
+			}
+			if !strings.Contains(strings.ToLower(encrypted), "host") || !IsValuesDocument([]byte(encrypted)) {
+				t.Errorf("Expected readable keys in a values document, got:\n%s", encrypted)
+			}
+
+			var decrypted bytes.Buffer
+			if err := Decrypt(strings.NewReader(encrypted), &decrypted, "password", Options{}); err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			if decrypted.String() != doc {
+				t.Errorf("Expected %q, got %q", doc, decrypted.String())
+			}
+		})
+	}
+}
+
+func TestEncryptValuesKeepsUnchangedValues(t *testing.T) {
+	opts := valuesOptions(values.Dotenv)
+	first := encryptValuesString(t, "A=1\nB=2\n", "password", opts)
+
+	opts.Previous = []byte(first)
+	second := encryptValuesString(t, "A=1\nB=3\n", "password", opts)
+
+	firstLines, secondLines := strings.Split(first, "\n"), strings.Split(second, "\n")
+	if firstLines[1] != secondLines[1] {
+		t.Errorf("Expected unchanged value A to keep its ciphertext, got %q and %q", firstLines[1], secondLines[1])
+	}
+	if firstLines[2] == secondLines[2] {
+		t.Error("Expected changed value B to get a new ciphertext")
+	}
+
+	t.Run("NewPassword", func(t *testing.T) {
+		third := encryptValuesString(t, "A=1\nB=2\n", "other", opts)
+		if strings.Split(third, "\n")[1] == firstLines[1] {
+			t.Error("Expected a new salt when the password changes")
+		}
+	})
+}
+
+func TestDecryptValuesErrors(t *testing.T) {
+	encrypted := encryptValuesString(t, "A=1\nB=2\n", "password", valuesOptions(values.Dotenv))
+	lines := strings.Split(encrypted, "\n")
+	valueA := strings.TrimPrefix(lines[1], "A=")
+	valueB := strings.TrimPrefix(lines[2], "B=")
+
+	tests := []struct {
+		name     string
+		doc      string
+		password string
+		expected error
+	}{
+		{"WrongPassword", encrypted, "wrong", ErrWrongPassword},
+		{"RenamedKey", strings.Replace(encrypted, "\nA=", "\nC=", 1), "password", ErrCorrupted},
+		{"SwappedValues", strings.NewReplacer(valueA, valueB, valueB, valueA).Replace(encrypted), "password", ErrCorrupted},
+		{"PlaintextValue", strings.Replace(encrypted, lines[2], "B=2", 1), "password", ErrCorrupted},
+		{"AddedComment", encrypted + "# note\n", "password", ErrCorrupted},
+		{"BadMetadata", "# secureflow-values: dotenv\nA=1\n", "password", ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Decrypt(strings.NewReader(tt.doc), &bytes.Buffer{}, tt.password, Options{})
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestDecryptValuesIgnoreDocumentMAC(t *testing.T) {
+	opts := valuesOptions(values.Dotenv)
+	base := encryptValuesString(t, "A=1\n", "password", opts)
+	opts.Previous = []byte(base)
+	ours := encryptValuesString(t, "A=1\nB=2\n", "password", opts)
+	theirs := encryptValuesString(t, "A=1\nC=3\n", "password", opts)
+
+	// A merge of both sides keeps our metadata line and adds their value
+	merged := ours + strings.SplitN(theirs, "\n", 3)[2]
+	if err := Decrypt(strings.NewReader(merged), &bytes.Buffer{}, "password", Options{}); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted for a merged document, got %v", err)
+	}
+
+	var decrypted bytes.Buffer
+	if err := Decrypt(strings.NewReader(merged), &decrypted, "password", Options{IgnoreDocumentMAC: true}); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if decrypted.String() != "A=1\nB=2\nC=3\n" {
+		t.Errorf("Expected the merged document, got %q", decrypted.String())
+	}
+}
+
+func TestEncryptValuesRecipients(t *testing.T) {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	opts := Options{Format: FormatAEAD, Recipients: []*Recipient{identity.Recipient()}, Values: values.JSON}
+	encrypted := encryptValuesString(t, `{"token": "abc"}`, "", opts)
+
+	var decrypted bytes.Buffer
+	if err := Decrypt(strings.NewReader(encrypted), &decrypted, "", Options{Identities: []*Identity{identity}}); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if decrypted.String() != `{"token": "abc"}` {
+		t.Errorf("Expected the original document, got %q", decrypted.String())
+	}
+}
+
+func TestEncryptValuesRequiresAEAD(t *testing.T) {
+	err := Encrypt(strings.NewReader("A=1\n"), &bytes.Buffer{}, "password", Options{Values: values.Dotenv})
+	if err == nil {
+		t.Fatal("Expected error when encrypting values in the openssl format")
+	}
+}
@@ -0,0 +1,238 @@
+// Package diff produces unified line diffs of decrypted secrets
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change
+const DefaultContext = 3
+
+// MaxEdits is the most added and removed lines a diff is worked out for;
+// the memory needed grows with its square
+const MaxEdits = 2000
+
+// ErrTooManyChanges is returned when the inputs differ by more than MaxEdits
+// lines
+var ErrTooManyChanges = fmt.Errorf("more than %d lines changed", MaxEdits)
+
+// Line is one line of a unified diff. Kind is ' ' for context, '-' for a
+// removed line and '+' for an added line. Text includes the trailing newline
+// unless it is the last line of a file without one.
+type Line struct {
+	Kind byte
+	Text string
+}
+
+// Hunk is a group of nearby changes with their context
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header returns the @@ line of the hunk
+func (h *Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// IsBinary reports whether data looks like a binary file
+func IsBinary(data []byte) bool {
+	probe := data
+	if len(probe) > 8000 {
+		probe = probe[:8000]
+	}
+	return bytes.IndexByte(probe, 0) >= 0
+}
+
+// Hunks compares old and new line by line and groups the changes into hunks
+// with context unchanged lines around them. It returns nil when the inputs
+// are equal, and ErrTooManyChanges when they differ too much to compare.
+func Hunks(old, new []byte, context int) ([]*Hunk, error) {
+	ops, err := editScript(splitLines(old), splitLines(new))
+	if err != nil {
+		return nil, err
+	}
+
+	// Indices of changed lines in the edit script
+	var changes []int
+	for i, op := range ops {
+		if op.Kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	// Line numbers before each op
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.Kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.Kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var hunks []*Hunk
+	for i := 0; i < len(changes); {
+		// Merge changes whose context would overlap
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*context+1 {
+			j++
+		}
+
+		start := max(changes[i]-context, 0)
+		end := min(changes[j]+context+1, len(ops))
+		h := &Hunk{
+			OldStart: oldPos[start] + 1,
+			OldLines: oldPos[end] - oldPos[start],
+			NewStart: newPos[start] + 1,
+			NewLines: newPos[end] - newPos[start],
+			Lines:    ops[start:end],
+		}
+		// An empty range names the line before it, as diff -u does
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+		i = j + 1
+	}
+	return hunks, nil
+}
+
+// Unified renders a unified diff of old and new, or "" when they are equal.
+// Like Hunks, it fails with ErrTooManyChanges.
+func Unified(oldName, newName string, old, new []byte, context int) (string, error) {
+	hunks, err := Hunks(old, new, context)
+	if hunks == nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		b.WriteString(h.Header())
+		b.WriteByte('\n')
+		for _, line := range h.Lines {
+			b.WriteString(line.String())
+		}
+	}
+	return b.String(), nil
+}
+
+// String renders the line with its prefix and a trailing newline, marking a
+// missing newline at end of file the way diff does
+func (l Line) String() string {
+	if strings.HasSuffix(l.Text, "\n") {
+		return string(l.Kind) + l.Text
+	}
+	return string(l.Kind) + l.Text + "\n\\ No newline at end of file\n"
+}
+
+// hunkRange formats a start,count pair, omitting a count of one
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits data after each newline
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// editScript computes a shortest edit script from a to b with Myers'
+// algorithm, giving up with ErrTooManyChanges after MaxEdits edits
+func editScript(a, b []string) ([]Line, error) {
+	n, m := len(a), len(b)
+	maxD := min(n+m, MaxEdits)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+
+	// trace[d] holds the furthest x reached on diagonals -d-1 to d+1 before
+	// round d, which are all that round d and backtrack read
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace), nil
+			}
+		}
+	}
+	return nil, ErrTooManyChanges
+}
+
+// backtrack walks the Myers trace from the end to recover the edit script
+func backtrack(a, b []string, trace [][]int) []Line {
+	var ops []Line
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, Line{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, Line{'+', b[y-1]})
+				y--
+			} else {
+				ops = append(ops, Line{'-', a[x-1]})
+				x--
+			}
+		}
+	}
+
+	// The walk produced the script backwards
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "Equal",
+			old:      "A=1\nB=2\n",
+			new:      "A=1\nB=2\n",
+			expected: "",
+		},
+		{
+			name: "ChangedLine",
+			old:  "A=1\nB=2\nC=3\n",
+			new:  "A=1\nB=two\nC=3\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,3 +1,3 @@\n A=1\n-B=2\n+B=two\n C=3\n",
+		},
+		{
+			name: "AddedToEmpty",
+			old:  "",
+			new:  "A=1\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -0,0 +1 @@\n+A=1\n",
+		},
+		{
+			name: "RemovedAll",
+			old:  "A=1\nB=2\n",
+			new:  "",
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,2 +0,0 @@\n-A=1\n-B=2\n",
+		},
+		{
+			name: "NoNewlineAtEnd",
+			old:  "A=1\n",
+			new:  "A=1",
+			expected: "--- old\n+++ new\n" +
+				"@@ -1 +1 @@\n-A=1\n+A=1\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unified("old", "new", []byte(tt.old), []byte(tt.new), DefaultContext)
+			if err != nil {
+				t.Fatalf("Unified failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Unified() =\n%s\nexpected\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHunksSplitDistantChanges(t *testing.T) {
+	var old, new strings.Builder
+	for i := 0; i < 20; i++ {
+		line := strings.Repeat("x", i+1) + "\n"
+		old.WriteString(line)
+		if i == 1 || i == 18 {
+			line = "changed\n"
+		}
+		new.WriteString(line)
+	}
+
+	hunks, err := Hunks([]byte(old.String()), []byte(new.String()), DefaultContext)
+	if err != nil {
+		t.Fatalf("Hunks failed: %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -1,5 +1,5 @@" {
+		t.Errorf("Unexpected first hunk header %q", got)
+	}
+	if got := hunks[1].Header(); got != "@@ -16,5 +16,5 @@" {
+		t.Errorf("Unexpected second hunk header %q", got)
+	}
+}
+
+func TestHunksMaxEdits(t *testing.T) {
+	// lines returns n distinct lines starting with prefix
+	lines := func(prefix string, n int) []byte {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "%s%d\n", prefix, i)
+		}
+		return []byte(b.String())
+	}
+
+	// Replacing every line takes one removal and one addition per line
+	hunks, err := Hunks(lines("old", MaxEdits/2), lines("new", MaxEdits/2), DefaultContext)
+	if err != nil {
+		t.Fatalf("Hunks failed at the limit: %v", err)
+	}
+	if len(hunks) != 1 || len(hunks[0].Lines) != MaxEdits {
+		t.Errorf("Expected one hunk of %d lines", MaxEdits)
+	}
+
+	if _, err := Hunks(lines("old", MaxEdits/2+1), lines("new", MaxEdits/2), DefaultContext); !errors.Is(err, ErrTooManyChanges) {
+		t.Errorf("Expected ErrTooManyChanges over the limit, got %v", err)
+	}
+	if _, err := Unified("old", "new", lines("old", MaxEdits), lines("new", MaxEdits), DefaultContext); !errors.Is(err, ErrTooManyChanges) {
+		t.Errorf("Expected Unified to fail with ErrTooManyChanges, got %v", err)
+	}
+
+	// Many lines with few changes are still compared
+	big := lines("line", 10*MaxEdits)
+	changed := append([]byte("first\n"), big...)
+	if hunks, err := Hunks(big, changed, DefaultContext); err != nil || len(hunks) != 1 {
+		t.Errorf("Expected one hunk for a large file with one change, got %d (%v)", len(hunks), err)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if IsBinary([]byte("KEY=value\n")) {
+		t.Error("Expected text not to be binary")
+	}
+	if !IsBinary([]byte{0xfe, 0xed, 0x00, 0x02}) {
+		t.Error("Expected data with NUL bytes to be binary")
+	}
+}
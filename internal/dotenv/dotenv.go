@@ -0,0 +1,237 @@
+// Package dotenv parses .env-style files
+package dotenv
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Entry is one KEY=VALUE assignment
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// IsDotenv reports whether path names a .env-style file, such as .env,
+// .env.prod or production.env
+func IsDotenv(path string) bool {
+	name := filepath.Base(path)
+	return name == ".env" || strings.HasPrefix(name, ".env.") || strings.HasSuffix(name, ".env")
+}
+
+// Parse reads KEY=VALUE assignments in file order. It accepts blank lines,
+// # comments, an optional "export " prefix, single-quoted values taken
+// literally, double-quoted values with \n, \t, \" and \\ escapes that may
+// span lines, and unquoted values with trailing " #" comments removed.
+func Parse(data []byte) ([]Entry, error) {
+	var entries []Entry
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validKey(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single-quoted value", lineNo)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			// Double-quoted values may continue on the following lines
+			raw := value[1:]
+			for {
+				if end := closingQuote(raw); end >= 0 {
+					raw = raw[:end]
+					break
+				}
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated double-quoted value", lineNo)
+				}
+				raw += "\n" + lines[i]
+			}
+			value = unescape(raw)
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+
+		entries = append(entries, Entry{Key: key, Value: value})
+	}
+	return entries, nil
+}
+
+// Span is where an assignment's value appears in a file
+type Span struct {
+	Key        string
+	Start, End int // byte offsets of the raw value text
+}
+
+// Locate finds the raw text of each value, as written after the = and up to
+// the end of its line, or of the line holding the closing quote of a
+// multi-line double-quoted value. It accepts what Parse accepts; replacing
+// a span with other text leaves the rest of the file untouched.
+func Locate(data []byte) ([]Span, error) {
+	var spans []Span
+	s := string(data)
+	lineNo := 0
+
+	for pos := 0; pos < len(s); {
+		lineNo++
+		end := lineEnd(s, pos)
+		line := s[pos:end]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			pos = nextLine(s, end)
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		key := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[:max(eq, 0)]), "export "))
+		if eq < 0 || !validKey(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+
+		start := pos + eq + 1
+		if strings.HasPrefix(strings.TrimSpace(s[start:end]), `"`) {
+			// Double-quoted values may continue on the following lines
+			open := start + strings.IndexByte(s[start:end], '"')
+			closing := closingQuote(s[open+1:])
+			if closing < 0 {
+				return nil, fmt.Errorf("line %d: unterminated double-quoted value", lineNo)
+			}
+			lineNo += strings.Count(s[open:open+1+closing], "\n")
+			end = lineEnd(s, open+1+closing)
+		}
+
+		spans = append(spans, Span{Key: key, Start: start, End: end})
+		pos = nextLine(s, end)
+	}
+	return spans, nil
+}
+
+// lineEnd returns the offset of the line break ending the line at pos, not
+// counting the \r of a \r\n
+func lineEnd(s string, pos int) int {
+	end := len(s)
+	if i := strings.IndexByte(s[pos:], '\n'); i >= 0 {
+		end = pos + i
+	}
+	if end > pos && s[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// nextLine returns the offset of the line after the one ending at end
+func nextLine(s string, end int) int {
+	if i := strings.IndexByte(s[end:], '\n'); i >= 0 {
+		return end + i + 1
+	}
+	return len(s)
+}
+
+// Mask renders entries one per line as KEY=******** followed by a tag for
+// the value, so a diff of two masked files shows which keys were added,
+// removed or changed without revealing any value
+func Mask(entries []Entry, tag func(value string) string) []byte {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s=******** [%s]\n", e.Key, tag(e.Value))
+	}
+	return []byte(b.String())
+}
+
+// Merge returns environ, in os.Environ form, with entries added. Entries
+// override variables already in environ, and later entries override earlier
+// ones.
+func Merge(environ []string, entries []Entry) []string {
+	index := make(map[string]int, len(environ)+len(entries))
+	merged := make([]string, 0, len(environ)+len(entries))
+	set := func(key, kv string) {
+		if i, ok := index[key]; ok {
+			merged[i] = kv
+			return
+		}
+		index[key] = len(merged)
+		merged = append(merged, kv)
+	}
+
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		set(key, kv)
+	}
+	for _, e := range entries {
+		set(e.Key, e.Key+"="+e.Value)
+	}
+	return merged
+}
+
+// validKey reports whether key is a usable environment variable name
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		case c == '.' || c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// closingQuote returns the index of the first unescaped double quote in s,
+// or -1
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape expands the escapes allowed in double-quoted values
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package dotenv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Entry
+		wantErr  bool
+	}{
+		{
+			name:     "Simple",
+			input:    "A=1\nB=two\n",
+			expected: []Entry{{"A", "1"}, {"B", "two"}},
+		},
+		{
+			name:     "CommentsAndBlankLines",
+			input:    "# comment\n\nA=1 # trailing\n  B = 2  \n",
+			expected: []Entry{{"A", "1"}, {"B", "2"}},
+		},
+		{
+			name:     "Export",
+			input:    "export API_KEY=abc\n",
+			expected: []Entry{{"API_KEY", "abc"}},
+		},
+		{
+			name:     "SingleQuoted",
+			input:    "A='raw \\n # not a comment'\n",
+			expected: []Entry{{"A", "raw \\n # not a comment"}},
+		},
+		{
+			name:     "DoubleQuotedEscapes",
+			input:    "A=\"line1\\nline2 \\\"quoted\\\"\"\n",
+			expected: []Entry{{"A", "line1\nline2 \"quoted\""}},
+		},
+		{
+			name:     "DoubleQuotedMultiline",
+			input:    "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT=1\n",
+			expected: []Entry{{"KEY", "-----BEGIN-----\nabc\n-----END-----"}, {"NEXT", "1"}},
+		},
+		{
+			name:     "EmptyValue",
+			input:    "EMPTY=\n",
+			expected: []Entry{{"EMPTY", ""}},
+		},
+		{
+			name:     "CRLF",
+			input:    "A=1\r\nB=2\r\n",
+			expected: []Entry{{"A", "1"}, {"B", "2"}},
+		},
+		{
+			name:    "MissingEquals",
+			input:   "NOT_AN_ASSIGNMENT\n",
+			wantErr: true,
+		},
+		{
+			name:    "UnterminatedQuote",
+			input:   "A=\"open\nB=2\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Parse([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(entries, tt.expected) {
+				t.Errorf("Parse() = %q, expected %q", entries, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	input := "# comment\nA=1\nexport B = two # note\nC=\"multi\nline\"\r\nEMPTY=\n"
+	spans, err := Locate([]byte(input))
+	if err != nil {
+		t.Fatalf("Locate failed: %v", err)
+	}
+
+	expected := map[string]string{"A": "1", "B": " two # note", "C": "\"multi\nline\"", "EMPTY": ""}
+	if len(spans) != len(expected) {
+		t.Fatalf("Locate() found %d values, expected %d", len(spans), len(expected))
+	}
+	for _, s := range spans {
+		if raw := input[s.Start:s.End]; raw != expected[s.Key] {
+			t.Errorf("Locate() value of %s = %q, expected %q", s.Key, raw, expected[s.Key])
+		}
+	}
+
+	if _, err := Locate([]byte("A=\"open\n")); err == nil {
+		t.Error("Expected error for an unterminated double-quoted value")
+	}
+}
+
+func TestIsDotenv(t *testing.T) {
+	tests := map[string]bool{
+		".env":                  true,
+		".env.prod":             true,
+		"config/production.env": true,
+		"keystore.jks":          false,
+		"environment.yaml":      false,
+	}
+
+	for path, expected := range tests {
+		if got := IsDotenv(path); got != expected {
+			t.Errorf("IsDotenv(%q) = %v, expected %v", path, got, expected)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	entries := []Entry{{"A", "secret"}, {"B", "other"}}
+	masked := Mask(entries, func(value string) string { return "tag-" + string(value[0]) })
+
+	expected := "A=******** [tag-s]\nB=******** [tag-o]\n"
+	if string(masked) != expected {
+		t.Errorf("Mask() = %q, expected %q", masked, expected)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	environ := []string{"PATH=/bin", "API_KEY=old", "HOME=/root"}
+	entries := []Entry{{"API_KEY", "new"}, {"DB_URL", "postgres://"}, {"DB_URL", "mysql://"}}
+
+	expected := []string{"PATH=/bin", "API_KEY=new", "HOME=/root", "DB_URL=mysql://"}
+	if got := Merge(environ, entries); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Merge() = %q, expected %q", got, expected)
+	}
+}
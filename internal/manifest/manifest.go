@@ -0,0 +1,319 @@
+// Package manifest records what encrypt produced, so later commands can tell
+// whether encrypted files are intact and whether their plaintext has changed.
+//
+// The manifest never stores plaintext hashes directly: a bare SHA-256 of a
+// short secret can be brute-forced. Plaintext is fingerprinted with an
+// HMAC-SHA256 keyed by Argon2id over the password and a per-manifest salt,
+// so checking a guess costs as much as attacking the encrypted file itself.
+//
+// The same key authenticates each encrypted file, which matters for the
+// OpenSSL format: it has no MAC of its own, so without the manifest a
+// corrupted file and a wrong password look alike.
+package manifest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"golang.org/x/crypto/argon2"
+)
+
+// FileName is the manifest's name inside the output directory
+const FileName = "manifest.json"
+
+// Version is the manifest schema version written by this package
+const Version = 1
+
+// Key derivation for plaintext fingerprints; changing these requires a new
+// schema version
+const (
+	saltSize     = 16
+	keySize      = 32
+	argon2Time   = 3
+	argon2Memory = 64 * 1024
+	argon2Lanes  = 4
+)
+
+// keyCheckLabel is the message MACed to record which password the
+// manifest was written with
+const keyCheckLabel = "secureflow manifest key check"
+
+var (
+	// ErrMismatch is returned when a file does not match its manifest entry
+	ErrMismatch = errors.New("file does not match the manifest")
+	// ErrWrongPassword is returned when a password is not the one the
+	// manifest was written with. It is crypto.ErrWrongPassword, so one
+	// check covers both the manifest and a file rejecting the password.
+	ErrWrongPassword = crypto.ErrWrongPassword
+)
+
+// Manifest describes one run of encrypt
+type Manifest struct {
+	Version      int       `json:"version"`
+	ToolVersion  string    `json:"tool_version"`
+	CreatedAt    time.Time `json:"created_at"`
+	Note         string    `json:"note,omitempty"`
+	PasswordHint string    `json:"password_hint,omitempty"`
+	Format       string    `json:"format"`
+	KDF          string    `json:"kdf"`
+	Recipients   int       `json:"recipients,omitempty"`
+	HMACSalt     string    `json:"hmac_salt,omitempty"` // hex; empty when encrypting to recipients
+	KeyCheck     string    `json:"key_check,omitempty"` // identifies the password the fingerprints are keyed with
+	Files        []Entry   `json:"files"`
+}
+
+// Entry describes one encrypted file
+type Entry struct {
+	Input            string    `json:"input"`
+	Output           string    `json:"output"`
+	Size             int64     `json:"size"`
+	Lines            int       `json:"lines"`
+	ModifiedAt       time.Time `json:"modified_at"`
+	Mode             string    `json:"mode,omitempty"` // octal permissions of the plaintext, e.g. "0640"
+	PlaintextHMAC    string    `json:"plaintext_hmac,omitempty"`
+	CiphertextSHA256 string    `json:"ciphertext_sha256"`
+	CiphertextHMAC   string    `json:"ciphertext_hmac,omitempty"`
+}
+
+// New returns an empty manifest with a fresh HMAC salt
+func New(toolVersion string) (*Manifest, error) {
+	m := &Manifest{
+		Version:     Version,
+		ToolVersion: toolVersion,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	if err := m.NewSalt(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewSalt replaces the HMAC salt, as when the password changes. Existing
+// fingerprints and the key check must be recomputed afterwards.
+func (m *Manifest) NewSalt() error {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	m.HMACSalt = hex.EncodeToString(salt)
+	m.KeyCheck = ""
+	return nil
+}
+
+// Path returns the manifest path inside dir
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// Load reads the manifest from dir. The error wraps fs.ErrNotExist when
+// there is no manifest.
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(Path(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to dir
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(Path(dir), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Entry returns the entry for the encrypted file named output, or nil. It
+// is safe to call on a nil manifest.
+func (m *Manifest) Entry(output string) *Entry {
+	if m == nil {
+		return nil
+	}
+	for i := range m.Files {
+		if m.Files[i].Output == output {
+			return &m.Files[i]
+		}
+	}
+	return nil
+}
+
+// SetPerm records the plaintext file's permissions
+func (e *Entry) SetPerm(perm os.FileMode) {
+	e.Mode = fmt.Sprintf("%04o", uint32(perm.Perm()))
+}
+
+// Perm returns the recorded plaintext permissions. ok is false for entries
+// written before modes were recorded.
+func (e *Entry) Perm() (perm os.FileMode, ok bool) {
+	if e == nil || e.Mode == "" {
+		return 0, false
+	}
+	mode, err := strconv.ParseUint(e.Mode, 8, 32)
+	if err != nil {
+		return 0, false
+	}
+	return os.FileMode(mode).Perm(), true
+}
+
+// Set adds e, replacing any entry for the same output
+func (m *Manifest) Set(e Entry) {
+	if existing := m.Entry(e.Output); existing != nil {
+		*existing = e
+		return
+	}
+	m.Files = append(m.Files, e)
+}
+
+// Key derives the HMAC key from password. It returns nil when the manifest
+// has no salt, as for files encrypted to recipients, and an error wrapping
+// ErrWrongPassword when the manifest records a different password.
+func (m *Manifest) Key(password string) ([]byte, error) {
+	if m.HMACSalt == "" || password == "" {
+		return nil, nil
+	}
+	salt, err := hex.DecodeString(m.HMACSalt)
+	if err != nil || len(salt) != saltSize {
+		return nil, fmt.Errorf("invalid manifest salt")
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Lanes, keySize)
+
+	if m.KeyCheck != "" && !hmac.Equal([]byte(HMAC(key, []byte(keyCheckLabel))), []byte(m.KeyCheck)) {
+		return nil, fmt.Errorf("%w: %s was written with a different password", ErrWrongPassword, FileName)
+	}
+	return key, nil
+}
+
+// SetKeyCheck records that the manifest was written with the password
+// key was derived from, so Key can reject any other
+func (m *Manifest) SetKeyCheck(key []byte) {
+	m.KeyCheck = ""
+	if key != nil {
+		m.KeyCheck = HMAC(key, []byte(keyCheckLabel))
+	}
+}
+
+// HasKeyCheck reports whether Key can tell a wrong password apart
+func (m *Manifest) HasKeyCheck() bool {
+	return m != nil && m.KeyCheck != ""
+}
+
+// HMACFile returns the hex HMAC-SHA256 of the file at path under key
+func HMACFile(key []byte, path string) (string, error) {
+	return hashFile(hmac.New(sha256.New, key), path)
+}
+
+// SHA256File returns the hex SHA-256 of the file at path
+func SHA256File(path string) (string, error) {
+	return hashFile(sha256.New(), path)
+}
+
+// HMAC returns the hex HMAC-SHA256 of data under key
+func HMAC(key, data []byte) string {
+	h := NewHMAC(key)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewHMAC returns the hash behind HMAC, for fingerprinting content as it
+// is streamed
+func NewHMAC(key []byte) hash.Hash {
+	return hmac.New(sha256.New, key)
+}
+
+// SHA256 returns the hex SHA-256 of data
+func SHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyCiphertext checks the encrypted file at path against the entry
+func (e *Entry) VerifyCiphertext(path string) error {
+	sum, err := SHA256File(path)
+	if err != nil {
+		return err
+	}
+	if sum != e.CiphertextSHA256 {
+		return fmt.Errorf("%w: %s has changed since the manifest was written", ErrMismatch, path)
+	}
+	return nil
+}
+
+// AuthenticateCiphertext checks the encrypted file at path against the
+// entry's keyed fingerprint. Unlike VerifyCiphertext, it also catches a file
+// replaced along with its recorded checksum. It does nothing when the entry
+// or key has no ciphertext fingerprint.
+func (e *Entry) AuthenticateCiphertext(key []byte, path string) error {
+	if key == nil || e.CiphertextHMAC == "" {
+		return nil
+	}
+	sum, err := HMACFile(key, path)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sum), []byte(e.CiphertextHMAC)) {
+		return fmt.Errorf("%w: %s fails authentication", ErrMismatch, path)
+	}
+	return nil
+}
+
+// VerifyPlaintext checks the decrypted file at path against the entry. It
+// does nothing when the entry or key has no plaintext fingerprint.
+func (e *Entry) VerifyPlaintext(key []byte, path string) error {
+	if key == nil || e.PlaintextHMAC == "" {
+		return nil
+	}
+	sum, err := HMACFile(key, path)
+	if err != nil {
+		return err
+	}
+	return e.CheckPlaintext(sum)
+}
+
+// CheckPlaintext compares a plaintext fingerprint computed with NewHMAC, as
+// hex, against the entry
+func (e *Entry) CheckPlaintext(sum string) error {
+	if !hmac.Equal([]byte(sum), []byte(e.PlaintextHMAC)) {
+		return fmt.Errorf("%w: decrypted content of %s differs from what was encrypted", ErrMismatch, e.Output)
+	}
+	return nil
+}
+
+// hashFile streams the file at path through h
+func hashFile(h hash.Hash, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,220 @@
+package manifest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	m, err := New("1.2.3")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	m.Format = "aead"
+	m.Files = []Entry{{Input: ".env", Output: ".env.encrypted", Size: 42, CiphertextSHA256: "abc"}}
+
+	if err := m.Save(tmpDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.ToolVersion != "1.2.3" || loaded.HMACSalt != m.HMACSalt || !loaded.CreatedAt.Equal(m.CreatedAt) {
+		t.Errorf("Loaded manifest %+v does not match saved %+v", loaded, m)
+	}
+
+	entry := loaded.Entry(".env.encrypted")
+	if entry == nil || entry.Size != 42 {
+		t.Fatalf("Expected entry for .env.encrypted, got %+v", entry)
+	}
+	if loaded.Entry("missing") != nil {
+		t.Error("Expected no entry for unknown output")
+	}
+}
+
+func TestSet(t *testing.T) {
+	m := &Manifest{Files: []Entry{{Output: "a", Size: 1}, {Output: "b", Size: 2}}}
+
+	m.Set(Entry{Output: "a", Size: 10})
+	m.Set(Entry{Output: "c", Size: 3})
+
+	if len(m.Files) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(m.Files))
+	}
+	if m.Files[0].Size != 10 || m.Files[1].Size != 2 || m.Files[2].Output != "c" {
+		t.Errorf("Unexpected entries: %+v", m.Files)
+	}
+}
+
+func TestPerm(t *testing.T) {
+	var entry Entry
+	if _, ok := entry.Perm(); ok {
+		t.Error("Expected no mode for an entry without one")
+	}
+
+	entry.SetPerm(0640)
+	if entry.Mode != "0640" {
+		t.Errorf("Expected mode %q, got %q", "0640", entry.Mode)
+	}
+	if perm, ok := entry.Perm(); !ok || perm != 0640 {
+		t.Errorf("Expected 0640, got %04o (ok=%v)", perm, ok)
+	}
+
+	var missing *Entry
+	if _, ok := missing.Perm(); ok {
+		t.Error("Expected no mode for a nil entry")
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	if _, err := Load(t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestKeyDependsOnPasswordAndSalt(t *testing.T) {
+	m, err := New("test")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	key1, _ := m.Key("password")
+	key2, _ := m.Key("password")
+	other, _ := m.Key("other")
+	if string(key1) != string(key2) {
+		t.Error("Expected the same key for the same password")
+	}
+	if string(key1) == string(other) {
+		t.Error("Expected different keys for different passwords")
+	}
+
+	if err := m.NewSalt(); err != nil {
+		t.Fatalf("NewSalt failed: %v", err)
+	}
+	resalted, _ := m.Key("password")
+	if string(key1) == string(resalted) {
+		t.Error("Expected a different key after NewSalt")
+	}
+
+	m.HMACSalt = ""
+	if key, err := m.Key("password"); key != nil || err != nil {
+		t.Errorf("Expected no key without a salt, got %x, %v", key, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+	plainPath := filepath.Join(tmpDir, "plain.txt")
+	cipherPath := filepath.Join(tmpDir, "plain.txt.encrypted")
+	if err := os.WriteFile(plainPath, []byte("API_KEY=secret\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(cipherPath, []byte("ciphertext"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	fingerprint, err := HMACFile(key, plainPath)
+	if err != nil {
+		t.Fatalf("HMACFile failed: %v", err)
+	}
+	if fingerprint != HMAC(key, []byte("API_KEY=secret\n")) {
+		t.Error("HMACFile and HMAC disagree")
+	}
+	sum, err := SHA256File(cipherPath)
+	if err != nil {
+		t.Fatalf("SHA256File failed: %v", err)
+	}
+
+	entry := &Entry{Output: "plain.txt.encrypted", PlaintextHMAC: fingerprint, CiphertextSHA256: sum}
+	if err := entry.VerifyCiphertext(cipherPath); err != nil {
+		t.Errorf("VerifyCiphertext failed: %v", err)
+	}
+	if err := entry.VerifyPlaintext(key, plainPath); err != nil {
+		t.Errorf("VerifyPlaintext failed: %v", err)
+	}
+
+	if err := os.WriteFile(plainPath, []byte("API_KEY=changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(cipherPath, []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := entry.VerifyCiphertext(cipherPath); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected ErrMismatch for changed ciphertext, got %v", err)
+	}
+	if err := entry.VerifyPlaintext(key, plainPath); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected ErrMismatch for changed plaintext, got %v", err)
+	}
+
+	// Without a key there is nothing to check
+	if err := entry.VerifyPlaintext(nil, plainPath); err != nil {
+		t.Errorf("Expected no error without a key, got %v", err)
+	}
+}
+
+func TestKeyCheck(t *testing.T) {
+	m, err := New("test")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	key, err := m.Key("password")
+	if err != nil {
+		t.Fatalf("Key failed: %v", err)
+	}
+	m.SetKeyCheck(key)
+	if !m.HasKeyCheck() {
+		t.Fatal("Expected a key check after SetKeyCheck")
+	}
+
+	if _, err := m.Key("password"); err != nil {
+		t.Errorf("Expected the recorded password to be accepted, got %v", err)
+	}
+	if _, err := m.Key("other"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+
+	if err := m.NewSalt(); err != nil {
+		t.Fatalf("NewSalt failed: %v", err)
+	}
+	if m.HasKeyCheck() {
+		t.Error("Expected NewSalt to clear the key check")
+	}
+	if _, err := m.Key("other"); err != nil {
+		t.Errorf("Expected any password without a key check, got %v", err)
+	}
+}
+
+func TestAuthenticateCiphertext(t *testing.T) {
+	cipherPath := filepath.Join(t.TempDir(), "plain.txt.encrypted")
+	if err := os.WriteFile(cipherPath, []byte("ciphertext"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	entry := &Entry{CiphertextSHA256: SHA256([]byte("ciphertext")), CiphertextHMAC: HMAC(key, []byte("ciphertext"))}
+	if err := entry.AuthenticateCiphertext(key, cipherPath); err != nil {
+		t.Errorf("AuthenticateCiphertext failed: %v", err)
+	}
+
+	// Replacing the file and its checksum passes VerifyCiphertext but not
+	// the keyed fingerprint
+	if err := os.WriteFile(cipherPath, []byte("forged"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	entry.CiphertextSHA256 = SHA256([]byte("forged"))
+	if err := entry.VerifyCiphertext(cipherPath); err != nil {
+		t.Errorf("VerifyCiphertext failed: %v", err)
+	}
+	if err := entry.AuthenticateCiphertext(key, cipherPath); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected ErrMismatch for forged ciphertext, got %v", err)
+	}
+}
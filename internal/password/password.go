@@ -0,0 +1,136 @@
+// Package password resolves the encryption password from the sources that
+// can be given on the command line, so secrets need not appear in process
+// listings or shell history.
+package password
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// DefaultEnv is the environment variable consulted when no explicit source
+// is given
+const DefaultEnv = "SECUREFLOW_PASSWORD"
+
+// Source describes where to obtain a password. Resolve consults the fields
+// in this order and uses the first one that is set:
+//
+//  1. Value (--password)
+//  2. Env (--password-env NAME)
+//  3. File (--password-file PATH)
+//  4. Stdin (--password-stdin)
+//  5. Command (--password-command CMD)
+//  6. DefaultEnv (SECUREFLOW_PASSWORD)
+type Source struct {
+	Value      string
+	Env        string
+	File       string
+	Stdin      bool
+	Command    string
+	DefaultEnv string
+}
+
+// Resolve returns the password from the first configured source. found is
+// false when no source is configured, in which case the caller may prompt.
+// A configured source that yields an empty password is an error.
+func Resolve(src Source, stdin io.Reader) (pwd string, found bool, err error) {
+	var from string
+	switch {
+	case src.Value != "":
+		return src.Value, true, nil
+	case src.Env != "":
+		from = "environment variable " + src.Env
+		value, ok := os.LookupEnv(src.Env)
+		if !ok {
+			return "", false, fmt.Errorf("environment variable %s is not set", src.Env)
+		}
+		pwd = value
+	case src.File != "":
+		from = "password file " + src.File
+		data, err := os.ReadFile(src.File)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read password file: %w", err)
+		}
+		pwd = firstLine(string(data))
+	case src.Stdin:
+		from = "stdin"
+		pwd, err = readLine(stdin)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+	case src.Command != "":
+		from = "password command"
+		pwd, err = runCommand(src.Command)
+		if err != nil {
+			return "", false, err
+		}
+	case src.DefaultEnv != "" && os.Getenv(src.DefaultEnv) != "":
+		return os.Getenv(src.DefaultEnv), true, nil
+	default:
+		return "", false, nil
+	}
+
+	if pwd == "" {
+		return "", false, fmt.Errorf("password from %s is empty", from)
+	}
+	return pwd, true, nil
+}
+
+// readLine reads up to the first newline one byte at a time, so input
+// after the password is left for later prompts
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return firstLine(string(line)), nil
+}
+
+// runCommand runs command through the platform shell and returns its
+// first line of standard output. Standard error is passed through so the command can
+// prompt or report problems.
+func runCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("password command failed: %w", err)
+	}
+
+	return firstLine(stdout.String()), nil
+}
+
+// firstLine returns s up to its first line ending, keeping any other
+// whitespace that may be part of the password
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, "\r")
+}
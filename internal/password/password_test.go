@@ -0,0 +1,161 @@
+package password
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolvePrecedence(t *testing.T) {
+	t.Setenv("SF_TEST_PASSWORD", "from-env")
+	t.Setenv(DefaultEnv, "from-default")
+
+	tmpDir := t.TempDir()
+	passwordFile := filepath.Join(tmpDir, "password.txt")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to create password file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		src      Source
+		expected string
+	}{
+		{"Value", Source{Value: "from-flag", Env: "SF_TEST_PASSWORD", DefaultEnv: DefaultEnv}, "from-flag"},
+		{"Env", Source{Env: "SF_TEST_PASSWORD", File: passwordFile, DefaultEnv: DefaultEnv}, "from-env"},
+		{"File", Source{File: passwordFile, Stdin: true, DefaultEnv: DefaultEnv}, "from-file"},
+		{"Stdin", Source{Stdin: true, Command: "echo from-command", DefaultEnv: DefaultEnv}, "from-stdin"},
+		{"DefaultEnv", Source{DefaultEnv: DefaultEnv}, "from-default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pwd, found, err := Resolve(tt.src, strings.NewReader("from-stdin\nleftover\n"))
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if !found {
+				t.Fatal("Expected a password to be found")
+			}
+			if pwd != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, pwd)
+			}
+		})
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell command")
+	}
+
+	pwd, found, err := Resolve(Source{Command: "printf 'secret with spaces \\n'"}, nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if !found || pwd != "secret with spaces " {
+		t.Errorf("Expected %q, got %q (found: %v)", "secret with spaces ", pwd, found)
+	}
+
+	pwd, _, err = Resolve(Source{Command: "printf 'secret\\r\\nusername: me\\n'"}, nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if pwd != "secret" {
+		t.Errorf("Expected only the first line of the output, got %q", pwd)
+	}
+
+	if _, _, err := Resolve(Source{Command: "exit 3"}, nil); err == nil {
+		t.Error("Expected error for failing password command")
+	}
+}
+
+func TestResolveFileFirstLine(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"LF", "secret\nusername: me\n"},
+		{"CRLF", "secret\r\nusername: me\r\n"},
+		{"NoTrailingNewline", "secret\nusername: me"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passwordFile := filepath.Join(t.TempDir(), "password.txt")
+			if err := os.WriteFile(passwordFile, []byte(tt.data), 0600); err != nil {
+				t.Fatalf("Failed to create password file: %v", err)
+			}
+
+			pwd, _, err := Resolve(Source{File: passwordFile}, nil)
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if pwd != "secret" {
+				t.Errorf("Expected only the first line of the file, got %q", pwd)
+			}
+		})
+	}
+}
+
+func TestResolveNotFound(t *testing.T) {
+	t.Setenv(DefaultEnv, "")
+
+	_, found, err := Resolve(Source{DefaultEnv: DefaultEnv}, nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if found {
+		t.Error("Expected no password to be found")
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	emptyFile := filepath.Join(tmpDir, "empty.txt")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0600); err != nil {
+		t.Fatalf("Failed to create password file: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		src   Source
+		stdin io.Reader
+	}{
+		{"UnsetEnv", Source{Env: "SF_TEST_UNSET_VARIABLE"}, nil},
+		{"MissingFile", Source{File: filepath.Join(tmpDir, "missing.txt")}, nil},
+		{"EmptyFile", Source{File: emptyFile}, nil},
+		{"EmptyStdin", Source{Stdin: true}, strings.NewReader("")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Resolve(tt.src, tt.stdin); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestReadLineLeavesRemainingInput(t *testing.T) {
+	r := strings.NewReader("secret\r\nnext line\n")
+
+	pwd, err := readLine(r)
+	if err != nil {
+		t.Fatalf("readLine failed: %v", err)
+	}
+	if pwd != "secret" {
+		t.Errorf("Expected %q, got %q", "secret", pwd)
+	}
+
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read remaining input: %v", err)
+	}
+	if string(rest) != "next line\n" {
+		t.Errorf("Expected remaining input to be preserved, got %q", string(rest))
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// StageFile streams the content produced by write into a temporary file in
+// the same directory as path, syncs it to disk and gives it mode perm. The
+// temporary file is removed on failure; on success the caller passes its
+// name to ReplaceFile, or removes it.
+func StageFile(path string, perm os.FileMode, write func(w io.Writer) error) (string, error) {
+	// CreateTemp makes the file 0600, so content is never readable by
+	// others before perm is applied
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	bw := bufio.NewWriterSize(tmp, 64*1024)
+	if err := write(bw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	err = bw.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return tmp.Name(), nil
+}
+
+// ReplaceFile atomically renames a file staged by StageFile over path and
+// syncs the directory, so a crash leaves either the old or the new file
+func ReplaceFile(tmpPath, path string) error {
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// WriteAtomic replaces path with the content produced by write. Readers see
+// either the old file or the complete new one, never a partial write.
+func WriteAtomic(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmpPath, err := StageFile(path, perm, write)
+	if err != nil {
+		return err
+	}
+	return ReplaceFile(tmpPath, path)
+}
+
+// WriteFileAtomic is os.WriteFile through WriteAtomic
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteAtomic(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// syncDir flushes a directory entry change to disk. Not every platform can
+// sync a directory, so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
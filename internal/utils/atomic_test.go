@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "secret.txt")
+
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("new"), 0640); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(content) != "new" {
+		t.Errorf("Expected %q, got %q", "new", content)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %04o", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the written file, found %d entries", len(entries))
+	}
+}
+
+func TestWriteAtomicKeepsOriginalOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "secret.txt")
+
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	errWrite := errors.New("write failed")
+	err := WriteAtomic(path, 0600, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("Expected the write error, got %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(content) != "old" {
+		t.Errorf("Original file was modified: %q", content)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected the temporary file to be removed, found %d entries", len(entries))
+	}
+}
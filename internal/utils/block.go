@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+)
+
+// SetBlock returns data, the contents of a line-based file such as
+// .gitattributes, with the block of lines between "# BEGIN name" and
+// "# END name" replaced by lines. A missing block is appended; with no lines
+// the block is removed. Everything outside the block is left as it was.
+func SetBlock(data []byte, name string, lines []string) []byte {
+	begin, end := "# BEGIN "+name, "# END "+name
+
+	var block []byte
+	if len(lines) > 0 {
+		block = []byte(begin + "\n" + strings.Join(lines, "\n") + "\n" + end + "\n")
+	}
+
+	start, stop := -1, -1
+	for pos := 0; pos < len(data); {
+		next := len(data)
+		if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+			next = pos + i + 1
+		}
+		line := strings.TrimSpace(string(data[pos:next]))
+		if line == begin && start < 0 {
+			start = pos
+		} else if line == end && start >= 0 {
+			stop = next
+			break
+		}
+		pos = next
+	}
+
+	if start < 0 || stop < 0 {
+		if block == nil {
+			return data
+		}
+		out := append([]byte(nil), data...)
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		return append(out, block...)
+	}
+
+	out := append([]byte(nil), data[:start]...)
+	out = append(out, block...)
+	return append(out, data[stop:]...)
+}
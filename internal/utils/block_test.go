@@ -0,0 +1,58 @@
+package utils
+
+import "testing"
+
+func TestSetBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		lines    []string
+		expected string
+	}{
+		{
+			name:     "Empty",
+			data:     "",
+			lines:    []string{"a", "b"},
+			expected: "# BEGIN test\na\nb\n# END test\n",
+		},
+		{
+			name:     "AppendWithoutTrailingNewline",
+			data:     "*.png binary",
+			lines:    []string{"a"},
+			expected: "*.png binary\n# BEGIN test\na\n# END test\n",
+		},
+		{
+			name:     "Replace",
+			data:     "before\n# BEGIN test\nold\n# END test\nafter\n",
+			lines:    []string{"new"},
+			expected: "before\n# BEGIN test\nnew\n# END test\nafter\n",
+		},
+		{
+			name:     "Remove",
+			data:     "before\n# BEGIN test\nold\n# END test\nafter\n",
+			lines:    nil,
+			expected: "before\nafter\n",
+		},
+		{
+			name:     "RemoveMissing",
+			data:     "before\n",
+			lines:    nil,
+			expected: "before\n",
+		},
+		{
+			name:     "OtherBlock",
+			data:     "# BEGIN other\nx\n# END other\n",
+			lines:    []string{"a"},
+			expected: "# BEGIN other\nx\n# END other\n# BEGIN test\na\n# END test\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(SetBlock([]byte(tt.data), "test", tt.lines))
+			if got != tt.expected {
+				t.Errorf("SetBlock() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"syscall"
@@ -53,6 +55,7 @@ type FileInfo struct {
 	Size         int64
 	Lines        int
 	LastModified time.Time
+	Mode         os.FileMode // permission bits
 }
 
 // GetFileInfo retrieves metadata about a file
@@ -62,7 +65,7 @@ func GetFileInfo(path string) (*FileInfo, error) {
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	// Count lines
+	// Count lines without loading the file or limiting line length
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -70,13 +73,24 @@ func GetFileInfo(path string) (*FileInfo, error) {
 	defer file.Close()
 
 	lines := 0
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines++
+	last := byte('\n')
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+	if last != '\n' {
+		// Final line without a trailing newline
+		lines++
 	}
 
 	return &FileInfo{
@@ -84,6 +98,7 @@ func GetFileInfo(path string) (*FileInfo, error) {
 		Size:         stat.Size(),
 		Lines:        lines,
 		LastModified: stat.ModTime(),
+		Mode:         stat.Mode().Perm(),
 	}, nil
 }
 
@@ -101,18 +116,24 @@ func FileExists(path string) bool {
 	return err == nil
 }
 
-// CopyFile copies a file from src to dst
+// CopyFile atomically copies a file from src to dst with the same
+// permissions
 func CopyFile(src, dst string) error {
-	// Read source file
-	data, err := os.ReadFile(src)
+	in, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to read source file: %w", err)
 	}
+	defer in.Close()
 
-	// Write to destination file
-	if err := os.WriteFile(dst, data, 0644); err != nil {
-		return fmt.Errorf("failed to write destination file: %w", err)
+	stat, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
 	}
 
-	return nil
+	return WriteAtomic(dst, stat.Mode().Perm(), func(w io.Writer) error {
+		if _, err := io.Copy(w, in); err != nil {
+			return fmt.Errorf("failed to write destination file: %w", err)
+		}
+		return nil
+	})
 }
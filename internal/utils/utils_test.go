@@ -252,6 +252,31 @@ func TestCopyFile(t *testing.T) {
 	}
 }
 
+func TestCopyFileKeepsMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "source.txt")
+	dstPath := filepath.Join(tmpDir, "dest.txt")
+
+	if err := os.WriteFile(srcPath, []byte("secret"), 0600); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := os.WriteFile(dstPath, []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to create destination file: %v", err)
+	}
+
+	if err := CopyFile(srcPath, dstPath); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+
+	info, err := os.Stat(dstPath)
+	if err != nil {
+		t.Fatalf("Failed to stat destination file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %04o", info.Mode().Perm())
+	}
+}
+
 func TestCopyFileNonExistent(t *testing.T) {
 	tmpDir := t.TempDir()
 	srcPath := filepath.Join(tmpDir, "nonexistent.txt")
@@ -262,3 +287,24 @@ func TestCopyFileNonExistent(t *testing.T) {
 		t.Error("Expected error when copying non-existent file")
 	}
 }
+
+func TestGetFileInfoLongLines(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Lines longer than bufio.Scanner's default token limit
+	testFile := filepath.Join(tmpDir, "long.txt")
+	line := strings.Repeat("a", 200*1024)
+	content := line + "\n" + line
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	info, err := GetFileInfo(testFile)
+	if err != nil {
+		t.Fatalf("GetFileInfo failed: %v", err)
+	}
+
+	if info.Lines != 2 {
+		t.Errorf("Expected 2 lines, got %d", info.Lines)
+	}
+}
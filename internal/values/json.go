@@ -0,0 +1,101 @@
+package values
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// jsonFrame tracks the object or array being read
+type jsonFrame struct {
+	path   string
+	object bool
+	key    string // the key whose value comes next, in an object
+	index  int    // the index of the next element, in an array
+	hasKey bool
+}
+
+// findJSON walks the tokens of a JSON document. The decoder reports where
+// each token ends; it starts after the whitespace, colon or comma that
+// follows the previous one.
+func findJSON(data []byte) ([]Value, error) {
+	if i := skipJSONSeparators(data, 0); i == len(data) || data[i] != '{' {
+		return nil, fmt.Errorf("a JSON document must be an object to encrypt its values")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var found []Value
+	var stack []*jsonFrame
+	prev := 0
+	for read := false; ; read = true {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		if read && len(stack) == 0 {
+			return nil, fmt.Errorf("invalid JSON: unexpected data after the top-level object")
+		}
+		start := skipJSONSeparators(data, prev)
+		end := int(dec.InputOffset())
+		prev = end
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if top != nil && top.object && !top.hasKey {
+			if tok == json.Delim('}') {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			top.key, top.hasKey = tok.(string), true
+			continue
+		}
+
+		// tok is a value, or closes an array
+		path := ""
+		if top != nil {
+			if top.object {
+				path = joinKey(top.path, top.key)
+				top.hasKey = false
+			} else if tok != json.Delim(']') {
+				path = joinIndex(top.path, top.index)
+				top.index++
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &jsonFrame{path: path, object: true})
+		case json.Delim('['):
+			stack = append(stack, &jsonFrame{path: path})
+		case json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		default:
+			found = append(found, Value{Path: path, Start: start, End: end})
+		}
+	}
+	return found, nil
+}
+
+// skipJSONSeparators returns the offset of the first byte at or after pos
+// that is not whitespace, a colon or a comma
+func skipJSONSeparators(data []byte, pos int) int {
+	for pos < len(data) {
+		switch data[pos] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			pos++
+		default:
+			return pos
+		}
+	}
+	return pos
+}
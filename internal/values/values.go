@@ -0,0 +1,106 @@
+// Package values finds the values in dotenv, YAML and JSON documents, so
+// they can be encrypted one by one while the keys and layout stay readable
+package values
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/MayR-Labs/secureflow-go/internal/dotenv"
+)
+
+// Syntax identifies the kind of document
+type Syntax string
+
+const (
+	Dotenv Syntax = "dotenv"
+	YAML   Syntax = "yaml"
+	JSON   Syntax = "json"
+)
+
+// Value is where one value appears in a document
+type Value struct {
+	// Path names the value by its keys, such as db.hosts[0]
+	Path string
+	// Start and End are the byte offsets of its raw text, quotes included
+	Start, End int
+}
+
+// DetectSyntax picks the syntax of the file at path from its name
+func DetectSyntax(path string) (Syntax, error) {
+	if dotenv.IsDotenv(path) {
+		return Dotenv, nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".json":
+		return JSON, nil
+	default:
+		return "", fmt.Errorf("cannot tell the syntax of %s: per-value encryption supports .env, .yaml, .yml and .json files", path)
+	}
+}
+
+// ParseSyntax checks a syntax name read from an encrypted document
+func ParseSyntax(name string) (Syntax, error) {
+	switch s := Syntax(name); s {
+	case Dotenv, YAML, JSON:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown document syntax %q", name)
+	}
+}
+
+// Find returns the values of data in document order. Keys, comments and
+// layout are left out, and so are YAML aliases and empty YAML values.
+func Find(data []byte, syntax Syntax) ([]Value, error) {
+	switch syntax {
+	case Dotenv:
+		spans, err := dotenv.Locate(data)
+		if err != nil {
+			return nil, err
+		}
+		found := make([]Value, len(spans))
+		for i, s := range spans {
+			found[i] = Value{Path: s.Key, Start: s.Start, End: s.End}
+		}
+		return found, nil
+	case YAML:
+		return findYAML(data)
+	case JSON:
+		return findJSON(data)
+	default:
+		return nil, fmt.Errorf("unknown document syntax %q", syntax)
+	}
+}
+
+// Replace returns data with the text of each value replaced by repl's
+// result for it. found must be in document order, as Find returns it.
+func Replace(data []byte, found []Value, repl func(v Value, raw []byte) ([]byte, error)) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	last := 0
+	for _, v := range found {
+		text, err := repl(v, data[v.Start:v.End])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data[last:v.Start]...)
+		out = append(out, text...)
+		last = v.End
+	}
+	return append(out, data[last:]...), nil
+}
+
+// joinKey extends a value path with a mapping key
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// joinIndex extends a value path with a sequence index
+func joinIndex(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
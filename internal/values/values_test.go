@@ -0,0 +1,116 @@
+package values
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		syntax   Syntax
+		input    string
+		expected []string // path=raw text
+	}{
+		{
+			name:     "Dotenv",
+			syntax:   Dotenv,
+			input:    "# comment\nA=1\nB=\"two\nlines\"\n",
+			expected: []string{"A=1", "B=\"two\nlines\""},
+		},
+		{
+			name:   "YAMLScalars",
+			syntax: YAML,
+			input: "# top\nplain: value # comment\ndouble: \"a \\\" b\"\nsingle: 'it''s'\n" +
+				"empty:\nunicode: ключ\n",
+			expected: []string{"plain=value", "double=\"a \\\" b\"", "single='it''s'", "unicode=ключ"},
+		},
+		{
+			name:     "YAMLNested",
+			syntax:   YAML,
+			input:    "db:\n  hosts:\n    - a\n    - &b b\n    - *b\n  flow: {x: 1, y: [2, \"3\"]}\n",
+			expected: []string{"db.hosts[0]=a", "db.hosts[1]=b", "db.flow.x=1", "db.flow.y[0]=2", "db.flow.y[1]=\"3\""},
+		},
+		{
+			name:     "YAMLTagged",
+			syntax:   YAML,
+			input:    "port: !!str 8080\n",
+			expected: []string{"port=8080"},
+		},
+		{
+			name:     "YAMLBlock",
+			syntax:   YAML,
+			input:    "key: |\n  line1\n\n  line2\nnext: >-\n    folded\n    text\nlast: 1\n",
+			expected: []string{"key=|\n  line1\n\n  line2", "next=>-\n    folded\n    text", "last=1"},
+		},
+		{
+			name:     "YAMLDocuments",
+			syntax:   YAML,
+			input:    "a: 1\n---\nb: 2\n",
+			expected: []string{"a=1", "b=2"},
+		},
+		{
+			name:     "JSON",
+			syntax:   JSON,
+			input:    "{\n  \"a\": 1,\n  \"b\": {\"c\": [true, null, \"s\\\"\"], \"d\": {}},\n  \"e\": -1.5e3\n}\n",
+			expected: []string{"a=1", "b.c[0]=true", "b.c[1]=null", "b.c[2]=\"s\\\"\"", "e=-1.5e3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := Find([]byte(tt.input), tt.syntax)
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			var got []string
+			for _, v := range found {
+				got = append(got, v.Path+"="+tt.input[v.Start:v.End])
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Find() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		syntax  Syntax
+		input   string
+		problem string
+	}{
+		{"MultilinePlainYAML", YAML, "key: first\n  second\n", "quote it"},
+		{"InvalidYAML", YAML, "key: [1, 2\n", "invalid YAML"},
+		{"JSONArray", JSON, "[1, 2]\n", "must be an object"},
+		{"TrailingJSON", JSON, "{} {}\n", "after the top-level object"},
+		{"InvalidDotenv", Dotenv, "NOT_AN_ASSIGNMENT\n", "expected KEY=VALUE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Find([]byte(tt.input), tt.syntax)
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("Find() error = %v, expected one containing %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestDetectSyntax(t *testing.T) {
+	tests := map[string]Syntax{
+		".env.prod":        Dotenv,
+		"config/app.yaml":  YAML,
+		"config/app.YML":   YAML,
+		"service-key.json": JSON,
+		"keystore.jks":     "",
+	}
+
+	for path, expected := range tests {
+		got, err := DetectSyntax(path)
+		if got != expected || (err != nil) != (expected == "") {
+			t.Errorf("DetectSyntax(%q) = %q, %v, expected %q", path, got, err, expected)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package values
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlFinder collects the scalar values of a YAML stream. The parser gives
+// where each scalar starts; where it ends depends on its style.
+type yamlFinder struct {
+	data  []byte
+	lines []int // offset of the start of each line
+	found []Value
+}
+
+func findYAML(data []byte) ([]Value, error) {
+	f := &yamlFinder{data: data, lines: []int{0}}
+	for i, b := range data {
+		if b == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if err := f.walk(&doc, nil, "", 0); err != nil {
+			return nil, err
+		}
+	}
+	return f.found, nil
+}
+
+// walk visits n, whose parent collection is indented by indent columns
+func (f *yamlFinder) walk(n, parent *yaml.Node, path string, indent int) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if err := f.walk(c, n, path, 0); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if err := f.walk(value, n, joinKey(path, key.Value), key.Column-1); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			if err := f.walk(c, n, joinIndex(path, i), n.Column-1); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" && n.Value == "" {
+			// Nothing is written, so there is nothing to encrypt
+			return nil
+		}
+		v, err := f.scalar(n, parent, indent)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", n.Line, path, err)
+		}
+		v.Path = path
+		f.found = append(f.found, v)
+	}
+	// Aliases refer to a value found at its anchor
+	return nil
+}
+
+// scalar finds the raw text of a scalar, after any anchor or tag
+func (f *yamlFinder) scalar(n, parent *yaml.Node, indent int) (Value, error) {
+	start := f.offset(n.Line, n.Column)
+	for start < len(f.data) && (f.data[start] == '&' || f.data[start] == '!') {
+		for start < len(f.data) && !isSpace(f.data[start]) {
+			start++
+		}
+		for start < len(f.data) && (f.data[start] == ' ' || f.data[start] == '\t') {
+			start++
+		}
+	}
+	if start >= len(f.data) || f.data[start] == '\n' || f.data[start] == '\r' {
+		return Value{}, fmt.Errorf("put the value on the same line as its anchor or tag")
+	}
+
+	end := -1
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		end = f.doubleQuoted(start)
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		end = f.singleQuoted(start)
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		end = f.block(start, indent)
+	default:
+		end = f.plain(start, parent.Style&yaml.FlowStyle != 0)
+		if end >= 0 && string(f.data[start:end]) != n.Value {
+			return Value{}, fmt.Errorf("a plain value that continues on the next line cannot be encrypted; quote it")
+		}
+	}
+	if end < 0 {
+		return Value{}, fmt.Errorf("cannot find the end of the value")
+	}
+	return Value{Start: start, End: end}, nil
+}
+
+// offset converts a 1-based line and column, counted in characters, to a
+// byte offset
+func (f *yamlFinder) offset(line, column int) int {
+	if line < 1 || line > len(f.lines) {
+		return len(f.data)
+	}
+	pos := f.lines[line-1]
+	for i := 1; i < column && pos < len(f.data); i++ {
+		_, size := utf8.DecodeRune(f.data[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// doubleQuoted returns the offset after the quote closing the string at
+// start, skipping escaped characters
+func (f *yamlFinder) doubleQuoted(start int) int {
+	if f.data[start] != '"' {
+		return -1
+	}
+	for i := start + 1; i < len(f.data); i++ {
+		switch f.data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// singleQuoted returns the offset after the quote closing the string at
+// start; a doubled quote is an escaped one
+func (f *yamlFinder) singleQuoted(start int) int {
+	if f.data[start] != '\'' {
+		return -1
+	}
+	for i := start + 1; i < len(f.data); i++ {
+		if f.data[i] != '\'' {
+			continue
+		}
+		if i+1 < len(f.data) && f.data[i+1] == '\'' {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return -1
+}
+
+// plain returns the end of the plain scalar at start: the end of the line,
+// a comment or, inside a flow collection, the next indicator
+func (f *yamlFinder) plain(start int, flow bool) int {
+	end := start
+	for end < len(f.data) && f.data[end] != '\n' && f.data[end] != '\r' {
+		c := f.data[end]
+		if c == '#' && end > start && isSpace(f.data[end-1]) {
+			break
+		}
+		if flow && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		end++
+	}
+	for end > start && isSpace(f.data[end-1]) {
+		end--
+	}
+	return end
+}
+
+// block returns the end of the literal or folded scalar whose header is at
+// start: the end of its last line indented deeper than its parent
+func (f *yamlFinder) block(start, indent int) int {
+	if f.data[start] != '|' && f.data[start] != '>' {
+		return -1
+	}
+	end := f.lineEnd(start)
+
+	// An indentation indicator fixes the content's indentation; otherwise
+	// the first non-blank line sets it
+	contentIndent := 0
+	header := string(f.data[start+1 : end])
+	if i := strings.IndexAny(header, "123456789"); i >= 0 && i < 2 {
+		contentIndent = indent + int(header[i]-'0')
+	}
+
+	for pos := f.nextLine(end); pos < len(f.data); pos = f.nextLine(pos) {
+		lineEnd := f.lineEnd(pos)
+		line := f.data[pos:lineEnd]
+		spaces := len(line) - len(bytes.TrimLeft(line, " "))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if contentIndent == 0 {
+			if spaces <= indent {
+				break
+			}
+			contentIndent = spaces
+		}
+		if spaces < contentIndent {
+			break
+		}
+		end = lineEnd
+	}
+	return end
+}
+
+// lineEnd returns the offset of the line break after pos, before any \r
+func (f *yamlFinder) lineEnd(pos int) int {
+	end := len(f.data)
+	if i := bytes.IndexByte(f.data[pos:], '\n'); i >= 0 {
+		end = pos + i
+	}
+	if end > pos && f.data[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// nextLine returns the offset of the line after the one holding pos
+func (f *yamlFinder) nextLine(pos int) int {
+	if i := bytes.IndexByte(f.data[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(f.data)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
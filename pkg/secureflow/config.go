@@ -0,0 +1,110 @@
+package secureflow
+
+import (
+	"fmt"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/values"
+)
+
+// Configuration types, as read from secureflow.yaml
+type (
+	// Config is a loaded secureflow.yaml
+	Config = config.Config
+	// FileMapping is one entry of the files list
+	FileMapping = config.FileMapping
+	// Selector picks a subset of the configured files, as --only and
+	// --except do
+	Selector = config.Selector
+	// ValidationError lists the problems found in a config file
+	ValidationError = config.ValidationError
+	// Problem is one mistake found in a config file
+	Problem = config.Problem
+)
+
+// LoadConfig reads and validates the config file at path, selects the
+// environment env (the top level when empty) and expands its glob and
+// directory entries. Paths in the result are relative to the working
+// directory, as they are for the command.
+func LoadConfig(path, env string) (*Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if cfg, err = cfg.ForEnvironment(env); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.ExpandFiles(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// OptionsFromConfig builds the encryption options that cfg's format, kdf
+// and recipients settings describe. Identities and a PBKDF2 iteration
+// count are not part of the config; set them on the result.
+func OptionsFromConfig(cfg *Config) (Options, error) {
+	format, err := crypto.ParseFormat(cfg.Format)
+	if err != nil {
+		return Options{}, fmt.Errorf("invalid config: %w", err)
+	}
+	if cfg.Format == "" && len(cfg.Recipients) > 0 {
+		// Recipients are only supported by the aead format
+		format = crypto.FormatAEAD
+	}
+
+	opts := Options{Format: format}
+
+	if cfg.KDF != nil {
+		kdf, err := crypto.ParseKDF(cfg.KDF.Algorithm)
+		if err != nil {
+			return Options{}, fmt.Errorf("invalid config: %w", err)
+		}
+		opts.KDF = crypto.KDFParams{
+			Algorithm:   kdf,
+			Iterations:  cfg.KDF.Iterations,
+			ScryptN:     cfg.KDF.N,
+			ScryptR:     cfg.KDF.R,
+			ScryptP:     cfg.KDF.P,
+			Time:        cfg.KDF.Time,
+			Memory:      cfg.KDF.Memory,
+			Parallelism: cfg.KDF.Parallelism,
+		}
+	}
+
+	for _, r := range cfg.Recipients {
+		recipient, err := crypto.ParseRecipient(r)
+		if err != nil {
+			return Options{}, fmt.Errorf("invalid config: %w", err)
+		}
+		opts.Recipients = append(opts.Recipients, recipient)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return Options{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return opts, nil
+}
+
+// FileOptions adapts opts to one file: with encryption: values it selects
+// per-value encryption in the file's syntax. previous is the file's current
+// encrypted form, or nil; unchanged values keep their ciphertext from it.
+func FileOptions(opts Options, f FileMapping, previous []byte) (Options, error) {
+	if f.Encryption != config.EncryptValues {
+		return opts, nil
+	}
+	syntax, err := values.DetectSyntax(f.Input)
+	if err != nil {
+		return Options{}, err
+	}
+	if opts.Format != crypto.FormatAEAD {
+		return Options{}, fmt.Errorf("encryption: values for %s requires format: aead", f.Input)
+	}
+	opts.Values = syntax
+	opts.Previous = previous
+	return opts, nil
+}
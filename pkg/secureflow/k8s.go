@@ -0,0 +1,232 @@
+package secureflow
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/dotenv"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// K8sSecret is a file's k8s block in secureflow.yaml
+type K8sSecret = config.K8sSecret
+
+// Secret is a Kubernetes Secret built from decrypted files
+type Secret struct {
+	Name      string
+	Namespace string // empty to leave it to kubectl
+	Type      string
+	Data      map[string][]byte
+}
+
+// SecretOptions names the Secret for files whose k8s block does not
+type SecretOptions struct {
+	Name      string
+	Namespace string
+}
+
+// Secrets decrypts every encrypted file in memory and collects it into the
+// Secret its k8s block names, as one data key for the whole file or one per
+// variable of a .env file. Secrets are returned in the order the config
+// first names them. It stops at the first file that fails or is missing
+// without being optional, since the Secrets would be incomplete, and fails
+// when two files set the same key of a Secret.
+func (p *Project) Secrets(o SecretOptions) ([]*Secret, error) {
+	if o.Name != "" && !config.ValidSecretName(o.Name) {
+		return nil, fmt.Errorf("%q is not a valid Secret name (lowercase letters, digits, '-' and '.')", o.Name)
+	}
+	if o.Namespace != "" && !config.ValidNamespace(o.Namespace) {
+		return nil, fmt.Errorf("%q is not a valid namespace (lowercase letters, digits and '-')", o.Namespace)
+	}
+
+	m, key, err := p.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := p.runOptions()
+	var secrets []*Secret
+	byName := make(map[string]*Secret)
+	setBy := make(map[*Secret]map[string]string) // data key -> input that set it
+	done := 0
+	for _, fileMapping := range p.Config.Files {
+		encryptedPath := filepath.Join(p.Config.OutputDir, fileMapping.Output)
+		p.emit(Event{Kind: FileStarted, File: fileMapping, Path: encryptedPath})
+
+		// Check if encrypted file exists
+		if !utils.FileExists(encryptedPath) {
+			if err := p.missing(fileMapping, encryptedPath, p.emit); err != errSkipped {
+				return nil, &FileError{File: fileMapping, Path: encryptedPath, Err: err}
+			}
+			continue
+		}
+
+		k := K8sSecret{}
+		if fileMapping.K8s != nil {
+			k = *fileMapping.K8s
+		}
+		if k.Name == "" {
+			k.Name = o.Name
+		}
+		if k.Namespace == "" {
+			k.Namespace = o.Namespace
+		}
+		if k.Name == "" {
+			return nil, fmt.Errorf("no Secret name for %s: set k8s.name in the config or give a default name (--name)", fileMapping.Input)
+		}
+
+		plaintext, err := decryptInMemory(fileMapping, encryptedPath, p.Password, opts, m, key)
+		if err != nil {
+			p.emit(Event{Kind: FileFailed, File: fileMapping, Path: encryptedPath, Err: err})
+			return nil, &FileError{File: fileMapping, Path: encryptedPath, Err: err}
+		}
+		data, err := secretData(fileMapping, k, plaintext)
+		if err != nil {
+			p.emit(Event{Kind: FileFailed, File: fileMapping, Path: encryptedPath, Err: err})
+			return nil, &FileError{File: fileMapping, Path: encryptedPath, Err: err}
+		}
+
+		id := k.Name
+		if k.Namespace != "" {
+			id = k.Namespace + "/" + k.Name
+		}
+		secret := byName[id]
+		if secret == nil {
+			secret = &Secret{Name: k.Name, Namespace: k.Namespace, Data: make(map[string][]byte)}
+			byName[id] = secret
+			setBy[secret] = make(map[string]string)
+			secrets = append(secrets, secret)
+		}
+		if k.Type != "" {
+			if secret.Type != "" && secret.Type != k.Type {
+				return nil, fmt.Errorf("Secret %s is given type %s by %s and %s by another file", id, k.Type, fileMapping.Input, secret.Type)
+			}
+			secret.Type = k.Type
+		}
+		for _, d := range data {
+			if other, ok := setBy[secret][d.Key]; ok {
+				return nil, fmt.Errorf("key %s of Secret %s is set by both %s and %s", d.Key, id, other, fileMapping.Input)
+			}
+			setBy[secret][d.Key] = fileMapping.Input
+			secret.Data[d.Key] = []byte(d.Value)
+		}
+
+		p.emit(Event{Kind: FileDone, File: fileMapping, Path: encryptedPath, Dest: "secret/" + id})
+		done++
+	}
+
+	if done == 0 {
+		return nil, fmt.Errorf("no files were decrypted")
+	}
+	for _, secret := range secrets {
+		if secret.Type == "" {
+			secret.Type = "Opaque"
+		}
+	}
+	return secrets, nil
+}
+
+// secretData splits a decrypted file into the Secret data its k8s block asks
+// for
+func secretData(fileMapping FileMapping, k K8sSecret, plaintext []byte) ([]dotenv.Entry, error) {
+	from := k.From
+	if from == "" {
+		from = config.K8sFromFile
+		if dotenv.IsDotenv(fileMapping.Input) {
+			from = config.K8sFromDotenv
+		}
+	}
+
+	if from == config.K8sFromDotenv {
+		entries, err := dotenv.Parse(plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", fileMapping.Input, err)
+		}
+		for _, e := range entries {
+			if !config.ValidSecretKey(e.Key) {
+				return nil, fmt.Errorf("%s cannot be a Secret key", e.Key)
+			}
+		}
+		return entries, nil
+	}
+
+	key := k.Key
+	if key == "" {
+		key = filepath.Base(fileMapping.Input)
+	}
+	if !config.ValidSecretKey(key) {
+		return nil, fmt.Errorf("%q cannot be a Secret key; set k8s.key", key)
+	}
+	return []dotenv.Entry{{Key: key, Value: string(plaintext)}}, nil
+}
+
+// decryptInMemory decrypts an encrypted file without writing the plaintext
+// to disk, checking it against the manifest like decryptWithManifest
+func decryptInMemory(fileMapping FileMapping, encryptedPath, pwd string, opts Options, m *manifest.Manifest, key []byte) ([]byte, error) {
+	entry := m.Entry(fileMapping.Output)
+	if entry != nil {
+		if err := entry.VerifyCiphertext(encryptedPath); err != nil {
+			return nil, err
+		}
+	}
+
+	ciphertext, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+	plaintext, err := DecryptBytes(ciphertext, pwd, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && key != nil && entry.PlaintextHMAC != "" {
+		if err := entry.CheckPlaintext(manifest.HMAC(key, plaintext)); err != nil {
+			return nil, err
+		}
+	}
+	return plaintext, nil
+}
+
+// secretManifest is the YAML form of a Secret
+type secretManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   secretMetadata    `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+// secretMetadata is the metadata of a secretManifest
+type secretMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// WriteSecrets writes the Secrets as a YAML stream for kubectl apply -f -,
+// with their data keys sorted and values base64-encoded
+func WriteSecrets(w io.Writer, secrets []*Secret) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, s := range secrets {
+		doc := secretManifest{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   secretMetadata{Name: s.Name, Namespace: s.Namespace},
+			Type:       s.Type,
+			Data:       make(map[string]string, len(s.Data)),
+		}
+		for k, v := range s.Data {
+			doc.Data[k] = base64.StdEncoding.EncodeToString(v)
+		}
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("failed to write Secret %s: %w", s.Name, err)
+		}
+	}
+	return enc.Close()
+}
@@ -0,0 +1,91 @@
+package secureflow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProjectSecrets(t *testing.T) {
+	p := newTestProject(t, "")
+	if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	p.Config.Files[1].K8s = &K8sSecret{Name: "app-config", Key: "config.yaml"}
+
+	secrets, err := p.Secrets(SecretOptions{Name: "app-env", Namespace: "prod"})
+	if err != nil {
+		t.Fatalf("Secrets failed: %v", err)
+	}
+	if len(secrets) != 2 {
+		t.Fatalf("Secrets returned %d Secret(s), want 2", len(secrets))
+	}
+
+	env, cfg := secrets[0], secrets[1]
+	if env.Name != "app-env" || env.Namespace != "prod" || env.Type != "Opaque" {
+		t.Errorf("first Secret = %s/%s of type %s, want prod/app-env of type Opaque", env.Namespace, env.Name, env.Type)
+	}
+	if string(env.Data["API_KEY"]) != "secret" || len(env.Data) != 1 {
+		t.Errorf("first Secret data = %q, want API_KEY from .env", env.Data)
+	}
+	if cfg.Name != "app-config" || string(cfg.Data["config.yaml"]) != "debug: false\n" {
+		t.Errorf("second Secret = %s with %q, want app-config with config.yaml", cfg.Name, cfg.Data)
+	}
+
+	var out bytes.Buffer
+	if err := WriteSecrets(&out, secrets); err != nil {
+		t.Fatalf("WriteSecrets failed: %v", err)
+	}
+	want := `apiVersion: v1
+kind: Secret
+metadata:
+  name: app-env
+  namespace: prod
+type: Opaque
+data:
+  API_KEY: c2VjcmV0
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-config
+  namespace: prod
+type: Opaque
+data:
+  config.yaml: ZGVidWc6IGZhbHNlCg==
+`
+	if out.String() != want {
+		t.Errorf("WriteSecrets wrote:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestProjectSecretsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		k8s     *K8sSecret // for config/app.yaml
+		opts    SecretOptions
+		problem string
+	}{
+		{"no name", nil, SecretOptions{}, "no Secret name for .env"},
+		{"invalid name", nil, SecretOptions{Name: "App"}, `"App" is not a valid Secret name`},
+		{"duplicate key", &K8sSecret{Key: "API_KEY"}, SecretOptions{Name: "app"}, "key API_KEY of Secret app is set by both .env and config/app.yaml"},
+		{"conflicting types", &K8sSecret{Name: "app", Type: "kubernetes.io/tls"}, SecretOptions{Name: "app"}, "Secret app is given type kubernetes.io/tls by config/app.yaml and Opaque by another file"},
+		{"dotenv parse", &K8sSecret{From: "dotenv"}, SecretOptions{Name: "app"}, "failed to parse config/app.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProject(t, "")
+			if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			p.Config.Files[0].K8s = &K8sSecret{Type: "Opaque"}
+			p.Config.Files[1].K8s = tt.k8s
+
+			_, err := p.Secrets(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("Secrets error = %v, want one containing %q", err, tt.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,593 @@
+package secureflow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/MayR-Labs/secureflow-go/internal/config"
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+)
+
+// Project runs the encrypt, decrypt and test flows over the files listed in
+// a config, the way the secureflow command does
+type Project struct {
+	// Config lists the files. Narrow Config.Files with Config.Select to
+	// act on some of them.
+	Config *Config
+
+	// Options controls the container format and keys
+	Options Options
+
+	// Password encrypts and decrypts the files. It is not needed when
+	// Options has recipients to encrypt to or identities to decrypt with.
+	Password string
+
+	// ToolVersion is recorded in manifest.json
+	ToolVersion string
+
+	// Progress, when set, is called as each file is processed. It is
+	// always called from the goroutine running the flow, with the events
+	// of each file together and in the order of Config.Files.
+	Progress func(Event)
+
+	// Jobs is how many files are encrypted or decrypted at once. Zero
+	// means one per CPU.
+	Jobs int
+
+	// AllowMissing treats every file as optional, so a missing file is
+	// skipped rather than failing the run
+	AllowMissing bool
+
+	// FailFast stops at the first file that fails instead of processing
+	// the rest. Files already being processed are finished.
+	FailFast bool
+}
+
+// EventKind says what happened in an Event
+type EventKind int
+
+const (
+	// ManifestLoaded means files will be checked against the manifest at
+	// Path
+	ManifestLoaded EventKind = iota
+	// FileStarted means File is about to be read from Path
+	FileStarted
+	// FileSkipped means Path does not exist and the file is optional
+	FileSkipped
+	// FileDone means Path was encrypted or decrypted to Dest
+	FileDone
+	// FileCopied means Path was copied to File.CopyTo, or Err says why
+	// it was not, which fails the file
+	FileCopied
+	// FileFailed means File was not processed; Err says why, and is
+	// ErrMissing when Path does not exist
+	FileFailed
+)
+
+// Event reports progress on one file to Project.Progress
+type Event struct {
+	Kind EventKind
+	File FileMapping
+	Path string // the file being read
+	Dest string // the file written, for FileDone
+	Err  error
+}
+
+// Result summarises a Project run
+type Result struct {
+	Done    int          // files encrypted or decrypted
+	Skipped int          // optional files that did not exist
+	Failed  []*FileError // files that failed, in the order of Config.Files
+	Stopped int          // files not processed because FailFast stopped the run
+	Report  string       // report written by Encrypt, if any
+}
+
+// ErrMissing is the error of a file that does not exist and is not optional
+var ErrMissing = errors.New("file not found")
+
+// FileError says why one file failed. Encrypt, Decrypt and Test return one
+// for each file that failed, joined with errors.Join.
+type FileError struct {
+	File FileMapping
+	Path string // the file being read
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// NewProject loads the config file at path with LoadConfig and builds the
+// options it describes
+func NewProject(path, env string) (*Project, error) {
+	cfg, err := LoadConfig(path, env)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := OptionsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{Config: cfg, Options: opts}, nil
+}
+
+// emit reports e to Progress
+func (p *Project) emit(e Event) {
+	if p.Progress != nil {
+		p.Progress(e)
+	}
+}
+
+// EncryptOptions controls what Encrypt records next to the encrypted files
+type EncryptOptions struct {
+	// Report selects the report to write; the zero value is ReportJSON
+	Report ReportFormat
+
+	// Note and PasswordHint are recorded in the report
+	Note         string
+	PasswordHint string
+
+	// Merge keeps the entries of an existing manifest.json for files that
+	// are not being encrypted, for when Config.Files has been narrowed
+	Merge bool
+}
+
+// defaultNote is the note recorded when none is given
+const defaultNote = "Encrypted secrets for CI/CD"
+
+// Encrypt encrypts every input file into the output directory and writes
+// the report. A file that fails or is missing without being optional does
+// not stop the others unless FailFast is set; the report still lists the
+// files that were encrypted, and the error returned joins a *FileError for
+// each failure.
+func (p *Project) Encrypt(o EncryptOptions) (*Result, error) {
+	cfg := p.Config
+	if o.Report == "" {
+		o.Report = ReportJSON
+	}
+	if err := o.Report.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := utils.EnsureDir(cfg.OutputDir); err != nil {
+		return nil, err
+	}
+
+	// Encrypting a subset keeps the other files' entries, and the salt
+	// their fingerprints were keyed with
+	var m *manifest.Manifest
+	var err error
+	if o.Merge && o.Report == ReportJSON {
+		if m, err = manifest.Load(cfg.OutputDir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if m == nil {
+		if m, err = manifest.New(p.ToolVersion); err != nil {
+			return nil, err
+		}
+		m.Note = defaultNote
+	}
+	m.ToolVersion = p.ToolVersion
+	if o.Note != "" {
+		m.Note = o.Note
+	}
+	if o.PasswordHint != "" {
+		m.PasswordHint = o.PasswordHint
+	}
+	m.Format = string(p.Options.Format)
+	m.KDF = p.Options.KDFSummary()
+	m.Recipients = len(p.Options.Recipients)
+
+	// Plaintext fingerprints are keyed by the password, so recipient-mode
+	// manifests only record ciphertext hashes
+	var key []byte
+	if len(p.Options.Recipients) > 0 {
+		m.HMACSalt = ""
+		m.SetKeyCheck(nil)
+	} else if o.Report == ReportJSON {
+		if m.HMACSalt == "" {
+			if err := m.NewSalt(); err != nil {
+				return nil, err
+			}
+		}
+		if key, err = m.Key(p.Password); err != nil {
+			return nil, err
+		}
+		m.SetKeyCheck(key)
+	}
+
+	opts := p.runOptions()
+	infos := make([]*utils.FileInfo, len(cfg.Files))
+	outcomes := p.forEach(cfg.Files, func(i int, fileMapping FileMapping, emit func(Event)) error {
+		emit(Event{Kind: FileStarted, File: fileMapping, Path: fileMapping.Input})
+
+		// Check if input file exists
+		if !utils.FileExists(fileMapping.Input) {
+			return p.missing(fileMapping, fileMapping.Input, emit)
+		}
+
+		// Get file info before encryption
+		fileInfo, err := utils.GetFileInfo(fileMapping.Input)
+		if err != nil {
+			emit(Event{Kind: FileFailed, File: fileMapping, Path: fileMapping.Input, Err: err})
+			return err
+		}
+
+		outputPath := filepath.Join(cfg.OutputDir, fileMapping.Output)
+		if err := utils.EnsureDir(filepath.Dir(outputPath)); err != nil {
+			emit(Event{Kind: FileFailed, File: fileMapping, Path: fileMapping.Input, Dest: outputPath, Err: err})
+			return err
+		}
+		var previous []byte
+		if fileMapping.Encryption == config.EncryptValues {
+			// Missing on the first encryption
+			previous, _ = os.ReadFile(outputPath)
+		}
+		fileOpts, err := FileOptions(opts, fileMapping, previous)
+		if err != nil {
+			emit(Event{Kind: FileFailed, File: fileMapping, Path: fileMapping.Input, Dest: outputPath, Err: err})
+			return err
+		}
+		if err := crypto.EncryptFileWithOptions(fileMapping.Input, outputPath, p.Password, fileOpts); err != nil {
+			emit(Event{Kind: FileFailed, File: fileMapping, Path: fileMapping.Input, Dest: outputPath, Err: err})
+			return err
+		}
+		emit(Event{Kind: FileDone, File: fileMapping, Path: fileMapping.Input, Dest: outputPath})
+		infos[i] = fileInfo
+		return nil
+	})
+
+	res := &Result{}
+	for i, err := range outcomes {
+		if res.count(cfg.Files[i], cfg.Files[i].Input, err) {
+			outputPath := filepath.Join(cfg.OutputDir, cfg.Files[i].Output)
+			entry, err := manifestEntry(cfg.Files[i], infos[i], outputPath, key)
+			if err != nil {
+				return res, err
+			}
+			m.Set(*entry)
+		}
+	}
+
+	if res.Done == 0 {
+		if len(res.Failed) > 0 {
+			return res, res.err()
+		}
+		return res, fmt.Errorf("no files were encrypted")
+	}
+
+	if res.Report, err = writeReport(cfg.OutputDir, m, o.Report); err != nil {
+		return res, err
+	}
+	return res, res.err()
+}
+
+// Decrypt decrypts every encrypted file back to its input path and copies
+// it to copy_to. A file that fails to decrypt or copy, does not match the
+// manifest or is missing without being optional does not stop the others
+// unless FailFast is set; the error returned then joins a *FileError for
+// each.
+func (p *Project) Decrypt() (*Result, error) {
+	return p.decrypt(func(f FileMapping) (string, error) {
+		// Ensure output directory exists
+		return f.Input, utils.EnsureDir(filepath.Dir(f.Input))
+	}, true)
+}
+
+// Test decrypts every encrypted file into the test output directory,
+// leaving the input files alone. Failures are returned like Decrypt's.
+func (p *Project) Test() (*Result, error) {
+	if err := utils.EnsureDir(p.Config.TestOutputDir); err != nil {
+		return nil, err
+	}
+	return p.decrypt(func(f FileMapping) (string, error) {
+		// Files from a glob or directory keep their relative path so
+		// names cannot collide
+		if f.Pattern == "" {
+			return filepath.Join(p.Config.TestOutputDir, filepath.Base(f.Input)), nil
+		}
+		dest := filepath.Join(p.Config.TestOutputDir, filepath.FromSlash(strings.TrimSuffix(f.Output, config.EncryptedSuffix)))
+		return dest, utils.EnsureDir(filepath.Dir(dest))
+	}, false)
+}
+
+// decrypt runs the decrypt flow, writing each file to the path chosen by
+// dest and copying it to copy_to when copyTo is set
+func (p *Project) decrypt(dest func(FileMapping) (string, error), copyTo bool) (*Result, error) {
+	m, key, err := p.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := p.runOptions()
+	outcomes := p.forEach(p.Config.Files, func(i int, fileMapping FileMapping, emit func(Event)) error {
+		encryptedPath := filepath.Join(p.Config.OutputDir, fileMapping.Output)
+		emit(Event{Kind: FileStarted, File: fileMapping, Path: encryptedPath})
+
+		// Check if encrypted file exists
+		if !utils.FileExists(encryptedPath) {
+			return p.missing(fileMapping, encryptedPath, emit)
+		}
+
+		outputPath, err := dest(fileMapping)
+		if err == nil {
+			err = decryptWithManifest(fileMapping, encryptedPath, outputPath, p.Password, opts, m, key)
+		}
+		if err != nil {
+			emit(Event{Kind: FileFailed, File: fileMapping, Path: encryptedPath, Dest: outputPath, Err: err})
+			return err
+		}
+		emit(Event{Kind: FileDone, File: fileMapping, Path: encryptedPath, Dest: outputPath})
+
+		if copyTo && fileMapping.CopyTo != "" {
+			err := utils.EnsureDir(filepath.Dir(fileMapping.CopyTo))
+			if err == nil {
+				err = utils.CopyFile(outputPath, fileMapping.CopyTo)
+			}
+			emit(Event{Kind: FileCopied, File: fileMapping, Path: outputPath, Dest: fileMapping.CopyTo, Err: err})
+			if err != nil {
+				return fmt.Errorf("failed to copy to %s: %w", fileMapping.CopyTo, err)
+			}
+		}
+		return nil
+	})
+
+	res := &Result{}
+	for i, err := range outcomes {
+		res.count(p.Config.Files[i], filepath.Join(p.Config.OutputDir, p.Config.Files[i].Output), err)
+	}
+
+	if len(res.Failed) > 0 {
+		return res, res.err()
+	}
+	if res.Done == 0 {
+		return res, fmt.Errorf("no files were decrypted")
+	}
+	return res, nil
+}
+
+var (
+	// errSkipped is returned to forEach for an optional file that does
+	// not exist
+	errSkipped = errors.New("skipped")
+	// errStopped is what forEach records for the files FailFast left out
+	errStopped = errors.New("stopped")
+)
+
+// missing reports a file whose path does not exist, skipping it when it is
+// optional
+func (p *Project) missing(f FileMapping, path string, emit func(Event)) error {
+	if f.Optional || p.AllowMissing {
+		emit(Event{Kind: FileSkipped, File: f, Path: path})
+		return errSkipped
+	}
+	emit(Event{Kind: FileFailed, File: f, Path: path, Err: ErrMissing})
+	return ErrMissing
+}
+
+// count adds what forEach returned for the file read from path to the
+// result, reporting whether the file was done
+func (r *Result) count(f FileMapping, path string, err error) bool {
+	switch err {
+	case nil:
+		r.Done++
+		return true
+	case errSkipped:
+		r.Skipped++
+	case errStopped:
+		r.Stopped++
+	default:
+		r.Failed = append(r.Failed, &FileError{File: f, Path: path, Err: err})
+	}
+	return false
+}
+
+// err joins the errors of the failed files, or is nil when none failed
+func (r *Result) err() error {
+	errs := make([]error, len(r.Failed))
+	for i, fileErr := range r.Failed {
+		errs[i] = fileErr
+	}
+	return errors.Join(errs...)
+}
+
+// runOptions returns the options for one run, with a key cache so that
+// files sharing a salt derive their key once
+func (p *Project) runOptions() Options {
+	opts := p.Options
+	if opts.Keys == nil {
+		opts.Keys = crypto.NewKeyCache()
+	}
+	return opts
+}
+
+// forEach calls fn for every file, up to Jobs at a time, and returns what
+// each call returned. Each file's events are passed to Progress once it and
+// every file before it are finished, so they come in the same order
+// however the work was scheduled. With FailFast, files not yet started
+// when one fails are given errStopped.
+func (p *Project) forEach(files []FileMapping, fn func(i int, f FileMapping, emit func(Event)) error) []error {
+	var stop atomic.Bool
+	run := func(i int, emit func(Event)) error {
+		if stop.Load() {
+			return errStopped
+		}
+		err := fn(i, files[i], emit)
+		if err != nil && err != errSkipped && p.FailFast {
+			stop.Store(true)
+		}
+		return err
+	}
+
+	jobs := p.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	outcomes := make([]error, len(files))
+	if jobs == 1 || len(files) < 2 {
+		for i := range files {
+			outcomes[i] = run(i, p.emit)
+		}
+		return outcomes
+	}
+
+	events := make([][]Event, len(files))
+	next := make(chan int)
+	finished := make(chan int)
+	go func() {
+		for i := range files {
+			next <- i
+		}
+		close(next)
+	}()
+	for w := 0; w < jobs && w < len(files); w++ {
+		go func() {
+			for i := range next {
+				outcomes[i] = run(i, func(e Event) { events[i] = append(events[i], e) })
+				finished <- i
+			}
+		}()
+	}
+
+	done := make([]bool, len(files))
+	reported := 0
+	for range files {
+		done[<-finished] = true
+		for reported < len(files) && done[reported] {
+			for _, e := range events[reported] {
+				p.emit(e)
+			}
+			reported++
+		}
+	}
+	return outcomes
+}
+
+// loadManifest reads the manifest from the output directory and derives its
+// HMAC key. Both are nil when there is no manifest.
+func (p *Project) loadManifest() (*manifest.Manifest, []byte, error) {
+	m, err := manifest.Load(p.Config.OutputDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := m.Key(p.Password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.emit(Event{Kind: ManifestLoaded, Path: manifest.Path(p.Config.OutputDir)})
+	return m, key, nil
+}
+
+// manifestEntry describes an encrypted file for the manifest. The
+// fingerprints are only computed when key is set.
+func manifestEntry(fileMapping FileMapping, fileInfo *utils.FileInfo, outputPath string, key []byte) (*manifest.Entry, error) {
+	entry := &manifest.Entry{
+		Input:      fileMapping.Input,
+		Output:     fileMapping.Output,
+		Size:       fileInfo.Size,
+		Lines:      fileInfo.Lines,
+		ModifiedAt: fileInfo.LastModified.UTC(),
+	}
+	entry.SetPerm(fileInfo.Mode)
+
+	var err error
+	if entry.CiphertextSHA256, err = manifest.SHA256File(outputPath); err != nil {
+		return nil, err
+	}
+	if key != nil {
+		if entry.PlaintextHMAC, err = manifest.HMACFile(key, fileMapping.Input); err != nil {
+			return nil, err
+		}
+		if entry.CiphertextHMAC, err = manifest.HMACFile(key, outputPath); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// decryptWithManifest decrypts like crypto.DecryptFileWithOptions and, when
+// the manifest has an entry for the file, checks the ciphertext before and
+// the plaintext after decryption. outputPath is only replaced when both
+// match, and gets the mode chosen by outputMode.
+func decryptWithManifest(fileMapping FileMapping, encryptedPath, outputPath, pwd string, opts Options, m *manifest.Manifest, key []byte) error {
+	entry := m.Entry(fileMapping.Output)
+	perm := outputMode(fileMapping, entry)
+	if entry == nil {
+		return crypto.DecryptFileMode(encryptedPath, outputPath, pwd, opts, perm)
+	}
+
+	if err := entry.VerifyCiphertext(encryptedPath); err != nil {
+		return err
+	}
+	if key == nil || entry.PlaintextHMAC == "" {
+		// A matching ciphertext is enough to know the plaintext is the
+		// one recorded
+		if err := crypto.DecryptFileMode(encryptedPath, outputPath, pwd, opts, perm); err != nil {
+			return err
+		}
+		restoreModTime(outputPath, entry)
+		return nil
+	}
+
+	// Decrypt next to the destination so a mismatch leaves it untouched
+	in, err := os.Open(encryptedPath)
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+	defer in.Close()
+
+	tmpPath, err := utils.StageFile(outputPath, perm, func(w io.Writer) error {
+		return crypto.Decrypt(in, w, pwd, opts)
+	})
+	if err != nil {
+		return err
+	}
+	if err := entry.VerifyPlaintext(key, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := utils.ReplaceFile(tmpPath, outputPath); err != nil {
+		return err
+	}
+	restoreModTime(outputPath, entry)
+	return nil
+}
+
+// outputMode picks the permissions for a decrypted file: the mode set in the
+// config, else the mode the file had when it was encrypted, else owner-only
+func outputMode(fileMapping FileMapping, entry *manifest.Entry) os.FileMode {
+	if fileMapping.Mode != 0 {
+		return os.FileMode(fileMapping.Mode)
+	}
+	if perm, ok := entry.Perm(); ok {
+		return perm
+	}
+	return 0600
+}
+
+// restoreModTime gives a verified decrypted file the modification time it
+// had when it was encrypted, so status does not report it as changed
+func restoreModTime(path string, entry *manifest.Entry) {
+	if entry != nil && !entry.ModifiedAt.IsZero() {
+		os.Chtimes(path, entry.ModifiedAt, entry.ModifiedAt)
+	}
+}
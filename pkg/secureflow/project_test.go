@@ -0,0 +1,407 @@
+package secureflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+)
+
+const testConfig = `output_dir: encrypted
+test_output_dir: test_decrypted
+format: aead
+kdf:
+  algorithm: pbkdf2
+  iterations: 1000
+files:
+  - input: .env
+    output: env.encrypted
+    copy_to: backup/.env
+  - input: config/app.yaml
+    output: app.yaml.encrypted
+  - input: missing.txt
+    output: missing.txt.encrypted
+    optional: true
+environments:
+  staging:
+    output_dir: encrypted/staging
+`
+
+// newTestProject writes testConfig and its input files to a temporary
+// working directory and loads it for env
+func newTestProject(t *testing.T, env string) *Project {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	files := map[string]string{
+		"secureflow.yaml": testConfig,
+		".env":            "API_KEY=secret\n",
+		"config/app.yaml": "debug: false\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	p, err := NewProject("secureflow.yaml", env)
+	if err != nil {
+		t.Fatalf("NewProject failed: %v", err)
+	}
+	p.Password = "pw"
+	return p
+}
+
+// recordEvents collects the kinds of the events p reports
+func recordEvents(p *Project) *[]EventKind {
+	var kinds []EventKind
+	p.Progress = func(e Event) { kinds = append(kinds, e.Kind) }
+	return &kinds
+}
+
+func TestLoadConfig(t *testing.T) {
+	p := newTestProject(t, "staging")
+	if p.Config.OutputDir != "encrypted/staging" {
+		t.Errorf("OutputDir = %q, want encrypted/staging", p.Config.OutputDir)
+	}
+	if p.Options.Format != FormatAEAD || p.Options.KDF.Algorithm != KDFPBKDF2 || p.Options.KDF.Iterations != 1000 {
+		t.Errorf("Options = %+v, want aead with pbkdf2 at 1000 iterations", p.Options)
+	}
+
+	if _, err := LoadConfig("secureflow.yaml", "production"); err == nil {
+		t.Error("LoadConfig accepted an unknown environment")
+	}
+	if _, err := LoadConfig("nonexistent.yaml", ""); err == nil {
+		t.Error("LoadConfig accepted a missing file")
+	}
+
+	if err := os.WriteFile("invalid.yaml", []byte("output_dir: out\nfiles:\n  - output: x\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	var verr *ValidationError
+	if _, err := LoadConfig("invalid.yaml", ""); !errors.As(err, &verr) {
+		t.Errorf("LoadConfig error = %v, want a *ValidationError", err)
+	}
+}
+
+func TestProjectEncryptDecrypt(t *testing.T) {
+	p := newTestProject(t, "")
+	events := recordEvents(p)
+
+	res, err := p.Encrypt(EncryptOptions{Note: "test"})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if res.Done != 2 || res.Skipped != 1 || res.Report != manifest.Path("encrypted") {
+		t.Errorf("Encrypt = %+v, want 2 done, 1 skipped and the manifest", res)
+	}
+	want := []EventKind{FileStarted, FileDone, FileStarted, FileDone, FileStarted, FileSkipped}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("Encrypt events = %v, want %v", *events, want)
+	}
+
+	for _, path := range []string{".env", "config/app.yaml"} {
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("Failed to remove %s: %v", path, err)
+		}
+	}
+	*events = nil
+
+	res, err = p.Decrypt()
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if res.Done != 2 || res.Skipped != 1 {
+		t.Errorf("Decrypt = %+v, want 2 done and 1 skipped", res)
+	}
+	want = []EventKind{ManifestLoaded, FileStarted, FileDone, FileCopied, FileStarted, FileDone, FileStarted, FileSkipped}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("Decrypt events = %v, want %v", *events, want)
+	}
+
+	for path, content := range map[string]string{
+		".env":            "API_KEY=secret\n",
+		"backup/.env":     "API_KEY=secret\n",
+		"config/app.yaml": "debug: false\n",
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", path, got, content)
+		}
+	}
+}
+
+func TestProjectEncryptMissing(t *testing.T) {
+	p := newTestProject(t, "")
+	if err := os.Remove("config/app.yaml"); err != nil {
+		t.Fatalf("Failed to remove config/app.yaml: %v", err)
+	}
+
+	res, err := p.Encrypt(EncryptOptions{})
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || fileErr.Path != "config/app.yaml" || !errors.Is(err, ErrMissing) {
+		t.Fatalf("Encrypt error = %v, want ErrMissing for config/app.yaml", err)
+	}
+	if res.Done != 1 || res.Skipped != 1 {
+		t.Errorf("Encrypt = %+v, want 1 done and the optional file skipped", res)
+	}
+
+	// The files that were encrypted are still recorded
+	m, err := manifest.Load("encrypted")
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	if len(m.Files) != 1 {
+		t.Errorf("manifest has %d entries, want 1", len(m.Files))
+	}
+
+	p.AllowMissing = true
+	if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+		t.Errorf("Encrypt with AllowMissing failed: %v", err)
+	}
+}
+
+func TestProjectTest(t *testing.T) {
+	p := newTestProject(t, "")
+	if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if err := os.WriteFile(".env", []byte("API_KEY=changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .env: %v", err)
+	}
+
+	res, err := p.Test()
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if res.Done != 2 {
+		t.Errorf("Test decrypted %d file(s), want 2", res.Done)
+	}
+
+	got, err := os.ReadFile(filepath.Join("test_decrypted", ".env"))
+	if err != nil {
+		t.Fatalf("Failed to read test output: %v", err)
+	}
+	if string(got) != "API_KEY=secret\n" {
+		t.Errorf("test output = %q, want the encrypted content", got)
+	}
+	if got, _ := os.ReadFile(".env"); string(got) != "API_KEY=changed\n" {
+		t.Errorf(".env = %q, want it left alone", got)
+	}
+	if _, err := os.Stat(filepath.Join("backup", ".env")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Test copied a file to copy_to")
+	}
+}
+
+func TestProjectEncryptMerge(t *testing.T) {
+	p := newTestProject(t, "")
+	if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	p.Config.Select(Selector{Only: []string{".env"}})
+	if _, err := p.Encrypt(EncryptOptions{Merge: true}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	m, err := manifest.Load("encrypted")
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	if len(m.Files) != 2 {
+		t.Errorf("manifest has %d entries after a merge, want 2", len(m.Files))
+	}
+}
+
+func TestProjectEncryptValues(t *testing.T) {
+	p := newTestProject(t, "")
+	p.Config.Files[1].Encryption = "values"
+	if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	first, err := os.ReadFile("encrypted/app.yaml.encrypted")
+	if err != nil {
+		t.Fatalf("Failed to read encrypted file: %v", err)
+	}
+	if !bytes.Contains(first, []byte("\ndebug: \"ENC[")) {
+		t.Errorf("Expected an encrypted value under a readable key, got:\n%s", first)
+	}
+
+	// Re-encrypting unchanged values leaves the file as it was
+	if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	second, err := os.ReadFile("encrypted/app.yaml.encrypted")
+	if err != nil {
+		t.Fatalf("Failed to read encrypted file: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("Re-encrypting changed the file:\n%s\n%s", first, second)
+	}
+
+	if _, err := p.Test(); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	decrypted, err := os.ReadFile(filepath.Join("test_decrypted", "app.yaml"))
+	if err != nil || string(decrypted) != "debug: false\n" {
+		t.Errorf("Test decrypted %q (err: %v), want the original file", decrypted, err)
+	}
+}
+
+func TestProjectDecryptErrors(t *testing.T) {
+	t.Run("wrong password", func(t *testing.T) {
+		p := newTestProject(t, "")
+		if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+
+		p.Password = "other"
+		if _, err := p.Decrypt(); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("Decrypt error = %v, want ErrWrongPassword", err)
+		}
+	})
+
+	t.Run("tampered file", func(t *testing.T) {
+		p := newTestProject(t, "")
+		if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		path := filepath.Join("encrypted", "app.yaml.encrypted")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		data[len(data)-1] ^= 1
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		_, err = p.Decrypt()
+		var fileErr *FileError
+		if !errors.As(err, &fileErr) || fileErr.Path != path {
+			t.Fatalf("Decrypt error = %v, want a *FileError for %s", err, path)
+		}
+		if !errors.Is(err, ErrMismatch) {
+			t.Errorf("Decrypt error = %v, want ErrMismatch", err)
+		}
+	})
+
+	t.Run("other files continue", func(t *testing.T) {
+		p := newTestProject(t, "")
+		if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		path := filepath.Join("encrypted", "env.encrypted")
+		if err := os.WriteFile(path, []byte("not encrypted"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		res, err := p.Decrypt()
+		if err == nil || len(res.Failed) != 1 || res.Failed[0].Path != path {
+			t.Fatalf("Decrypt = %+v, %v, want a failure for %s", res, err, path)
+		}
+		if res.Done != 1 {
+			t.Errorf("Decrypt decrypted %d file(s), want the other file decrypted", res.Done)
+		}
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		p := newTestProject(t, "")
+		res, err := p.Decrypt()
+		if !errors.Is(err, ErrMissing) || len(res.Failed) != 2 || res.Skipped != 1 {
+			t.Fatalf("Decrypt = %+v, %v, want 2 missing files and the optional one skipped", res, err)
+		}
+		if res.Failed[0].Path != filepath.Join("encrypted", "env.encrypted") {
+			t.Errorf("Decrypt failed %s first, want encrypted/env.encrypted", res.Failed[0].Path)
+		}
+	})
+
+	t.Run("copy failure", func(t *testing.T) {
+		p := newTestProject(t, "")
+		if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		// copy_to needs backup to be a directory
+		if err := os.WriteFile("backup", nil, 0644); err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+
+		res, err := p.Decrypt()
+		if err == nil || len(res.Failed) != 1 || res.Failed[0].File.Input != ".env" {
+			t.Fatalf("Decrypt = %+v, %v, want the copy of .env to fail", res, err)
+		}
+		if res.Done != 1 {
+			t.Errorf("Decrypt decrypted %d file(s), want the other file decrypted", res.Done)
+		}
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		p := newTestProject(t, "")
+		if _, err := p.Encrypt(EncryptOptions{}); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		path := filepath.Join("encrypted", "env.encrypted")
+		if err := os.WriteFile(path, []byte("not encrypted"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		p.Jobs = 1
+		p.FailFast = true
+		res, err := p.Decrypt()
+		if err == nil || len(res.Failed) != 1 || res.Done != 0 || res.Stopped != 2 {
+			t.Errorf("Decrypt = %+v, %v, want it to stop after the first file", res, err)
+		}
+	})
+
+	t.Run("nothing encrypted", func(t *testing.T) {
+		p := newTestProject(t, "")
+		p.AllowMissing = true
+		res, err := p.Decrypt()
+		if err == nil || res.Skipped != 3 {
+			t.Errorf("Decrypt = %+v, %v, want an error after skipping 3 files", res, err)
+		}
+		var fileErr *FileError
+		if errors.As(err, &fileErr) {
+			t.Errorf("Decrypt error = %v, want no *FileError", err)
+		}
+	})
+}
+
+func TestProjectJobs(t *testing.T) {
+	for _, jobs := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("%d jobs", jobs), func(t *testing.T) {
+			p := newTestProject(t, "")
+			p.Jobs = jobs
+			var paths []string
+			p.Progress = func(e Event) { paths = append(paths, e.Path) }
+
+			res, err := p.Encrypt(EncryptOptions{})
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			if res.Done != 2 || res.Skipped != 1 {
+				t.Errorf("Encrypt = %+v, want 2 done and 1 skipped", res)
+			}
+			want := []string{".env", ".env", "config/app.yaml", "config/app.yaml", "missing.txt", "missing.txt"}
+			if !reflect.DeepEqual(paths, want) {
+				t.Errorf("Encrypt reported %v, want the files in config order %v", paths, want)
+			}
+
+			res, err = p.Test()
+			if err != nil || res.Done != 2 {
+				t.Errorf("Test = %+v, %v, want 2 files decrypted", res, err)
+			}
+		})
+	}
+}
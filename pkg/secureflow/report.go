@@ -0,0 +1,95 @@
+package secureflow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+	"github.com/MayR-Labs/secureflow-go/internal/utils"
+)
+
+// ReportFormat selects the report Encrypt writes to the output directory
+type ReportFormat string
+
+// Report formats
+const (
+	ReportJSON ReportFormat = "json" // manifest.json, which decrypt and test verify against
+	ReportText ReportFormat = "text" // the human-readable report.txt
+	ReportNone ReportFormat = "none"
+)
+
+// TextReportName is the free-text report written for ReportText
+const TextReportName = "report.txt"
+
+// validate checks that f is a known report format
+func (f ReportFormat) validate() error {
+	switch f {
+	case ReportJSON, ReportText, ReportNone:
+		return nil
+	default:
+		return fmt.Errorf("invalid report format %q (expected json, text or none)", f)
+	}
+}
+
+// writeReport saves m in the requested format and removes a manifest left by
+// an earlier run that no longer describes the encrypted files
+func writeReport(outputDir string, m *manifest.Manifest, format ReportFormat) (string, error) {
+	if format != ReportJSON {
+		if err := os.Remove(manifest.Path(outputDir)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to remove stale manifest: %w", err)
+		}
+	}
+
+	switch format {
+	case ReportJSON:
+		return manifest.Path(outputDir), m.Save(outputDir)
+	case ReportText:
+		path := filepath.Join(outputDir, TextReportName)
+		return path, writeTextReport(path, m)
+	default:
+		return "", nil
+	}
+}
+
+// writeTextReport writes the human-readable report.txt
+func writeTextReport(path string, m *manifest.Manifest) error {
+	err := utils.WriteAtomic(path, 0644, func(reportFile io.Writer) error {
+		// Write report header
+		fmt.Fprintf(reportFile, "Encryption Report\n")
+		fmt.Fprintf(reportFile, "=================\n")
+		fmt.Fprintf(reportFile, "\n")
+		fmt.Fprintf(reportFile, "Note: %s\n", m.Note)
+		if m.Recipients > 0 {
+			fmt.Fprintf(reportFile, "Recipients: %d\n", m.Recipients)
+		} else if m.PasswordHint != "" {
+			fmt.Fprintf(reportFile, "Password Hint: %s\n", m.PasswordHint)
+		} else {
+			fmt.Fprintf(reportFile, "Password Hint: N/A\n")
+		}
+		fmt.Fprintf(reportFile, "Format: %s\n", m.Format)
+		fmt.Fprintf(reportFile, "Created at: %s\n", m.CreatedAt.Local().Format("2006-01-02"))
+		fmt.Fprintf(reportFile, "=================\n")
+		fmt.Fprintf(reportFile, "\n")
+
+		for _, entry := range m.Files {
+			fmt.Fprintf(reportFile, "File:           %s\n", entry.Input)
+			fmt.Fprintf(reportFile, "Encrypted As:   %s\n", entry.Output)
+			fmt.Fprintf(reportFile, "Size (bytes):   %d\n", entry.Size)
+			fmt.Fprintf(reportFile, "Lines:          %d\n", entry.Lines)
+			fmt.Fprintf(reportFile, "Last Modified:  %s\n", entry.ModifiedAt.Local().Format("2006-01-02 15:04:05"))
+			fmt.Fprintf(reportFile, "----------------------------------------\n")
+			fmt.Fprintf(reportFile, "\n")
+		}
+		// Write errors are kept by the buffered writer and reported when
+		// it is flushed
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write report file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package secureflow is the Go API behind the secureflow command, for tools
+// that embed it instead of running the binary.
+//
+// Encrypt, Decrypt, EncryptBytes and DecryptBytes work on single streams
+// and produce the same files as the command. Project runs the encrypt,
+// decrypt and test flows over the files listed in a secureflow.yaml, keeping
+// manifest.json up to date, and reports progress through a callback instead
+// of printing.
+package secureflow
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/MayR-Labs/secureflow-go/internal/crypto"
+	"github.com/MayR-Labs/secureflow-go/internal/manifest"
+)
+
+// Types shared with the command; see their fields for the details
+type (
+	// Options controls how files are encrypted and decrypted
+	Options = crypto.Options
+	// Format identifies the container format of an encrypted file
+	Format = crypto.Format
+	// KDF names a key derivation function for the aead format
+	KDF = crypto.KDF
+	// KDFParams selects a key derivation function and its cost
+	KDFParams = crypto.KDFParams
+	// Recipient is a public key files can be encrypted to
+	Recipient = crypto.Recipient
+	// Identity is the private key that decrypts files encrypted to its
+	// Recipient
+	Identity = crypto.Identity
+	// KeyCache remembers derived keys across the files of a run
+	KeyCache = crypto.KeyCache
+)
+
+// Container formats
+const (
+	FormatOpenSSL = crypto.FormatOpenSSL
+	FormatAEAD    = crypto.FormatAEAD
+)
+
+// Key derivation functions for the aead format
+const (
+	KDFArgon2id = crypto.KDFArgon2id
+	KDFScrypt   = crypto.KDFScrypt
+	KDFPBKDF2   = crypto.KDFPBKDF2
+)
+
+// Errors that tell why decryption failed; test for them with errors.Is
+var (
+	// ErrInvalidFormat means the data is not a SecureFlow or OpenSSL file
+	ErrInvalidFormat = crypto.ErrInvalidFormat
+	// ErrWrongPassword means an aead file, or manifest.json, rejected the
+	// password
+	ErrWrongPassword = crypto.ErrWrongPassword
+	// ErrCorrupted means the file is damaged or has been tampered with
+	ErrCorrupted = crypto.ErrCorrupted
+	// ErrDecryptionFailed means an openssl file did not decrypt, which the
+	// format cannot attribute to a wrong password or to corruption
+	ErrDecryptionFailed = crypto.ErrDecryptionFailed
+	// ErrNoIdentity means none of the identities can decrypt the file
+	ErrNoIdentity = crypto.ErrNoIdentity
+	// ErrMismatch means a file differs from what manifest.json recorded
+	ErrMismatch = manifest.ErrMismatch
+)
+
+// Encrypt reads plaintext from r and writes an encrypted container to w
+func Encrypt(r io.Reader, w io.Writer, password string, opts Options) error {
+	return crypto.Encrypt(r, w, password, opts)
+}
+
+// Decrypt reads a container of either format from r and writes the
+// plaintext to w. Plaintext is written as it is decrypted, so w may have
+// received part of it when an error is returned.
+func Decrypt(r io.Reader, w io.Writer, password string, opts Options) error {
+	return crypto.Decrypt(r, w, password, opts)
+}
+
+// EncryptBytes encrypts plaintext in memory
+func EncryptBytes(plaintext []byte, password string, opts Options) ([]byte, error) {
+	var out bytes.Buffer
+	if err := Encrypt(bytes.NewReader(plaintext), &out, password, opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// DecryptBytes decrypts a container in memory. Unlike Decrypt, it returns
+// no plaintext unless the whole container decrypted.
+func DecryptBytes(ciphertext []byte, password string, opts Options) ([]byte, error) {
+	var out bytes.Buffer
+	if err := Decrypt(bytes.NewReader(ciphertext), &out, password, opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// NewKeyCache returns an empty KeyCache, to set as Options.Keys while
+// encrypting or decrypting many streams with one password
+func NewKeyCache() *KeyCache {
+	return crypto.NewKeyCache()
+}
+
+// ParseRecipient parses a public key printed by secureflow keygen
+func ParseRecipient(s string) (*Recipient, error) {
+	return crypto.ParseRecipient(s)
+}
+
+// ParseIdentities reads the private keys from an identity file written by
+// secureflow keygen
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	return crypto.ParseIdentities(r)
+}
@@ -0,0 +1,72 @@
+package secureflow
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecryptBytes(t *testing.T) {
+	plaintext := []byte("API_KEY=secret\nDB_PASSWORD=hunter2\n")
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"openssl", Options{}},
+		{"aead", Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ciphertext, err := EncryptBytes(plaintext, "pw", tt.opts)
+			if err != nil {
+				t.Fatalf("EncryptBytes failed: %v", err)
+			}
+			if bytes.Contains(ciphertext, plaintext) {
+				t.Fatal("Ciphertext contains the plaintext")
+			}
+
+			got, err := DecryptBytes(ciphertext, "pw", tt.opts)
+			if err != nil {
+				t.Fatalf("DecryptBytes failed: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("DecryptBytes = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptBytesErrors(t *testing.T) {
+	opts := Options{Format: FormatAEAD, KDF: KDFParams{Algorithm: KDFPBKDF2, Iterations: 1000}}
+	ciphertext, err := EncryptBytes([]byte("secret"), "pw", opts)
+	if err != nil {
+		t.Fatalf("EncryptBytes failed: %v", err)
+	}
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+		password   string
+		want       error
+	}{
+		{"wrong password", ciphertext, "other", ErrWrongPassword},
+		{"tampered", tampered, "pw", ErrCorrupted},
+		{"not encrypted", []byte("plain text"), "pw", ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecryptBytes(tt.ciphertext, tt.password, opts)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("DecryptBytes error = %v, want %v", err, tt.want)
+			}
+			if got != nil {
+				t.Errorf("DecryptBytes returned %q with an error", got)
+			}
+		})
+	}
+}